@@ -1,10 +1,97 @@
-#version 120
+#version 410 core
 
-varying vec2 textureCoord0;
+#include "lighting.glsl"
+
+in vec3 worldPosition0;
+in vec2 textureCoord0;
+in vec3 normal0;
+in vec4 tangent0;
+
+out vec4 fragColor;
 
 uniform sampler2D diffuse;
+uniform sampler2D normalMap;
+uniform bool hasNormalMap;
+
+uniform float metallic;
+uniform float roughness;
+
+uniform vec3 cameraPosition;
+uniform vec3 ambient;
+uniform Light lights[MAX_LIGHTS];
+uniform int lightCount;
+
+// Les textures sont en sRGB, l'éclairage est calculé en linéaire
+vec3 toLinear(vec3 color)
+{
+	return pow(color, vec3(2.2));
+}
+
+vec3 surfaceNormal()
+{
+	vec3 n = normalize(normal0);
+
+	if (!hasNormalMap || dot(tangent0.xyz, tangent0.xyz) == 0.0) {
+		return n;
+	}
+
+	vec3 t = normalize(tangent0.xyz - n * dot(n, tangent0.xyz));
+	vec3 b = cross(n, t) * tangent0.w;
+	vec3 m = texture(normalMap, textureCoord0).xyz * 2.0 - 1.0;
+
+	return normalize(mat3(t, b, n) * m);
+}
 
 void main()
 {
-	gl_FragColor = texture2D(diffuse, textureCoord0);
+	vec4 base = texture(diffuse, textureCoord0);
+	vec3 albedo = toLinear(base.rgb);
+
+	vec3 n = surfaceNormal();
+	vec3 v = normalize(cameraPosition - worldPosition0);
+
+	// Les faces vues de dos sont éclairées comme leur face avant
+	if (dot(n, v) < 0.0) {
+		n = -n;
+	}
+
+	float r = clamp(roughness, 0.04, 1.0);
+	float m = clamp(metallic, 0.0, 1.0);
+	vec3 f0 = mix(vec3(0.04), albedo, m);
+	float nv = max(dot(n, v), 1e-4);
+
+	vec3 color = ambient * albedo;
+
+	for (int i = 0; i < lightCount && i < MAX_LIGHTS; i++) {
+
+		vec3 l;
+		vec3 radiance = lights[i].radiance;
+
+		if (lights[i].point) {
+			vec3 offset = lights[i].position - worldPosition0;
+			float distance2 = max(dot(offset, offset), 1e-4);
+			l = offset * inversesqrt(distance2);
+			radiance /= distance2;
+		} else {
+			l = -normalize(lights[i].direction);
+		}
+
+		float nl = dot(n, l);
+		if (nl <= 0.0) {
+			continue;
+		}
+
+		vec3 h = normalize(l + v);
+		vec3 f = fresnel(max(dot(v, h), 0.0), f0);
+
+		vec3 specular = distribution(max(dot(n, h), 0.0), r * r) *
+			geometry(nv, nl, r) * f / (4.0 * nv * nl);
+		vec3 kd = (1.0 - f) * (1.0 - m);
+
+		color += (kd * albedo / PI + specular) * radiance * nl;
+	}
+
+	// Compression des hautes lumières & retour en sRGB
+	color = color / (color + 1.0);
+	fragColor = vec4(pow(color, vec3(1.0 / 2.2)), base.a);
 }
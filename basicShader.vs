@@ -1,14 +1,28 @@
-#version 120
+#version 410 core
 
-varying vec2 textureCoord0;
+in vec3 position;
+in vec2 textureCoord;
+in vec3 normal;
+in vec4 tangent;
 
-attribute vec3 position;
-attribute vec2 textureCoord;
+out vec3 worldPosition0;
+out vec2 textureCoord0;
+out vec3 normal0;
+out vec4 tangent0;
 
 uniform mat4 transform;
+uniform mat4 view;
+uniform mat4 projection;
+uniform mat3 normalMatrix;
 
 void main()
 {
-	gl_Position = transform * vec4(position, 1.0);
+	vec4 worldPosition = transform * vec4(position, 1.0);
+
+	gl_Position = projection * view * worldPosition;
+
+	worldPosition0 = worldPosition.xyz;
 	textureCoord0 = textureCoord;
-}
\ No newline at end of file
+	normal0 = normalMatrix * normal;
+	tangent0 = vec4(mat3(transform) * tangent.xyz, tangent.w);
+}
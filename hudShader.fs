@@ -0,0 +1,14 @@
+#version 410 core
+
+in vec2 textureCoord0;
+in vec4 color0;
+
+out vec4 fragColor;
+
+// Caractères blancs dont seule la transparence est utilisée
+uniform sampler2D atlas;
+
+void main()
+{
+	fragColor = vec4(color0.rgb, color0.a * texture(atlas, textureCoord0).a);
+}
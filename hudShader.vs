@@ -0,0 +1,18 @@
+#version 410 core
+
+in vec3 position;
+in vec2 textureCoord;
+in vec4 color;
+
+out vec2 textureCoord0;
+out vec4 color0;
+
+// Pixels depuis le coin en haut à gauche
+uniform mat4 projection;
+
+void main()
+{
+	gl_Position = projection * vec4(position, 1.0);
+	textureCoord0 = textureCoord;
+	color0 = color;
+}
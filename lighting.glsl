@@ -0,0 +1,32 @@
+// Modèle métal/rugosité : distribution GGX, géométrie de Smith & Fresnel
+// de Schlick
+
+#define MAX_LIGHTS 4
+#define PI 3.14159265359
+
+struct Light {
+	bool point;
+	vec3 direction;
+	vec3 position;
+	vec3 radiance;
+};
+
+float distribution(float nh, float alpha)
+{
+	float a2 = alpha * alpha;
+	float d = nh * nh * (a2 - 1.0) + 1.0;
+
+	return a2 / (PI * d * d);
+}
+
+float geometry(float nv, float nl, float r)
+{
+	float k = (r + 1.0) * (r + 1.0) / 8.0;
+
+	return nv / (nv * (1.0 - k) + k) * nl / (nl * (1.0 - k) + k);
+}
+
+vec3 fresnel(float vh, vec3 f0)
+{
+	return f0 + (1.0 - f0) * pow(1.0 - vh, 5.0);
+}
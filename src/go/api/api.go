@@ -3,9 +3,24 @@ package api
 import (
 	"github.com/ant0ine/go-json-rest/rest"
 	"net/http"
+
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/sessions"
+	"github.com/ohohleo/violin/web"
 )
 
-func New() error {
+func New(options Options, store *sessions.Store, devices *input.Manager) (s *Server, err error) {
+
+	s = NewServer(options)
+
+	s.SetShares(func(token string) (string, bool, bool) {
+		session, err := store.Shared(token)
+		if err != nil {
+			return "", false, false
+		}
+
+		return session.Id, session.Recording, true
+	})
 
 	// Mise en place de l'API
 	api := rest.NewApi()
@@ -14,22 +29,37 @@ func New() error {
 	api.Use(rest.DefaultDevStack...)
 
 	router, err := rest.MakeRouter(
-		rest.Get("/test", func(w rest.ResponseWriter, req *rest.Request) {
-			w.WriteJson(map[string]string{"Body": "TEST"})
-		}),
+		rest.Get("/sessions", ListSessions(store)),
+		rest.Post("/sessions", StartSession(store)),
+		rest.Get("/sessions/:id", GetSession(store)),
+		rest.Post("/sessions/:id/stop", StopSession(store)),
+		rest.Get("/sessions/:id/download", DownloadSession(store)),
+		rest.Delete("/sessions/:id", DeleteSession(store)),
+		rest.Post("/sessions/:id/share", ShareSession(store)),
+		rest.Delete("/sessions/:id/share", UnshareSession(store)),
+
+		// Les routes fixes doivent précéder celles paramétrées
+		rest.Get("/devices", ListDevices(devices)),
+		rest.Get("/devices/ports", ListPorts(devices)),
+		rest.Post("/devices", OpenDevice(devices)),
+		rest.Get("/devices/:id", GetDevice(devices)),
+		rest.Delete("/devices/:id", CloseDevice(devices)),
 	)
 
 	if err != nil {
-		return err
+		return
 	}
 
 	api.SetApp(router)
 
-	http.Handle("/api/", http.StripPrefix("/api", api.MakeHandler()))
+	s.Handle("/api/", http.StripPrefix("/api", api.MakeHandler()))
+
+	s.AddWeb()
 
-	// Gestion des pages web statique
-	http.Handle("/", http.StripPrefix("/",
-		http.FileServer(http.Dir("/home/ohohleo/accelerometer/src/web"))))
+	return
+}
 
-	return nil
+// Gestion des pages web statique
+func (s *Server) AddWeb() {
+	s.Handle("/", http.FileServer(web.FileSystem(s.options.WebDir)))
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Paramètres permettant de s'authentifier lorsque les entêtes ne peuvent
+	// pas être modifiés (EventSource, WebSocket)
+	TOKEN_PARAM = "access_token"
+	SHARE_PARAM = "share"
+
+	CORS_MAX_AGE = 600
+)
+
+// Routes nécessitant une authentification lorsqu'un jeton est configuré
+var PROTECTED_PREFIXES = []string{"/api/", "/stream/", "/ws", "/metrics"}
+
+var CORS_METHODS = []string{"GET", "POST", "DELETE", "OPTIONS"}
+var CORS_HEADERS = []string{"Authorization", "Content-Type", "Last-Event-ID"}
+
+// Vérifie un jeton de partage & retourne l'identifiant de la session associée
+type ShareValidator func(token string) (id string, recording bool, ok bool)
+
+// Chaîne de traitement commune à toutes les routes : CORS puis authentification
+func (s *Server) handler() http.Handler {
+	return s.cors(s.authenticate(s.mux))
+}
+
+// Autorise les requêtes provenant des origines configurées
+func (s *Server) cors(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || sameOrigin(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := s.allowedOrigin(origin)
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+
+		// Requête de pré-vérification
+		if r.Method == http.MethodOptions &&
+			r.Header.Get("Access-Control-Request-Method") != "" {
+
+			if allowed == false {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(CORS_METHODS, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(CORS_HEADERS, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(CORS_MAX_AGE))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) allowedOrigin(origin string) bool {
+
+	for _, allowed := range s.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Vérifie l'origine des connexions WebSocket
+func (s *Server) checkOrigin(r *http.Request) bool {
+
+	origin := r.Header.Get("Origin")
+
+	return origin == "" || sameOrigin(r) || s.allowedOrigin(origin)
+}
+
+func sameOrigin(r *http.Request) bool {
+
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(origin.Host, r.Host)
+}
+
+// Exige le jeton configuré sur les routes protégées : un jeton de partage
+// ne donne accès qu'en lecture à sa session & aux flux de données pendant
+// son enregistrement
+func (s *Server) authenticate(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if s.options.Token == "" || protected(r.URL.Path) == false {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if token := requestToken(r); token != "" &&
+			subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if share := r.URL.Query().Get(SHARE_PARAM); share != "" && s.shares != nil {
+			if id, recording, ok := s.shares(share); ok && sharedRoute(r, id, recording) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="violin"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+	})
+}
+
+func protected(path string) bool {
+
+	for _, prefix := range PROTECTED_PREFIXES {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Routes accessibles avec le lien de partage de la session spécifiée
+func sharedRoute(r *http.Request, id string, recording bool) bool {
+
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	switch r.URL.Path {
+	case "/api/sessions/" + id, "/api/sessions/" + id + "/download":
+		return true
+	}
+
+	return recording && strings.HasPrefix(r.URL.Path, "/stream/")
+}
+
+func requestToken(r *http.Request) string {
+
+	authorization := r.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+
+	return r.URL.Query().Get(TOKEN_PARAM)
+}
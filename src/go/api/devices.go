@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ohohleo/violin/input"
+)
+
+type Port struct {
+	Name   string `json:"name"`
+	Opened bool   `json:"opened"`
+}
+
+type DeviceRequest struct {
+	Name     string `json:"name"`
+	Baudrate int    `json:"baudrate"`
+	Protocol string `json:"protocol"`
+}
+
+func ListDevices(devices *input.Manager) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		w.WriteJson(devices.Status())
+	}
+}
+
+// Ports série disponibles & protocoles supportés
+func ListPorts(devices *input.Manager) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		opened := make(map[string]bool)
+		for _, status := range devices.Status() {
+			opened[status.Name] = true
+		}
+
+		ports := make([]Port, 0)
+		for _, name := range input.ListPorts() {
+			ports = append(ports, Port{
+				Name:   name,
+				Opened: opened[name],
+			})
+		}
+
+		w.WriteJson(map[string]interface{}{
+			"ports":     ports,
+			"protocols": input.PROTOCOLS,
+		})
+	}
+}
+
+// Connexion au capteur décrit dans le corps de la requête
+func OpenDevice(devices *input.Manager) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		var request DeviceRequest
+
+		if err := r.DecodeJsonPayload(&request); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if request.Name == "" {
+			rest.Error(w, "device name required", http.StatusBadRequest)
+			return
+		}
+
+		device, err := devices.Open(request.Name, request.Baudrate, request.Protocol)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.WriteJson(device.Status())
+	}
+}
+
+func GetDevice(devices *input.Manager) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		device, err := devices.Get(r.PathParam("id"))
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteJson(device.Status())
+	}
+}
+
+func CloseDevice(devices *input.Manager) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		if err := devices.Close(r.PathParam("id")); err != nil {
+			if err == input.ErrDeviceNotFound {
+				rest.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				rest.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
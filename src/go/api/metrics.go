@@ -0,0 +1,110 @@
+package api
+
+import (
+	"sort"
+
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/metrics"
+)
+
+func (s *Server) AddMetrics(registry *metrics.Registry) {
+	s.Handle("/metrics", registry.Handler())
+}
+
+// Statistiques de réception des capteurs
+func DeviceMetrics(devices *input.Manager) metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		connected := metrics.NewGauge("violin_serial_connected",
+			"Whether the serial device is connected")
+		frames := metrics.NewCounter("violin_serial_frames_total",
+			"Valid frames received from the serial device")
+		crcErrors := metrics.NewCounter("violin_serial_crc_errors_total",
+			"Frames rejected because of an invalid CRC")
+		invalidFrames := metrics.NewCounter("violin_serial_invalid_frames_total",
+			"Frames rejected because of an invalid length")
+		frameRate := metrics.NewGauge("violin_serial_frame_rate",
+			"Frames received per second")
+
+		for _, status := range devices.Status() {
+
+			value := 0.0
+			if status.Connected {
+				value = 1
+			}
+
+			connected.Add(value, "device", status.Id)
+			frames.Add(float64(status.Frames), "device", status.Id)
+			crcErrors.Add(float64(status.CrcErrors), "device", status.Id)
+			invalidFrames.Add(float64(status.InvalidFrames), "device", status.Id)
+			frameRate.Add(status.FrameRate, "device", status.Id)
+		}
+
+		return []*metrics.Metric{connected, frames, crcErrors, invalidFrames, frameRate}
+	}
+}
+
+// Evènements publiés, perdus par type d'abonné & nombre de clients
+// connectés. Les clients étant identifiés par leur adresse, seul leur type
+// sert d'étiquette pour que le nombre de séries reste borné.
+func HubMetrics(hub *events.Hub) metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		stats := hub.Stats()
+
+		published := metrics.NewCounter("violin_events_published_total",
+			"Events published on the hub").Add(float64(stats.Published))
+		dropped := metrics.NewCounter("violin_events_dropped_total",
+			"Events dropped because a subscriber was too slow").Add(float64(stats.Dropped))
+		subscriberDropped := metrics.NewCounter("violin_subscriber_dropped_total",
+			"Events dropped per kind of subscriber")
+		subscriberPending := metrics.NewGauge("violin_subscriber_pending",
+			"Events waiting to be consumed per kind of subscriber")
+		clients := metrics.NewGauge("violin_stream_clients",
+			"Connected stream clients")
+
+		pending := make(map[string]int)
+		count := map[string]int{STREAM_CLIENT: 0, WS_CLIENT: 0}
+
+		for _, subscriber := range stats.Subscribers {
+
+			pending[subscriber.Kind] += subscriber.Pending
+
+			if _, ok := count[subscriber.Kind]; ok {
+				count[subscriber.Kind]++
+			}
+		}
+
+		// Ordre stable des séries d'une collecte à l'autre
+		kinds := make([]string, 0, len(stats.DroppedByKind))
+		for kind := range stats.DroppedByKind {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+
+		for _, kind := range kinds {
+			subscriberDropped.Add(float64(stats.DroppedByKind[kind]), "kind", kind)
+		}
+
+		kinds = kinds[:0]
+		for kind := range pending {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+
+		for _, kind := range kinds {
+			subscriberPending.Add(float64(pending[kind]), "kind", kind)
+		}
+
+		for _, kind := range []string{STREAM_CLIENT, WS_CLIENT} {
+			clients.Add(float64(count[kind]), "protocol", kind)
+		}
+
+		return []*metrics.Metric{
+			published, dropped, subscriberDropped, subscriberPending, clients,
+		}
+	}
+}
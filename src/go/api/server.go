@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Seule la machine locale a accès au serveur par défaut
+const DEFAULT_ADDR = "localhost:5000"
+
+type Options struct {
+	// Adresse d'écoute du serveur
+	Addr string
+
+	// Répertoire des pages web, à défaut celles embarquées sont utilisées
+	WebDir string
+
+	// Origines autorisées à accéder à l'API depuis un navigateur ("*" pour
+	// toutes), en plus de celle du serveur
+	AllowedOrigins []string
+
+	// Jeton exigé sur les routes /api, /stream, /ws & /metrics
+	Token string
+}
+
+type Server struct {
+	options Options
+	mux     *http.ServeMux
+	server  *http.Server
+	shares  ShareValidator
+}
+
+func NewServer(options Options) *Server {
+
+	if options.Addr == "" {
+		options.Addr = DEFAULT_ADDR
+	}
+
+	s := &Server{
+		options: options,
+		mux:     http.NewServeMux(),
+	}
+
+	s.server = &http.Server{
+		Addr:    options.Addr,
+		Handler: s.handler(),
+	}
+
+	return s
+}
+
+// Autorise l'accès aux sessions partagées
+func (s *Server) SetShares(shares ShareValidator) {
+	s.shares = shares
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ListenAndServe() error {
+
+	log.Printf("Listening %s ...", s.options.Addr)
+
+	if s.options.Token == "" && strings.HasPrefix(s.options.Addr, ":") {
+		log.Printf("WARNING: listening on all interfaces without token")
+	}
+
+	err := s.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// Arrête le serveur en laissant les requêtes en cours se terminer
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Gestionnaire complet des requêtes, CORS & authentification compris
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ohohleo/violin/sessions"
+)
+
+func ListSessions(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		list, err := store.List()
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteJson(list)
+	}
+}
+
+// Démarre une session : le corps de la requête contient ses informations
+func StartSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		var metadata sessions.Metadata
+
+		if r.ContentLength != 0 {
+			if err := r.DecodeJsonPayload(&metadata); err != nil {
+				rest.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		session, err := store.Start(metadata)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.WriteJson(session)
+	}
+}
+
+func GetSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		session, err := store.Get(r.PathParam("id"))
+		if err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.WriteJson(session)
+	}
+}
+
+func StopSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		session, err := store.Stop(r.PathParam("id"))
+		if err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.WriteJson(session)
+	}
+}
+
+// Télécharge la session au format demandé via le paramètre "format"
+func DownloadSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		id := r.PathParam("id")
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = sessions.FORMAT_JSONL
+		}
+
+		contentType, ok := sessions.FORMATS[format]
+		if ok == false {
+			rest.Error(w, fmt.Sprintf("unsupported format '%s'", format),
+				http.StatusBadRequest)
+			return
+		}
+
+		if _, err := store.Get(id); err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"%s.%s\"", id, format))
+
+		// Les entêtes sont déjà partis : l'erreur ne peut qu'être tracée
+		if err := store.Export(id, format, w.(http.ResponseWriter)); err != nil {
+			log.Printf("download session %s: %s", id, err)
+		}
+	}
+}
+
+func DeleteSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		if err := store.Delete(r.PathParam("id")); err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func sessionError(w rest.ResponseWriter, err error) {
+
+	switch err {
+	case sessions.ErrNotFound:
+		rest.Error(w, err.Error(), http.StatusNotFound)
+	case sessions.ErrNotRecording:
+		rest.Error(w, err.Error(), http.StatusConflict)
+	default:
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Génère le lien de partage donnant accès en lecture à la session
+func ShareSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		session, err := store.Share(r.PathParam("id"))
+		if err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.WriteJson(map[string]interface{}{
+			"session": session,
+			"url":     "/?" + SHARE_PARAM + "=" + session.Share,
+		})
+	}
+}
+
+func UnshareSession(store *sessions.Store) rest.HandlerFunc {
+
+	return func(w rest.ResponseWriter, r *rest.Request) {
+
+		session, err := store.Unshare(r.PathParam("id"))
+		if err != nil {
+			sessionError(w, err)
+			return
+		}
+
+		w.WriteJson(session)
+	}
+}
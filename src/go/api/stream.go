@@ -1,14 +1,28 @@
 package api
 
 import (
-	//"fmt"
-	"github.com/ant0ine/go-json-rest/rest"
-	"github.com/ohohleo/violin/input"
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ohohleo/violin/events"
+)
+
+const (
+	STREAM_BUFFER    = 256
+	STREAM_KEEPALIVE = 15 * time.Second
+	STREAM_RETRY     = 2000
+
+	// Préfixe du nom des abonnés
+	STREAM_CLIENT = "sse"
 )
 
-func NewStream(ch chan input.AccelGyro) error {
+func (s *Server) AddStream(hub *events.Hub) error {
 
 	// Mise en place de l'API Stream
 	apiStream := rest.NewApi()
@@ -17,7 +31,11 @@ func NewStream(ch chan input.AccelGyro) error {
 	apiStream.Use(rest.DefaultDevStack...)
 
 	stream, err := rest.MakeRouter(
-		rest.Get("/accelerometer", Stream(ch)),
+		rest.Get("/accelerometer",
+			Stream(hub, events.SAMPLE, events.STATUS, events.STROKE)),
+		rest.Get("/audio",
+			Stream(hub, events.PITCH, events.LEVEL, events.SPECTRUM)),
+		rest.Get("/events", Stream(hub)),
 	)
 
 	if err != nil {
@@ -26,30 +44,132 @@ func NewStream(ch chan input.AccelGyro) error {
 
 	apiStream.SetApp(stream)
 
-	http.Handle("/stream/", http.StripPrefix("/stream", apiStream.MakeHandler()))
+	s.Handle("/stream/", http.StripPrefix("/stream", apiStream.MakeHandler()))
 
 	return nil
 }
 
-func Stream(ch chan input.AccelGyro) func(w rest.ResponseWriter, r *rest.Request) {
+// Diffuse les évènements du hub au format Server-Sent Events : chaque client
+// dispose de son propre abonnement, libéré à la fin de la requête. Le
+// paramètre "types" permet de restreindre les types d'évènements reçus.
+func Stream(hub *events.Hub, types ...string) rest.HandlerFunc {
 
 	return func(w rest.ResponseWriter, r *rest.Request) {
 
-		w.(http.ResponseWriter).Header().Set("Content-Type", "text/event-stream")
+		writer := w.(http.ResponseWriter)
+
+		flusher, ok := w.(http.Flusher)
+		if ok == false {
+			rest.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Reprise après une déconnexion
+		lastId, err := lastEventId(r)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		subscriber := hub.SubscribeAs(STREAM_CLIENT+":"+r.RemoteAddr,
+			STREAM_BUFFER, lastId, filterTypes(r, types)...)
+		defer subscriber.Close()
 
-		w.(http.ResponseWriter).Write([]byte("data:it works!\n\n"))
-		w.(http.Flusher).Flush()
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.Header().Set("Connection", "keep-alive")
+		writer.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(writer, "retry: %d\n\n", STREAM_RETRY)
+		flusher.Flush()
+
+		keepalive := time.NewTicker(STREAM_KEEPALIVE)
+		defer keepalive.Stop()
 
 		for {
+			select {
+
+			// Le client s'est déconnecté
+			case <-r.Context().Done():
+				return
 
-			accelerometer := <-ch
+			case event, ok := <-subscriber.C:
+				if ok == false {
+					return
+				}
 
-			log.Printf("%+v", accelerometer)
+				if err := writeEvent(writer, event); err != nil {
+					return
+				}
 
-			w.(http.ResponseWriter).Write([]byte("data:" + accelerometer.String() + "\n\n"))
+			case <-keepalive.C:
+				if _, err := io.WriteString(writer, ": keepalive\n\n"); err != nil {
+					return
+				}
+			}
 
 			// Flush the buffer to client
-			w.(http.Flusher).Flush()
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event *events.Event) error {
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n",
+		event.Id, event.Type, data)
+
+	return err
+}
+
+func lastEventId(r *rest.Request) (uint64, error) {
+
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last event id '%s'", value)
+	}
+
+	return id, nil
+}
+
+// Restreint les types demandés par le client à ceux autorisés
+func filterTypes(r *rest.Request, types []string) []string {
+
+	query := r.URL.Query().Get("types")
+	if query == "" {
+		return types
+	}
+
+	allowed := make(map[string]bool, len(types))
+	for _, eventType := range types {
+		allowed[eventType] = true
+	}
+
+	var result []string
+	for _, eventType := range strings.Split(query, ",") {
+		if len(types) == 0 || allowed[eventType] {
+			result = append(result, eventType)
 		}
 	}
+
+	// Aucun type valide : on ne filtre que sur les types autorisés
+	if len(result) == 0 {
+		return types
+	}
+
+	return result
 }
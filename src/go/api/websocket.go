@@ -0,0 +1,259 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ohohleo/violin/control"
+	"github.com/ohohleo/violin/events"
+)
+
+// Protocole WebSocket (/ws)
+//
+// Chaque message est un objet JSON dont le champ "type" indique la nature.
+//
+// Client => serveur :
+//
+//	{"type": "command", "id": 1, "command": "tare", "args": []}
+//	{"type": "subscribe", "types": ["sample", "stroke"]}
+//	{"type": "ping", "id": 2}
+//
+// Serveur => client :
+//
+//	{"type": "event", "id": 42, "event": "sample", "time": "...", "data": {...}}
+//	{"type": "response", "id": 1, "result": ...}
+//	{"type": "error", "id": 1, "error": "unknown command 'foo'"}
+//	{"type": "pong", "id": 2}
+//	{"type": "heartbeat", "time": "...", "dropped": 0}
+//
+// Les commandes sont celles de control.Commands (tare, output.mask,
+// tuner.reference...). output.mask ne retire que des champs des valeurs
+// reçues : les sorties du firmware sont choisies à sa compilation
+// (OUTPUT_*) & ne peuvent pas être changées à distance.
+//
+// Les réponses reprennent l'identifiant de la requête. Le serveur envoie un
+// ping WebSocket & un message "heartbeat" toutes les WS_PING_PERIOD : le
+// client qui ne répond pas dans WS_PONG_WAIT est déconnecté. Lorsque le
+// client ne consomme pas assez vite, les évènements de télémétrie sont
+// perdus (comptés dans "dropped") mais les réponses ne le sont jamais : si
+// leur file d'attente est pleine, la connexion est fermée.
+
+const (
+	WS_WRITE_WAIT   = 10 * time.Second
+	WS_PONG_WAIT    = 60 * time.Second
+	WS_PING_PERIOD  = WS_PONG_WAIT * 9 / 10
+	WS_MAX_MESSAGE  = 4096
+	WS_EVENT_BUFFER = 256
+	WS_QUEUE        = 32
+
+	// Préfixe du nom des abonnés
+	WS_CLIENT = "websocket"
+
+	WS_COMMAND   = "command"
+	WS_SUBSCRIBE = "subscribe"
+	WS_PING      = "ping"
+
+	WS_EVENT     = "event"
+	WS_RESPONSE  = "response"
+	WS_ERROR     = "error"
+	WS_PONG      = "pong"
+	WS_HEARTBEAT = "heartbeat"
+)
+
+type Message struct {
+	Type    string        `json:"type"`
+	Id      uint64        `json:"id,omitempty"`
+	Event   string        `json:"event,omitempty"`
+	Time    *time.Time    `json:"time,omitempty"`
+	Command string        `json:"command,omitempty"`
+	Args    []interface{} `json:"args,omitempty"`
+	Types   []string      `json:"types,omitempty"`
+	Result  interface{}   `json:"result,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Data    interface{}   `json:"data,omitempty"`
+	Dropped uint64        `json:"dropped,omitempty"`
+}
+
+type wsClient struct {
+	conn      *websocket.Conn
+	hub       *events.Hub
+	commands  *control.Commands
+	queue     chan *Message
+	subscribe chan []string
+	done      chan struct{}
+}
+
+func (s *Server) AddWebSocket(hub *events.Hub, commands *control.Commands) error {
+
+	s.Handle("/ws", WebSocket(hub, commands, s.checkOrigin))
+
+	return nil
+}
+
+func WebSocket(hub *events.Hub, commands *control.Commands, checkOrigin func(*http.Request) bool) http.HandlerFunc {
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     checkOrigin,
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("websocket: %s", err)
+			return
+		}
+
+		c := &wsClient{
+			conn:      conn,
+			hub:       hub,
+			commands:  commands,
+			queue:     make(chan *Message, WS_QUEUE),
+			subscribe: make(chan []string, 1),
+			done:      make(chan struct{}),
+		}
+
+		go c.read()
+		c.write()
+	}
+}
+
+// Lecture des messages provenant du client
+func (c *wsClient) read() {
+
+	defer close(c.done)
+
+	c.conn.SetReadLimit(WS_MAX_MESSAGE)
+	c.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	})
+
+	for {
+		var request Message
+
+		if err := c.conn.ReadJSON(&request); err != nil {
+			if websocket.IsUnexpectedCloseError(err,
+				websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("websocket: %s", err)
+			}
+			return
+		}
+
+		switch request.Type {
+
+		case WS_COMMAND:
+			result, err := c.commands.Execute(request.Command, request.Args)
+			if err != nil {
+				c.send(&Message{Type: WS_ERROR, Id: request.Id, Error: err.Error()})
+				continue
+			}
+
+			c.send(&Message{Type: WS_RESPONSE, Id: request.Id, Result: result})
+
+		case WS_SUBSCRIBE:
+			// Seul le dernier abonnement demandé est pris en compte
+			select {
+			case <-c.subscribe:
+			default:
+			}
+			c.subscribe <- request.Types
+
+			c.send(&Message{Type: WS_RESPONSE, Id: request.Id, Result: request.Types})
+
+		case WS_PING:
+			c.send(&Message{Type: WS_PONG, Id: request.Id})
+
+		default:
+			c.send(&Message{Type: WS_ERROR, Id: request.Id,
+				Error: "unknown message type '" + request.Type + "'"})
+		}
+	}
+}
+
+// Les réponses ne sont jamais perdues : un client qui ne les lit pas est
+// déconnecté
+func (c *wsClient) send(message *Message) {
+	select {
+	case c.queue <- message:
+	default:
+		log.Printf("websocket: response queue full, closing %s",
+			c.conn.RemoteAddr())
+		c.conn.Close()
+	}
+}
+
+// Ecriture des messages vers le client
+func (c *wsClient) write() {
+
+	name := WS_CLIENT + ":" + c.conn.RemoteAddr().String()
+	subscriber := c.hub.SubscribeAs(name, WS_EVENT_BUFFER, 0)
+
+	ticker := time.NewTicker(WS_PING_PERIOD)
+
+	defer func() {
+		ticker.Stop()
+		subscriber.Close()
+		c.conn.Close()
+	}()
+
+	var dropped uint64
+
+	for {
+		var message *Message
+
+		select {
+
+		case <-c.done:
+			c.writeControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+
+		case types := <-c.subscribe:
+			dropped += subscriber.Dropped()
+			subscriber.Close()
+			subscriber = c.hub.SubscribeAs(name, WS_EVENT_BUFFER, 0, types...)
+			continue
+
+		case message = <-c.queue:
+
+		case event, ok := <-subscriber.C:
+			if ok == false {
+				return
+			}
+
+			message = &Message{
+				Type:  WS_EVENT,
+				Id:    event.Id,
+				Event: event.Type,
+				Time:  &event.Time,
+				Data:  event.Data,
+			}
+
+		case <-ticker.C:
+			if err := c.writeControl(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+			now := time.Now()
+			message = &Message{
+				Type:    WS_HEARTBEAT,
+				Time:    &now,
+				Dropped: dropped + subscriber.Dropped(),
+			}
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_WAIT))
+		if err := c.conn.WriteJSON(message); err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsClient) writeControl(messageType int, data []byte) error {
+	return c.conn.WriteControl(messageType, data, time.Now().Add(WS_WRITE_WAIT))
+}
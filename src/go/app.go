@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ohohleo/violin/api"
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/control"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/jack/sound"
+	"github.com/ohohleo/violin/metrics"
+	"github.com/ohohleo/violin/midi"
+	"github.com/ohohleo/violin/mqtt"
+	"github.com/ohohleo/violin/osc"
+	"github.com/ohohleo/violin/sessions"
+	"github.com/ohohleo/violin/supervisor"
+)
+
+// Composants partagés par les modes : tous les évènements transitent par le
+// hub, les sorties (OSC, MQTT, MIDI) étant indépendantes des modes
+type app struct {
+	options *options
+	modes   map[string]bool
+
+	hub      *events.Hub
+	filter   *input.Filter
+	tuner    *audio.Tuner
+	store    *sessions.Store
+	devices  *input.Manager
+	commands *control.Commands
+	registry *metrics.Registry
+
+	supervisor *supervisor.Supervisor
+	sound      *sound.SoundManager
+
+	// Identifiant des capteurs déconnectés
+	lost chan string
+}
+
+// Noms des composants autres que les modes
+const (
+	DEVICES    = "devices"
+	OSC        = "osc"
+	OSC_SERVER = "osc-server"
+	MQTT       = "mqtt"
+	MIDI       = "midi"
+)
+
+func newApp(o *options, modes map[string]bool) (a *app, err error) {
+
+	a = &app{
+		options:    o,
+		modes:      modes,
+		hub:        events.NewHub(events.DEFAULT_HISTORY),
+		filter:     input.NewFilter(),
+		registry:   metrics.NewRegistry(),
+		supervisor: supervisor.New(),
+		lost:       make(chan string, 1),
+	}
+
+	if a.tuner, err = audio.NewTuner(o.Audio.Reference); err != nil {
+		return
+	}
+
+	if a.store, err = sessions.NewStore(o.Sessions.Dir, a.hub, a.tuner); err != nil {
+		return
+	}
+
+	if err = a.loadCalibration(); err != nil {
+		return
+	}
+
+	// Les valeurs sont affichées par l'affichage tête haute de view3d
+	verbose := o.Verbose && (modes[VIEW3D] == false || o.Display.View3D.Hud.Enabled == false)
+
+	a.devices = newDevices(a.hub, a.filter, verbose)
+	a.commands = newCommands(a.hub, a.filter, a.tuner, a.store)
+
+	// Seules les pertes de connexion sont signalées, pas les fermetures
+	// demandées
+	onClose := a.devices.OnClose
+	a.devices.OnClose = func(device *input.Device) {
+		onClose(device)
+
+		if device.Status().Error == "" {
+			return
+		}
+
+		select {
+		case a.lost <- device.Id:
+		default:
+		}
+	}
+
+	a.registry.Register(api.DeviceMetrics(a.devices))
+	a.registry.Register(api.HubMetrics(a.hub))
+	a.registry.Register(a.supervisor.Metrics())
+
+	return
+}
+
+// Composants des modes demandés : les sorties & les modes consommant les
+// évènements sont démarrés avant les capteurs & le client JACK qui les
+// produisent, et arrêtés après eux pour recevoir les derniers évènements
+func (a *app) addComponents(sensors, sound, finite bool) (err error) {
+
+	var consumers, producers []supervisor.Component
+
+	consumers = append(consumers, a.outputs()...)
+
+	if a.modes[RECORD] {
+		consumers = append(consumers, a.record())
+	}
+
+	if a.modes[TUNE] {
+		consumers = append(consumers, a.tune())
+	}
+
+	if sensors {
+		producers = append(producers, a.sensors())
+	}
+
+	if sound {
+		producers = append(producers, a.audio())
+	}
+
+	for idx := range producers {
+		for _, consumer := range consumers {
+			producers[idx].Requires = append(producers[idx].Requires, consumer.Name)
+		}
+	}
+
+	// Modes utilisant les capteurs une fois ceux-ci démarrés
+	var users []supervisor.Component
+
+	if a.modes[SERVE] {
+		users = append(users, a.serve())
+	}
+
+	if a.modes[VIEW3D] {
+		users = append(users, a.view3d())
+	}
+
+	if a.modes[REPLAY] {
+		users = append(users, a.replay())
+	}
+
+	if a.modes[CALIBRATE] {
+		users = append(users, a.calibrate())
+	}
+
+	for idx := range users {
+
+		if sensors && users[idx].Name != REPLAY {
+			users[idx].Requires = append(users[idx].Requires, DEVICES)
+		}
+
+		// Les modes finis n'arrêtent l'application que s'ils sont seuls
+		users[idx].Finite = finite && users[idx].Finite
+	}
+
+	for _, list := range [][]supervisor.Component{consumers, producers, users} {
+		for _, component := range list {
+			if err = a.supervisor.Add(component); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// Ouvre les capteurs configurés puis attend une déconnexion pour les
+// rouvrir, le superviseur espaçant les tentatives
+func (a *app) sensors() supervisor.Component {
+	return supervisor.Component{
+		Name:    DEVICES,
+		Restart: true,
+		Run: func(ctx context.Context) error {
+
+			if err := a.openDevices(); err != nil {
+				return err
+			}
+
+			select {
+			case id := <-a.lost:
+				return fmt.Errorf("device %s disconnected", id)
+			case <-ctx.Done():
+				return nil
+			}
+		},
+		Stop: func() error {
+			a.devices.CloseAll()
+			return nil
+		},
+	}
+}
+
+// Ouvre les capteurs configurés qui ne sont pas connectés, sauf ceux fermés
+// par l'API
+func (a *app) openDevices() error {
+
+	var failed []string
+
+	for _, config := range a.options.Devices {
+
+		id := filepath.Base(config.Port)
+
+		if a.devices.Closed(id) {
+			continue
+		}
+
+		// Un capteur déconnecté est remplacé par Open
+		if device, err := a.devices.Get(id); err == nil && device.Status().Connected {
+			continue
+		}
+
+		if _, err := a.devices.Open(config.Port, config.Baudrate, config.Protocol); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// Client JACK publiant l'analyse audio sur le hub, sur le thread principal
+// lorsque les graphes sont affichés
+func (a *app) audio() supervisor.Component {
+
+	c := a.options.Config
+
+	a.sound = sound.NewSoundManager(sound.Options{
+		Display:          c.Display.Graphs.Enabled,
+		Width:            c.Display.Graphs.Width,
+		Height:           c.Display.Graphs.Height,
+		Font:             c.Display.Graphs.Font,
+		MinLevel:         c.Audio.MinLevel,
+		MinClarity:       c.Audio.MinClarity,
+		SpectrumInterval: c.Audio.SpectrumInterval.Duration,
+	})
+	a.sound.SetAnalysis(a.hub, a.tuner)
+
+	a.registry.Register(a.sound.Metrics())
+
+	a.commands.Register("display.graph",
+		"show only one graph [all|waveform|spectrum|spectrogram]",
+		func(args []interface{}) (interface{}, error) {
+			kind, err := control.String(args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			return kind, a.sound.ShowGraph(kind)
+		})
+
+	return supervisor.Component{
+		Name:    AUDIO,
+		Restart: true,
+		Main:    c.Display.Graphs.Enabled,
+		Run: func(ctx context.Context) error {
+			return a.sound.Run(ctx, c.Audio.Client, c.Audio.LinksMap())
+		},
+	}
+}
+
+// Sorties & entrées externes activées par les options
+func (a *app) outputs() (components []supervisor.Component) {
+
+	o := a.options
+
+	if len(o.Osc.Targets) > 0 {
+
+		var publisher *osc.Publisher
+
+		components = append(components, supervisor.Component{
+			Name: OSC,
+			Start: func(ctx context.Context) error {
+
+				sender, err := osc.NewSender(osc.Options{
+					Targets: o.Osc.Targets,
+					Bundle:  o.Osc.Bundle,
+					Rate:    o.Osc.Rate,
+					Prefix:  o.Osc.Prefix,
+					Mapping: o.Osc.Mapping,
+				})
+
+				if err != nil {
+					return err
+				}
+
+				publisher = osc.NewPublisher(a.hub, sender, o.Bow())
+				a.registry.Register(publisher.Metrics())
+
+				return nil
+			},
+			Stop: func() error {
+				return publisher.Close()
+			},
+		})
+	}
+
+	if o.Osc.Listen != "" {
+
+		var server *osc.Server
+
+		components = append(components, supervisor.Component{
+			Name: OSC_SERVER,
+			Start: func(ctx context.Context) (err error) {
+
+				if server, err = osc.NewServer(o.Osc.Listen, a.commands); err != nil {
+					return
+				}
+
+				server.ReplyPort = o.Osc.ReplyPort
+				server.Token = o.Osc.Token
+
+				if server.Allow, err = osc.ParseAllow(o.Osc.Allow); err != nil {
+					server.Close()
+					return
+				}
+
+				if server.Token == "" && len(server.Allow) == 0 && strings.HasPrefix(o.Osc.Listen, ":") {
+					log.Printf("WARNING: OSC commands accepted from all interfaces without token")
+				}
+
+				a.registry.Register(server.Metrics())
+
+				return
+			},
+			Run: func(ctx context.Context) error {
+
+				go func() {
+					<-ctx.Done()
+					server.Close()
+				}()
+
+				return server.Serve()
+			},
+		})
+	}
+
+	if o.Mqtt.Broker != "" {
+
+		var publisher *mqtt.Publisher
+
+		components = append(components, supervisor.Component{
+			Name: MQTT,
+			Start: func(ctx context.Context) (err error) {
+
+				publisher, err = mqtt.NewPublisher(a.hub, mqtt.Options{
+					Broker:    o.Mqtt.Broker,
+					ClientId:  o.Mqtt.ClientId,
+					Username:  o.Mqtt.Username,
+					Password:  o.Mqtt.Password,
+					Prefix:    o.Mqtt.Prefix,
+					Topics:    o.Mqtt.Topics,
+					QoS:       byte(o.Mqtt.QoS),
+					StatusQoS: 1,
+				})
+
+				if err != nil {
+					return
+				}
+
+				publisher.SetDevices(a.devices)
+				a.registry.Register(publisher.Metrics())
+
+				return
+			},
+			Stop: func() error {
+				publisher.Close()
+				return nil
+			},
+		})
+	}
+
+	if o.Midi != "" {
+
+		var recorder *midi.Recorder
+
+		components = append(components, supervisor.Component{
+			Name: MIDI,
+			Start: func(ctx context.Context) error {
+				recorder = midi.NewRecorder(a.hub, o.Midi, o.Bow())
+				return nil
+			},
+			Stop: func() error {
+				return recorder.Stop()
+			},
+		})
+	}
+
+	return
+}
+
+// Orientation de référence enregistrée par le mode calibrate
+func (a *app) loadCalibration() error {
+
+	if a.options.Calibration == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(a.options.Calibration)
+	if err != nil {
+		// Fichier pas encore créé par la calibration
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var reference input.AccelGyro
+	if err = json.Unmarshal(data, &reference); err != nil {
+		return fmt.Errorf("calibration %s: %s", a.options.Calibration, err)
+	}
+
+	a.filter.SetReference(&reference)
+	return nil
+}
+
+func (a *app) saveCalibration(reference *input.AccelGyro) error {
+
+	data, err := json.MarshalIndent(reference, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(a.options.Calibration, data, 0644)
+}
+
+// Les valeurs des capteurs sont filtrées puis diffusées sur le hub
+func newDevices(hub *events.Hub, filter *input.Filter, verbose bool) *input.Manager {
+
+	devices := input.NewManager()
+
+	var mutex sync.Mutex
+	strokes := make(map[string]*input.StrokeDetector)
+
+	devices.OnSample = func(device *input.Device, values *input.AccelGyro) {
+
+		values = filter.Apply(values)
+		if verbose {
+			fmt.Printf("%s", values)
+		}
+
+		hub.Publish(events.SAMPLE, values)
+
+		mutex.Lock()
+		detector, ok := strokes[device.Id]
+		if ok == false {
+			detector = input.NewStrokeDetector()
+			strokes[device.Id] = detector
+		}
+		stroke := detector.Update(values, time.Now())
+		mutex.Unlock()
+
+		if stroke != nil {
+			hub.Publish(events.STROKE, stroke)
+		}
+	}
+
+	devices.OnInit = func(device *input.Device, step string, value int) {
+		hub.Publish(events.STATUS, map[string]interface{}{
+			"device": device.Id,
+			"init":   step,
+			"value":  value,
+		})
+	}
+
+	devices.OnOpen = func(device *input.Device) {
+		hub.Publish(events.STATUS, device.Status())
+	}
+
+	devices.OnClose = func(device *input.Device) {
+		hub.Publish(events.STATUS, device.Status())
+	}
+
+	return devices
+}
@@ -0,0 +1,267 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+const (
+	DEFAULT_SAMPLE_RATE = 44100
+
+	// Taille de la fenêtre utilisée pour la détection de la hauteur
+	PITCH_WINDOW = 2048
+
+	// Tessiture du violon (Sol grave 196 Hz) avec de la marge
+	MIN_PITCH = 150.0
+	MAX_PITCH = 4000.0
+
+	// Seuils en dessous desquels aucune note n'est détectée
+	MIN_LEVEL   = -50.0
+	MIN_CLARITY = 0.8
+
+	// Nombre de bandes du spectre réduit, réparties logarithmiquement
+	SPECTRUM_BANDS   = 128
+	SPECTRUM_MIN     = 50.0
+	SPECTRUM_MAX     = 10000.0
+	SPECTRUM_MIN_DB  = -120.0
+	SILENCE_LEVEL_DB = -120.0
+)
+
+type Pitch struct {
+	Frequency float64 `json:"frequency"`
+	Clarity   float64 `json:"clarity"`
+	Note      *Note   `json:"note,omitempty"`
+}
+
+type Level struct {
+	Rms  float64 `json:"rms"`
+	Peak float64 `json:"peak"`
+	Db   float64 `json:"db"`
+}
+
+type Spectrum struct {
+	MinFrequency float64   `json:"min_frequency"`
+	MaxFrequency float64   `json:"max_frequency"`
+	Bands        []float32 `json:"bands"`
+}
+
+type Analysis struct {
+	Time     time.Time `json:"time"`
+	Level    Level     `json:"level"`
+	Pitch    *Pitch    `json:"pitch,omitempty"`
+	Spectrum *Spectrum `json:"spectrum,omitempty"`
+}
+
+// Analyse le signal audio période par période : niveau, hauteur de la note
+// jouée & écart par rapport à l'accordeur, spectre réduit
+type Analyzer struct {
+	SampleRate float64
+	Tuner      *Tuner
+
+	// Seuils de détection, MIN_LEVEL & MIN_CLARITY par défaut
+	MinLevel   float64
+	MinClarity float64
+
+	window []float64
+	filled int
+}
+
+func NewAnalyzer(sampleRate float64, tuner *Tuner) *Analyzer {
+
+	if sampleRate <= 0 {
+		sampleRate = DEFAULT_SAMPLE_RATE
+	}
+
+	return &Analyzer{
+		SampleRate: sampleRate,
+		Tuner:      tuner,
+		MinLevel:   MIN_LEVEL,
+		MinClarity: MIN_CLARITY,
+		window:     make([]float64, PITCH_WINDOW),
+	}
+}
+
+// Analyse une nouvelle période d'échantillons & sa FFT (optionnelle)
+func (a *Analyzer) Process(samples []float32, fft []complex128) *Analysis {
+
+	analysis := &Analysis{
+		Time:  time.Now(),
+		Level: ComputeLevel(samples),
+	}
+
+	// Fenêtre glissante des derniers échantillons
+	if len(samples) >= len(a.window) {
+		samples = samples[len(samples)-len(a.window):]
+	}
+
+	copy(a.window, a.window[len(samples):])
+	for idx, sample := range samples {
+		a.window[len(a.window)-len(samples)+idx] = float64(sample)
+	}
+
+	if a.filled < len(a.window) {
+		a.filled += len(samples)
+	}
+
+	if a.filled >= len(a.window) && analysis.Level.Db > a.MinLevel {
+
+		frequency, clarity := DetectPitch(a.window, a.SampleRate)
+
+		if frequency > 0 && clarity >= a.MinClarity {
+
+			analysis.Pitch = &Pitch{
+				Frequency: frequency,
+				Clarity:   clarity,
+			}
+
+			if a.Tuner != nil {
+				if note, ok := a.Tuner.Note(frequency); ok {
+					analysis.Pitch.Note = &note
+				}
+			}
+		}
+	}
+
+	if len(fft) > 0 {
+		analysis.Spectrum = ReduceSpectrum(fft, a.SampleRate, SPECTRUM_BANDS)
+	}
+
+	return analysis
+}
+
+func ComputeLevel(samples []float32) (level Level) {
+
+	level.Db = SILENCE_LEVEL_DB
+
+	if len(samples) == 0 {
+		return
+	}
+
+	var sum float64
+	for _, sample := range samples {
+		value := float64(sample)
+		sum += value * value
+
+		if math.Abs(value) > level.Peak {
+			level.Peak = math.Abs(value)
+		}
+	}
+
+	level.Rms = math.Sqrt(sum / float64(len(samples)))
+
+	if level.Rms > 0 {
+		level.Db = math.Max(SILENCE_LEVEL_DB, 20*math.Log10(level.Rms))
+	}
+
+	return
+}
+
+// Détection de la fréquence fondamentale par autocorrélation normalisée
+// (méthode de McLeod) : retourne la fréquence & la clarté entre 0 et 1
+func DetectPitch(window []float64, sampleRate float64) (frequency float64, clarity float64) {
+
+	minLag := int(sampleRate / MAX_PITCH)
+	maxLag := int(sampleRate / MIN_PITCH)
+	if maxLag >= len(window) {
+		maxLag = len(window) - 1
+	}
+
+	if minLag < 1 || minLag >= maxLag {
+		return
+	}
+
+	nsdf := make([]float64, maxLag+1)
+
+	for lag := minLag; lag <= maxLag; lag++ {
+
+		var acf, energy float64
+		for idx := 0; idx+lag < len(window); idx++ {
+			acf += window[idx] * window[idx+lag]
+			energy += window[idx]*window[idx] + window[idx+lag]*window[idx+lag]
+		}
+
+		if energy > 0 {
+			nsdf[lag] = 2 * acf / energy
+		}
+	}
+
+	// Recherche des maxima locaux entre deux passages par zéro positifs
+	var peaks []int
+	var best float64
+
+	for lag := minLag + 1; lag < maxLag; lag++ {
+		if nsdf[lag] > 0 && nsdf[lag] >= nsdf[lag-1] && nsdf[lag] > nsdf[lag+1] {
+			peaks = append(peaks, lag)
+			if nsdf[lag] > best {
+				best = nsdf[lag]
+			}
+		}
+	}
+
+	if best <= 0 {
+		return
+	}
+
+	// Premier pic suffisamment proche du maximum : évite les sous-harmoniques
+	for _, lag := range peaks {
+
+		if nsdf[lag] < 0.9*best {
+			continue
+		}
+
+		// Interpolation parabolique autour du pic
+		previous, next := nsdf[lag-1], nsdf[lag+1]
+		shift := 0.0
+		if denominator := previous - 2*nsdf[lag] + next; denominator != 0 {
+			shift = 0.5 * (previous - next) / denominator
+		}
+
+		return sampleRate / (float64(lag) + shift), nsdf[lag]
+	}
+
+	return
+}
+
+// Réduit le spectre à un nombre de bandes réparties logarithmiquement, en dB
+func ReduceSpectrum(fft []complex128, sampleRate float64, bands int) *Spectrum {
+
+	spectrum := &Spectrum{
+		MinFrequency: SPECTRUM_MIN,
+		MaxFrequency: math.Min(SPECTRUM_MAX, sampleRate/2),
+		Bands:        make([]float32, bands),
+	}
+
+	binWidth := sampleRate / float64(len(fft))
+	ratio := spectrum.MaxFrequency / spectrum.MinFrequency
+
+	for band := 0; band < bands; band++ {
+
+		low := spectrum.MinFrequency * math.Pow(ratio, float64(band)/float64(bands))
+		high := spectrum.MinFrequency * math.Pow(ratio, float64(band+1)/float64(bands))
+
+		first := int(low / binWidth)
+		last := int(math.Ceil(high / binWidth))
+		if last > len(fft)/2 {
+			last = len(fft) / 2
+		}
+
+		if last <= first {
+			last = first + 1
+		}
+
+		var magnitude float64
+		for idx := first; idx < last && idx < len(fft); idx++ {
+			magnitude = math.Max(magnitude, cmplx.Abs(fft[idx]))
+		}
+
+		db := SPECTRUM_MIN_DB
+		if magnitude > 0 {
+			db = math.Max(SPECTRUM_MIN_DB, 20*math.Log10(magnitude/float64(len(fft))))
+		}
+
+		spectrum.Bands[band] = float32(db)
+	}
+
+	return spectrum
+}
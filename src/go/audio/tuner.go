@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	DEFAULT_REFERENCE = 440.0
+	MIN_REFERENCE     = 400.0
+	MAX_REFERENCE     = 480.0
+
+	// Numéro MIDI du La de référence
+	REFERENCE_NOTE = 69
+)
+
+var NOTES = []string{
+	"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B",
+}
+
+type Note struct {
+	Name      string  `json:"name"`
+	Octave    int     `json:"octave"`
+	Midi      int     `json:"midi"`
+	Frequency float64 `json:"frequency"`
+	Cents     float64 `json:"cents"`
+}
+
+// Accordeur : associe une fréquence à la note la plus proche selon la
+// fréquence du La de référence
+type Tuner struct {
+	mutex     sync.RWMutex
+	reference float64
+}
+
+func NewTuner(reference float64) (t *Tuner, err error) {
+	t = new(Tuner)
+	err = t.SetReference(reference)
+	return
+}
+
+func (t *Tuner) SetReference(reference float64) error {
+
+	if reference < MIN_REFERENCE || reference > MAX_REFERENCE {
+		return fmt.Errorf("invalid tuning reference %.1f Hz (expect %.0f-%.0f Hz)",
+			reference, MIN_REFERENCE, MAX_REFERENCE)
+	}
+
+	t.mutex.Lock()
+	t.reference = reference
+	t.mutex.Unlock()
+
+	return nil
+}
+
+func (t *Tuner) Reference() float64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.reference
+}
+
+// Retourne la note la plus proche de la fréquence spécifiée & l'écart en cents
+func (t *Tuner) Note(frequency float64) (note Note, ok bool) {
+
+	if frequency <= 0 {
+		return
+	}
+
+	reference := t.Reference()
+
+	semitones := 12 * math.Log2(frequency/reference)
+	nearest := math.Round(semitones)
+
+	note.Midi = REFERENCE_NOTE + int(nearest)
+	if note.Midi < 0 {
+		return
+	}
+
+	note.Name = NOTES[note.Midi%12]
+	note.Octave = note.Midi/12 - 1
+	note.Frequency = t.Frequency(note.Midi)
+	note.Cents = 100 * (semitones - nearest)
+
+	return note, true
+}
+
+// Fréquence de la note MIDI spécifiée
+func (t *Tuner) Frequency(midi int) float64 {
+	return t.Reference() * math.Pow(2, float64(midi-REFERENCE_NOTE)/12)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/control"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/sessions"
+)
+
+// Commandes accessibles depuis les interfaces de contrôle
+func newCommands(hub *events.Hub, filter *input.Filter, tuner *audio.Tuner, store *sessions.Store) *control.Commands {
+
+	commands := control.NewCommands()
+
+	commands.Register("tare", "use the current orientation as reference",
+		func(args []interface{}) (interface{}, error) {
+			filter.Tare()
+			return nil, nil
+		})
+
+	commands.Register("tare.reset", "remove the orientation reference",
+		func(args []interface{}) (interface{}, error) {
+			filter.ResetTare()
+			return nil, nil
+		})
+
+	// Le masque ne filtre que les valeurs reçues, le firmware ne recevant
+	// aucune commande
+	commands.Register("output.mask", "set the outputs kept from the received samples [mask]",
+		func(args []interface{}) (interface{}, error) {
+			mask, err := control.Int(args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			filter.SetMask(mask)
+			return filter.Mask(), nil
+		})
+
+	commands.Register("tuner.reference", "set the tuning reference [frequency]",
+		func(args []interface{}) (interface{}, error) {
+			reference, err := control.Float(args, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = tuner.SetReference(reference); err != nil {
+				return nil, err
+			}
+
+			hub.Publish(events.STATUS, map[string]interface{}{
+				"tuner.reference": reference,
+			})
+
+			return reference, nil
+		})
+
+	commands.Register("record.start", "start a practice session [player] [piece]",
+		func(args []interface{}) (interface{}, error) {
+			var metadata sessions.Metadata
+
+			if len(args) > 0 {
+				metadata.Player, _ = control.String(args, 0)
+			}
+
+			if len(args) > 1 {
+				metadata.Piece, _ = control.String(args, 1)
+			}
+
+			return store.Start(metadata)
+		})
+
+	commands.Register("record.stop", "stop the current practice session",
+		func(args []interface{}) (interface{}, error) {
+			session, err := store.Active()
+			if err != nil {
+				return nil, err
+			}
+
+			return store.Stop(session.Id)
+		})
+
+	return commands
+}
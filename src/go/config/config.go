@@ -0,0 +1,572 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/input"
+)
+
+// Fichier chargé au démarrage s'il est présent dans le répertoire courant
+const DEFAULT_PATH = "violin.json"
+
+// Valeurs par défaut du serveur HTTP & de la publication MQTT, reprises ici
+// pour ne pas dépendre des paquets api & mqtt
+const (
+	DEFAULT_LISTEN      = "localhost:5000"
+	DEFAULT_MQTT_PREFIX = "violin"
+)
+
+// Description complète d'une installation : capteurs, ports & liaisons
+// audio, analyse, affichages et sorties réseau. Les chemins relatifs sont
+// résolus par rapport au répertoire du fichier de configuration.
+type Config struct {
+	Devices []Device `json:"devices"`
+
+	// Orientation de référence enregistrée par le mode calibrate
+	Calibration string `json:"calibration,omitempty"`
+
+	Audio    Audio    `json:"audio"`
+	Display  Display  `json:"display"`
+	Server   Server   `json:"server"`
+	Sessions Sessions `json:"sessions"`
+	Osc      Osc      `json:"osc"`
+	Mqtt     Mqtt     `json:"mqtt"`
+
+	// Transcription MIDI écrite à l'arrêt
+	Midi string `json:"midi,omitempty"`
+}
+
+// Capteur branché sur un port série
+type Device struct {
+	Port     string `json:"port"`
+	Baudrate int    `json:"baudrate,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+
+	// Capteur fixé sur l'archet plutôt que sur le violon
+	Bow bool `json:"bow,omitempty"`
+}
+
+// Liaison JACK entre deux ports "client:port"
+type Link struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type Audio struct {
+	// Nom du client JACK, préfixe de ses ports
+	Client string `json:"client"`
+	Links  []Link `json:"links"`
+
+	// Fréquence du La de l'accordeur
+	Reference float64 `json:"reference"`
+
+	// Seuils de détection de la hauteur
+	MinLevel   float64 `json:"min_level"`
+	MinClarity float64 `json:"min_clarity"`
+
+	// Intervalle minimal entre deux spectres publiés
+	SpectrumInterval Duration `json:"spectrum_interval"`
+}
+
+type Window struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type Display struct {
+	View3D View3D `json:"view3d"`
+	Graphs Graphs `json:"graphs"`
+}
+
+// Fenêtre OpenGL du mode view3d
+type View3D struct {
+	Window
+
+	// Plein écran sur l'écran désigné par son nom ou son numéro (0 pour le
+	// premier), à défaut l'écran principal
+	Fullscreen bool   `json:"fullscreen,omitempty"`
+	Monitor    string `json:"monitor,omitempty"`
+
+	// Agrandissement de l'affichage tête haute, 0 pour le déduire de l'écran
+	Scale float64 `json:"scale,omitempty"`
+
+	// Synchronisation avec le rafraîchissement de l'écran, échantillons par
+	// pixel de l'anticrénelage (0 pour le désactiver) & test de profondeur
+	Vsync     bool `json:"vsync"`
+	Samples   int  `json:"samples"`
+	DepthTest bool `json:"depth_test"`
+
+	// Préfixe des fichiers .vs & .fs du programme
+	Shader  string `json:"shader"`
+	Texture string `json:"texture"`
+
+	// Sans modèle, le violon est un triangle recouvert de la texture &
+	// l'archet n'est pas affiché
+	Violin Model `json:"violin"`
+	Bow    Model `json:"bow"`
+
+	// Repères supplémentaires (épaule, bras, main...) auxquels le violon,
+	// l'archet ou d'autres repères peuvent être attachés
+	Nodes []Node `json:"nodes,omitempty"`
+
+	// Capteurs orientant ou déplaçant les repères, à défaut celui de
+	// l'archet oriente l'archet & les autres le violon
+	Sensors []SensorBinding `json:"sensors,omitempty"`
+
+	// Vue initiale (player, audience, top) & fichier des vues enregistrées
+	Camera  string `json:"camera"`
+	Cameras string `json:"cameras,omitempty"`
+
+	// Raccourcis "modificateurs+touche" remplaçant ceux par défaut, par
+	// action (move_left, rotate_x+, freeze...)
+	Bindings map[string]string `json:"bindings,omitempty"`
+
+	// Lumières remplaçant celles par défaut & lumière ambiante
+	Lights  []Light     `json:"lights,omitempty"`
+	Ambient *[3]float64 `json:"ambient,omitempty"`
+
+	// Trajectoire de la pointe de l'archet
+	Trail Trail `json:"trail"`
+
+	// Affichage tête haute : images par seconde, capteurs & hauteur détectée
+	Hud Hud `json:"hud"`
+}
+
+type Hud struct {
+	Enabled bool `json:"enabled"`
+
+	// Police TrueType ou OpenType & taille en pixels, vide pour la police
+	// bitmap intégrée
+	Font string  `json:"font,omitempty"`
+	Size float64 `json:"size"`
+}
+
+// Trajectoire récente de la pointe de l'archet, affichée avec un modèle
+// d'archet
+type Trail struct {
+	// line, ribbon ou vide pour ne pas l'afficher
+	Style string `json:"style,omitempty"`
+
+	// speed, stroke (sens du coup d'archet) ou vide pour une couleur unique
+	Color string `json:"color,omitempty"`
+
+	// Direction de l'archet dans le repère du modèle & distance de son pivot
+	// à la pointe
+	Axis   [3]float64 `json:"axis"`
+	Length float64    `json:"length"`
+
+	// Largeur du ruban le long de l'archet
+	Width float64 `json:"width"`
+
+	// Durée d'affichage d'un point avant qu'il ne disparaisse
+	Duration Duration `json:"duration"`
+
+	// Vitesse de la pointe par seconde affichée avec la couleur la plus
+	// rapide
+	MaxSpeed float64 `json:"max_speed"`
+}
+
+type Light struct {
+	// directional, éclairant dans la direction, ou point, placé à la
+	// position
+	Type      string     `json:"type"`
+	Direction [3]float64 `json:"direction,omitempty"`
+	Position  [3]float64 `json:"position,omitempty"`
+
+	// Blanc par défaut
+	Color     *[3]float64 `json:"color,omitempty"`
+	Intensity float64     `json:"intensity"`
+}
+
+// Modèle 3D orienté par un capteur
+type Model struct {
+	// Fichier .obj, .gltf ou .glb
+	Path string `json:"path,omitempty"`
+
+	// Point du modèle placé à la position & autour duquel il tourne, à
+	// défaut l'origine d'un fichier glTF ou le centre d'un fichier .obj
+	Pivot *[3]float64 `json:"pivot,omitempty"`
+
+	Position [3]float64 `json:"position"`
+
+	// Orientation initiale en degrés autour des axes X, Y puis Z
+	Rotation [3]float64 `json:"rotation,omitempty"`
+
+	// Repère auquel le modèle est attaché, sa position & son orientation
+	// étant relatives à celui-ci
+	Parent string `json:"parent,omitempty"`
+
+	// Plus grande dimension affichée, 0 pour garder celle du fichier
+	Size float64 `json:"size,omitempty"`
+}
+
+// Repère nommé de la scène, sans modèle un simple point d'attache
+type Node struct {
+	Name string `json:"name"`
+	Model
+}
+
+// Flux d'un capteur appliqué à l'orientation ou à la position d'un repère
+type SensorBinding struct {
+	// Identifiant du capteur, vide pour ceux qu'aucune autre liaison ne
+	// désigne
+	Device string `json:"device,omitempty"`
+
+	// violin, bow ou le nom d'un repère
+	Node string `json:"node"`
+
+	// rotation ou position
+	Target string `json:"target"`
+
+	// quaternion (par défaut) ou ypr (lacet, tangage & roulis) pour une
+	// orientation dans la scène, real ou world (accélération intégrée) pour
+	// un déplacement depuis la position initiale dans le repère du parent
+	Source string `json:"source,omitempty"`
+
+	// Déplacement par unité d'accélération intégrée deux fois (unité.s²)
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// Graphes de l'entrée audio
+type Graphs struct {
+	Window
+
+	Enabled bool   `json:"enabled"`
+	Font    string `json:"font"`
+}
+
+type Server struct {
+	Listen  string   `json:"listen"`
+	Web     string   `json:"web,omitempty"`
+	Token   string   `json:"token,omitempty"`
+	Origins []string `json:"origins,omitempty"`
+}
+
+type Sessions struct {
+	Dir    string `json:"dir"`
+	Player string `json:"player,omitempty"`
+	Piece  string `json:"piece,omitempty"`
+}
+
+type Osc struct {
+	// Destinataires host:port des messages
+	Targets []string `json:"targets,omitempty"`
+	Rate    float64  `json:"rate,omitempty"`
+	Bundle  bool     `json:"bundle,omitempty"`
+	Prefix  string   `json:"prefix,omitempty"`
+
+	// Renommages d'adresses, une adresse vide désactive l'envoi
+	Mapping map[string]string `json:"mapping,omitempty"`
+
+	// Réception des commandes
+	Listen    string `json:"listen,omitempty"`
+	ReplyPort int    `json:"reply_port,omitempty"`
+
+	// Expéditeurs autorisés (adresses ou réseaux) & jeton attendu en premier
+	// argument des commandes
+	Allow []string `json:"allow,omitempty"`
+	Token string   `json:"token,omitempty"`
+}
+
+type Mqtt struct {
+	Broker   string `json:"broker,omitempty"`
+	ClientId string `json:"client_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Prefix   string `json:"prefix"`
+	QoS      int    `json:"qos"`
+
+	// Sujets par type d'évènement, un sujet vide désactive la publication
+	Topics map[string]string `json:"topics,omitempty"`
+}
+
+// Durée exprimée en JSON sous la forme "100ms", "2s"...
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+
+	var value string
+	if err = json.Unmarshal(data, &value); err == nil {
+		d.Duration, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid duration %s (expect e.g. \"100ms\")", data)
+	}
+
+	return nil
+}
+
+// Configuration utilisée en l'absence de fichier
+func Default() *Config {
+	return &Config{
+		Devices: []Device{
+			{
+				Port:     "/dev/ttyACM0",
+				Baudrate: input.DEFAULT_BAUDRATE,
+				Protocol: input.DEFAULT_PROTOCOL,
+			},
+		},
+		Audio: Audio{
+			Client: "violin",
+			Links: []Link{
+				{"PulseAudio JACK Sink:front-left", "violin:in_0"},
+				{"PulseAudio JACK Sink:front-right", "violin:in_1"},
+				{"violin:out_0", "system:playback_1"},
+				{"violin:out_1", "system:playback_2"},
+			},
+			Reference:        audio.DEFAULT_REFERENCE,
+			MinLevel:         audio.MIN_LEVEL,
+			MinClarity:       audio.MIN_CLARITY,
+			SpectrumInterval: Duration{100 * time.Millisecond},
+		},
+		Display: Display{
+			View3D: View3D{
+				Window:  Window{Width: 800, Height: 600},
+				Shader:  "basicShader",
+				Texture: "bricks.jpg",
+				Violin:  Model{Size: 1.2},
+				Bow:     Model{Size: 1.5},
+				Camera:  "audience",
+				Cameras: "cameras.json",
+				Trail: Trail{
+					Axis:     [3]float64{1, 0, 0},
+					Length:   0.75,
+					Width:    0.1,
+					Duration: Duration{2 * time.Second},
+					MaxSpeed: 2,
+				},
+				Hud: Hud{
+					Enabled: true,
+					Font:    "/usr/share/fonts/truetype/dejavu/DejaVuSansMono.ttf",
+					Size:    14,
+				},
+				Vsync:     true,
+				Samples:   4,
+				DepthTest: true,
+			},
+			Graphs: Graphs{
+				Window: Window{Width: 1000, Height: 800},
+				Font:   "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+			},
+		},
+		Server: Server{
+			Listen: DEFAULT_LISTEN,
+		},
+		Sessions: Sessions{
+			Dir: "recordings",
+		},
+		Mqtt: Mqtt{
+			Prefix: DEFAULT_MQTT_PREFIX,
+		},
+	}
+}
+
+// Charge le fichier par dessus la configuration par défaut puis la valide
+func Load(path string) (c *Config, err error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	c = Default()
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(c); err != nil {
+		return nil, decodeError(path, data, err)
+	}
+
+	c.setDefaults()
+	c.resolve(filepath.Dir(path))
+
+	if err = c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return
+}
+
+// Valeurs omises pour chacun des capteurs
+func (c *Config) setDefaults() {
+	for idx := range c.Devices {
+		device := &c.Devices[idx]
+
+		if device.Baudrate == 0 {
+			device.Baudrate = input.DEFAULT_BAUDRATE
+		}
+
+		if device.Protocol == "" {
+			device.Protocol = input.DEFAULT_PROTOCOL
+		}
+	}
+}
+
+// Chemins modifiables par le fichier de configuration
+func (c *Config) paths() []*string {
+	return []*string{
+		&c.Calibration,
+		&c.Midi,
+		&c.Display.View3D.Shader,
+		&c.Display.View3D.Texture,
+		&c.Display.View3D.Violin.Path,
+		&c.Display.View3D.Bow.Path,
+		&c.Display.View3D.Cameras,
+		&c.Display.View3D.Hud.Font,
+		&c.Display.Graphs.Font,
+		&c.Server.Web,
+		&c.Sessions.Dir,
+	}
+}
+
+// Les chemins relatifs spécifiés dans le fichier le sont par rapport à son
+// répertoire, les valeurs par défaut restant relatives au répertoire courant
+func (c *Config) resolve(dir string) {
+
+	defaults := Default().paths()
+
+	for idx, path := range c.paths() {
+		if *path != "" && *path != *defaults[idx] && filepath.IsAbs(*path) == false {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+
+	// Les repères n'ont pas de valeur par défaut
+	for idx := range c.Display.View3D.Nodes {
+		if path := &c.Display.View3D.Nodes[idx].Path; *path != "" && filepath.IsAbs(*path) == false {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+// Identifiant du capteur fixé sur l'archet, vide s'il n'y en a pas
+func (c *Config) Bow() string {
+
+	for _, device := range c.Devices {
+		if device.Bow {
+			return filepath.Base(device.Port)
+		}
+	}
+
+	return ""
+}
+
+// Liaisons sous la forme attendue par le client JACK
+func (a *Audio) LinksMap() map[string]string {
+
+	links := make(map[string]string, len(a.Links))
+	for _, link := range a.Links {
+		links[link.Source] = link.Destination
+	}
+
+	return links
+}
+
+// Analyse une liste de liaisons "source=destination,..."
+func ParseLinks(list string) (links []Link, err error) {
+
+	for _, entry := range strings.Split(list, ",") {
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid JACK link '%s' (expect source=destination)", entry)
+		}
+
+		links = append(links, Link{
+			Source:      strings.TrimSpace(parts[0]),
+			Destination: strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return
+}
+
+func FormatLinks(links []Link) string {
+
+	entries := make([]string, len(links))
+	for idx, link := range links {
+		entries[idx] = link.Source + "=" + link.Destination
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// Erreur de syntaxe ou de type localisée par sa ligne & sa colonne
+func decodeError(path string, data []byte, err error) error {
+
+	var offset int64
+
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		if e.Field != "" {
+			err = fmt.Errorf("%s: expect %s, got %s", e.Field, jsonType(e.Type), e.Value)
+		} else {
+			err = fmt.Errorf("expect %s, got %s", jsonType(e.Type), e.Value)
+		}
+
+	default:
+		// Champ inconnu ou valeur refusée (durée...)
+		return fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line, column := 1, 1
+	for _, char := range data[:offset] {
+		if char == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return fmt.Errorf("%s:%d:%d: %s", path, line, column, err)
+}
+
+// Nom JSON du type attendu
+func jsonType(t reflect.Type) string {
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+
+	return "object"
+}
@@ -0,0 +1,490 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+)
+
+// Schémas d'adresse acceptés par le client MQTT
+var MQTT_SCHEMES = []string{"tcp", "ssl", "tls", "ws", "wss", "mqtt", "mqtts"}
+
+// Extensions des modèles 3D affichés
+var MODEL_FORMATS = []string{".obj", ".gltf", ".glb"}
+
+var LIGHT_TYPES = []string{"directional", "point"}
+
+var TRAIL_STYLES = []string{"line", "ribbon"}
+var TRAIL_COLORS = []string{"speed", "stroke"}
+
+// Flux des capteurs applicables à l'orientation & à la position d'un repère
+var ROTATION_SOURCES = []string{"quaternion", "ypr"}
+var POSITION_SOURCES = []string{"real", "world"}
+
+// Repères du violon & de l'archet, toujours présents dans la scène
+var INSTRUMENT_NODES = []string{"violin", "bow"}
+
+// Nombre de lumières gérées par les shaders
+const MAX_LIGHTS = 4
+
+// Échantillons par pixel de l'anticrénelage au plus
+const MAX_SAMPLES = 16
+
+// Ensemble des problèmes relevés, chacun précédé du champ concerné
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+func (e *ValidationError) add(field string, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+// Vérifie l'ensemble de la configuration plutôt que de s'arrêter à la
+// première erreur
+func (c *Config) Validate() error {
+
+	e := new(ValidationError)
+
+	c.validateDevices(e)
+	c.validateAudio(e)
+	c.validateDisplay(e)
+
+	checkAddress(e, "server.listen", c.Server.Listen, true)
+
+	if c.Sessions.Dir == "" {
+		e.add("sessions.dir", "missing directory")
+	}
+
+	c.validateOsc(e)
+	c.validateMqtt(e)
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+
+	return nil
+}
+
+func (c *Config) validateDevices(e *ValidationError) {
+
+	ids := make(map[string]int)
+	bow := -1
+
+	for idx, device := range c.Devices {
+
+		field := fmt.Sprintf("devices[%d]", idx)
+
+		if device.Port == "" {
+			e.add(field+".port", "missing serial port")
+			continue
+		}
+
+		// L'identifiant des capteurs est le nom du port
+		id := filepath.Base(device.Port)
+		if previous, ok := ids[id]; ok {
+			e.add(field+".port", "'%s' already used by devices[%d]", id, previous)
+		}
+		ids[id] = idx
+
+		if device.Baudrate < 0 {
+			e.add(field+".baudrate", "invalid baud rate %d", device.Baudrate)
+		}
+
+		if device.Protocol != "" && contains(input.PROTOCOLS, device.Protocol) == false {
+			e.add(field+".protocol", "unknown protocol '%s' (expect %s)",
+				device.Protocol, strings.Join(input.PROTOCOLS, ", "))
+		}
+
+		if device.Bow {
+			if bow >= 0 {
+				e.add(field+".bow", "devices[%d] is already on the bow", bow)
+			}
+			bow = idx
+		}
+	}
+}
+
+func (c *Config) validateAudio(e *ValidationError) {
+
+	a := &c.Audio
+
+	if a.Client == "" || strings.Contains(a.Client, ":") {
+		e.add("audio.client", "invalid JACK client name '%s'", a.Client)
+	}
+
+	sources := make(map[string]int)
+
+	for idx, link := range a.Links {
+
+		field := fmt.Sprintf("audio.links[%d]", idx)
+
+		for _, port := range []struct{ name, value string }{
+			{"source", link.Source},
+			{"destination", link.Destination},
+		} {
+			if strings.Contains(port.value, ":") == false {
+				e.add(field+"."+port.name, "invalid JACK port '%s' (expect client:port)", port.value)
+			}
+		}
+
+		// Le client JACK n'accepte qu'une destination par source
+		if previous, ok := sources[link.Source]; ok {
+			e.add(field+".source", "'%s' already linked by audio.links[%d]", link.Source, previous)
+		}
+		sources[link.Source] = idx
+	}
+
+	if a.Reference < audio.MIN_REFERENCE || a.Reference > audio.MAX_REFERENCE {
+		e.add("audio.reference", "%g Hz out of range (expect %.0f-%.0f Hz)",
+			a.Reference, audio.MIN_REFERENCE, audio.MAX_REFERENCE)
+	}
+
+	if a.MinLevel > 0 {
+		e.add("audio.min_level", "%g dB must be negative", a.MinLevel)
+	}
+
+	if a.MinClarity < 0 || a.MinClarity > 1 {
+		e.add("audio.min_clarity", "%g out of range (expect 0-1)", a.MinClarity)
+	}
+
+	if a.SpectrumInterval.Duration < 0 {
+		e.add("audio.spectrum_interval", "negative duration %s", a.SpectrumInterval)
+	}
+}
+
+func (c *Config) validateDisplay(e *ValidationError) {
+
+	for _, window := range []struct {
+		field string
+		Window
+	}{
+		{"display.view3d", c.Display.View3D.Window},
+		{"display.graphs", c.Display.Graphs.Window},
+	} {
+		if window.Width <= 0 || window.Height <= 0 {
+			e.add(window.field, "invalid size %dx%d", window.Width, window.Height)
+		}
+	}
+
+	if samples := c.Display.View3D.Samples; samples < 0 || samples > MAX_SAMPLES {
+		e.add("display.view3d.samples", "%d out of range (expect 0-%d)", samples, MAX_SAMPLES)
+	}
+
+	if c.Display.View3D.Scale < 0 {
+		e.add("display.view3d.scale", "negative scale %g", c.Display.View3D.Scale)
+	}
+
+	if c.Display.View3D.Shader == "" {
+		e.add("display.view3d.shader", "missing shader")
+	}
+
+	validateModel(e, "display.view3d.violin", c.Display.View3D.Violin)
+	validateModel(e, "display.view3d.bow", c.Display.View3D.Bow)
+
+	for idx, node := range c.Display.View3D.Nodes {
+		validateModel(e, fmt.Sprintf("display.view3d.nodes[%d]", idx), node.Model)
+	}
+
+	if c.Display.View3D.Camera == "" {
+		e.add("display.view3d.camera", "missing camera view")
+	}
+
+	if len(c.Display.View3D.Lights) > MAX_LIGHTS {
+		e.add("display.view3d.lights", "%d lights, expect at most %d",
+			len(c.Display.View3D.Lights), MAX_LIGHTS)
+	}
+
+	for idx, light := range c.Display.View3D.Lights {
+
+		field := fmt.Sprintf("display.view3d.lights[%d]", idx)
+
+		if contains(LIGHT_TYPES, light.Type) == false {
+			e.add(field+".type", "unknown type '%s' (expect %s)",
+				light.Type, strings.Join(LIGHT_TYPES, ", "))
+		}
+
+		if light.Type == "directional" && light.Direction == [3]float64{} {
+			e.add(field+".direction", "missing direction")
+		}
+
+		if light.Intensity < 0 {
+			e.add(field+".intensity", "negative intensity %g", light.Intensity)
+		}
+	}
+
+	c.validateNodes(e)
+	c.validateSensors(e)
+	c.validateTrail(e)
+
+	if c.Display.View3D.Hud.Enabled && c.Display.View3D.Hud.Size <= 0 {
+		e.add("display.view3d.hud.size", "invalid size %g", c.Display.View3D.Hud.Size)
+	}
+
+	if c.Display.Graphs.Font == "" {
+		e.add("display.graphs.font", "missing font")
+	}
+}
+
+// Noms uniques, parents existants & sans cycle
+func (c *Config) validateNodes(e *ValidationError) {
+
+	view := &c.Display.View3D
+
+	parents := map[string]string{
+		"violin": view.Violin.Parent,
+		"bow":    view.Bow.Parent,
+	}
+
+	// Sans modèle, l'archet n'est pas dans la scène
+	names := []string{"violin"}
+	if view.Bow.Path != "" {
+		names = append(names, "bow")
+	}
+
+	for idx, node := range view.Nodes {
+
+		field := fmt.Sprintf("display.view3d.nodes[%d]", idx)
+
+		if node.Name == "" {
+			e.add(field+".name", "missing name")
+			continue
+		}
+
+		if _, ok := parents[node.Name]; ok {
+			e.add(field+".name", "'%s' already used", node.Name)
+			continue
+		}
+
+		parents[node.Name] = node.Parent
+		names = append(names, node.Name)
+	}
+
+	if view.Bow.Path == "" {
+		delete(parents, "bow")
+	}
+
+	for _, name := range names {
+
+		parent := parents[name]
+		if parent == "" {
+			continue
+		}
+
+		if _, ok := parents[parent]; ok == false {
+			e.add(nodeField(view, name)+".parent", "unknown node '%s'", parent)
+			continue
+		}
+
+		// Un cycle ne passant pas par ce repère est signalé par les siens
+		ancestor := parent
+		for depth := 0; ancestor != "" && ancestor != name && depth < len(parents); depth++ {
+			ancestor = parents[ancestor]
+		}
+
+		if ancestor == name {
+			e.add(nodeField(view, name)+".parent", "'%s' is attached to itself", name)
+		}
+	}
+}
+
+func nodeField(view *View3D, name string) string {
+
+	if contains(INSTRUMENT_NODES, name) {
+		return "display.view3d." + name
+	}
+
+	for idx, node := range view.Nodes {
+		if node.Name == name {
+			return fmt.Sprintf("display.view3d.nodes[%d]", idx)
+		}
+	}
+
+	return "display.view3d.nodes"
+}
+
+func (c *Config) validateSensors(e *ValidationError) {
+
+	view := &c.Display.View3D
+
+	nodes := map[string]bool{"violin": true, "bow": view.Bow.Path != ""}
+	for _, node := range view.Nodes {
+		nodes[node.Name] = true
+	}
+
+	for idx, binding := range view.Sensors {
+
+		field := fmt.Sprintf("display.view3d.sensors[%d]", idx)
+
+		if nodes[binding.Node] == false {
+			e.add(field+".node", "unknown node '%s'", binding.Node)
+		}
+
+		switch binding.Target {
+		case "rotation":
+			if binding.Source != "" && contains(ROTATION_SOURCES, binding.Source) == false {
+				e.add(field+".source", "unknown source '%s' (expect %s)",
+					binding.Source, strings.Join(ROTATION_SOURCES, ", "))
+			}
+
+		case "position":
+			if contains(POSITION_SOURCES, binding.Source) == false {
+				e.add(field+".source", "unknown source '%s' (expect %s)",
+					binding.Source, strings.Join(POSITION_SOURCES, ", "))
+			}
+
+			if binding.Scale == 0 {
+				e.add(field+".scale", "missing scale")
+			}
+
+		default:
+			e.add(field+".target", "unknown target '%s' (expect rotation, position)", binding.Target)
+		}
+	}
+}
+
+func validateModel(e *ValidationError, field string, model Model) {
+
+	if model.Path != "" && contains(MODEL_FORMATS, strings.ToLower(filepath.Ext(model.Path))) == false {
+		e.add(field+".path", "unsupported model '%s' (expect %s)",
+			model.Path, strings.Join(MODEL_FORMATS, ", "))
+	}
+
+	if model.Size < 0 {
+		e.add(field+".size", "negative size %g", model.Size)
+	}
+}
+
+func (c *Config) validateTrail(e *ValidationError) {
+
+	t := &c.Display.View3D.Trail
+
+	if t.Style == "" {
+		return
+	}
+
+	if contains(TRAIL_STYLES, t.Style) == false {
+		e.add("display.view3d.trail.style", "unknown style '%s' (expect %s)",
+			t.Style, strings.Join(TRAIL_STYLES, ", "))
+	}
+
+	if t.Color != "" && contains(TRAIL_COLORS, t.Color) == false {
+		e.add("display.view3d.trail.color", "unknown color '%s' (expect %s)",
+			t.Color, strings.Join(TRAIL_COLORS, ", "))
+	}
+
+	if c.Display.View3D.Bow.Path == "" {
+		e.add("display.view3d.trail", "requires a bow model (display.view3d.bow.path)")
+	}
+
+	if t.Axis == [3]float64{} {
+		e.add("display.view3d.trail.axis", "missing axis")
+	}
+
+	if t.Length <= 0 {
+		e.add("display.view3d.trail.length", "invalid length %g", t.Length)
+	}
+
+	if t.Width < 0 {
+		e.add("display.view3d.trail.width", "negative width %g", t.Width)
+	}
+
+	if t.Duration.Duration <= 0 {
+		e.add("display.view3d.trail.duration", "invalid duration %s", t.Duration)
+	}
+
+	if t.MaxSpeed <= 0 {
+		e.add("display.view3d.trail.max_speed", "invalid speed %g", t.MaxSpeed)
+	}
+}
+
+func (c *Config) validateOsc(e *ValidationError) {
+
+	o := &c.Osc
+
+	for idx, target := range o.Targets {
+		checkAddress(e, fmt.Sprintf("osc.targets[%d]", idx), target, false)
+	}
+
+	if o.Rate < 0 {
+		e.add("osc.rate", "negative rate %g", o.Rate)
+	}
+
+	if o.Prefix != "" && strings.HasPrefix(o.Prefix, "/") == false {
+		e.add("osc.prefix", "'%s' must start with /", o.Prefix)
+	}
+
+	for address, mapped := range o.Mapping {
+		if strings.HasPrefix(address, "/") == false ||
+			(mapped != "" && strings.HasPrefix(mapped, "/") == false) {
+			e.add("osc.mapping", "invalid mapping '%s' → '%s'", address, mapped)
+		}
+	}
+
+	if o.Listen != "" {
+		checkAddress(e, "osc.listen", o.Listen, true)
+	}
+
+	if o.ReplyPort < 0 || o.ReplyPort > 65535 {
+		e.add("osc.reply_port", "invalid port %d", o.ReplyPort)
+	}
+
+	for idx, entry := range o.Allow {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			e.add(fmt.Sprintf("osc.allow[%d]", idx), "invalid address or network '%s'", entry)
+		}
+	}
+}
+
+func (c *Config) validateMqtt(e *ValidationError) {
+
+	m := &c.Mqtt
+
+	if m.Broker != "" {
+		broker, err := url.Parse(m.Broker)
+		if err != nil || contains(MQTT_SCHEMES, broker.Scheme) == false || broker.Host == "" {
+			e.add("mqtt.broker", "invalid broker '%s' (expect e.g. tcp://localhost:1883)", m.Broker)
+		}
+	}
+
+	if m.Prefix == "" {
+		e.add("mqtt.prefix", "missing topics prefix")
+	}
+
+	if m.QoS < 0 || m.QoS > 2 {
+		e.add("mqtt.qos", "invalid QoS %d (expect 0, 1 or 2)", m.QoS)
+	}
+
+	for eventType := range m.Topics {
+		if contains(events.TYPES, eventType) == false {
+			e.add("mqtt.topics", "unknown event type '%s'", eventType)
+		}
+	}
+}
+
+// Adresse host:port, l'hôte pouvant être omis pour une écoute
+func checkAddress(e *ValidationError, field string, address string, listen bool) {
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port == "" || (host == "" && listen == false) {
+		e.add(field, "invalid address '%s' (expect host:port)", address)
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
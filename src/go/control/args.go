@@ -0,0 +1,62 @@
+package control
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Récupère l'argument à l'index spécifié sous forme de nombre flottant
+func Float(args []interface{}, idx int) (float64, error) {
+
+	if idx >= len(args) {
+		return 0, fmt.Errorf("missing argument %d", idx)
+	}
+
+	switch value := args[idx].(type) {
+	case float64:
+		return value, nil
+	case float32:
+		return float64(value), nil
+	case int:
+		return float64(value), nil
+	case int32:
+		return float64(value), nil
+	case int64:
+		return float64(value), nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return 0, fmt.Errorf("argument %d: expected number, got %T", idx, args[idx])
+}
+
+// Récupère l'argument à l'index spécifié sous forme d'entier
+func Int(args []interface{}, idx int) (int, error) {
+
+	if idx < len(args) {
+		if value, ok := args[idx].(string); ok {
+			result, err := strconv.ParseInt(value, 0, 64)
+			return int(result), err
+		}
+	}
+
+	value, err := Float(args, idx)
+	return int(value), err
+}
+
+// Récupère l'argument à l'index spécifié sous forme de chaîne
+func String(args []interface{}, idx int) (string, error) {
+
+	if idx >= len(args) {
+		return "", fmt.Errorf("missing argument %d", idx)
+	}
+
+	switch value := args[idx].(type) {
+	case string:
+		return value, nil
+	case fmt.Stringer:
+		return value.String(), nil
+	}
+
+	return fmt.Sprint(args[idx]), nil
+}
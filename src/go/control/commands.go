@@ -0,0 +1,76 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Exécute une commande avec ses arguments & retourne son résultat
+type Handler func(args []interface{}) (interface{}, error)
+
+type Description struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Registre des commandes pilotant l'application, partagé par les différentes
+// interfaces de contrôle (WebSocket, OSC, ...)
+type Commands struct {
+	mutex    sync.RWMutex
+	handlers map[string]Handler
+	docs     map[string]string
+}
+
+func NewCommands() *Commands {
+	return &Commands{
+		handlers: make(map[string]Handler),
+		docs:     make(map[string]string),
+	}
+}
+
+func (c *Commands) Register(name string, description string, handler Handler) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.handlers[name] = handler
+	c.docs[name] = description
+}
+
+func (c *Commands) Execute(name string, args []interface{}) (interface{}, error) {
+
+	c.mutex.RLock()
+	handler, ok := c.handlers[name]
+	c.mutex.RUnlock()
+
+	if ok == false {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownCommand, name)
+	}
+
+	return handler(args)
+}
+
+// Liste des commandes disponibles triées par nom
+func (c *Commands) List() []Description {
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	result := make([]Description, 0, len(c.docs))
+	for name, description := range c.docs {
+		result = append(result, Description{
+			Name:        name,
+			Description: description,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
@@ -0,0 +1,262 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Types d'évènements diffusés
+const (
+	SAMPLE = "sample"
+	STATUS = "status"
+	STROKE = "stroke"
+
+	// Analyse audio
+	PITCH    = "pitch"
+	LEVEL    = "level"
+	SPECTRUM = "spectrum"
+)
+
+// Ensemble des types diffusés
+var TYPES = []string{SAMPLE, STATUS, STROKE, PITCH, LEVEL, SPECTRUM}
+
+const (
+	DEFAULT_HISTORY = 256
+	DEFAULT_BUFFER  = 64
+)
+
+type Event struct {
+	Id   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Diffuse les évènements à un nombre quelconque d'abonnés : chaque abonné
+// dispose de son propre channel, un abonné trop lent perd des évènements
+// sans bloquer les autres
+type Hub struct {
+	mutex       sync.Mutex
+	lastId      uint64
+	dropped     uint64
+	history     []*Event
+	historyIdx  int
+	subscribers map[*Subscriber]struct{}
+
+	// Evènements perdus par type d'abonné, y compris ceux déjà désabonnés
+	droppedByKind map[string]uint64
+}
+
+type Subscriber struct {
+	C    <-chan *Event
+	Name string
+	Kind string
+
+	hub     *Hub
+	channel chan *Event
+	types   map[string]bool
+	dropped uint64
+	closed  bool
+}
+
+func NewHub(historySize int) *Hub {
+
+	if historySize <= 0 {
+		historySize = DEFAULT_HISTORY
+	}
+
+	return &Hub{
+		history:       make([]*Event, 0, historySize),
+		subscribers:   make(map[*Subscriber]struct{}),
+		droppedByKind: make(map[string]uint64),
+	}
+}
+
+// Type d'abonné : préfixe du nom avant ":", par exemple "sse" pour
+// "sse:127.0.0.1:5123", "anonymous" pour un abonné sans nom
+func SubscriberKind(name string) string {
+
+	if name == "" {
+		return "anonymous"
+	}
+
+	return strings.SplitN(name, ":", 2)[0]
+}
+
+// Publie un nouvel évènement auprès de tous les abonnés
+func (h *Hub) Publish(eventType string, data interface{}) *Event {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastId++
+
+	event := &Event{
+		Id:   h.lastId,
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+
+	// Conservation de l'historique pour permettre la reprise
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, event)
+	} else {
+		h.history[h.historyIdx] = event
+		h.historyIdx = (h.historyIdx + 1) % len(h.history)
+	}
+
+	for subscriber := range h.subscribers {
+		subscriber.send(event)
+	}
+
+	return event
+}
+
+type SubscriberStats struct {
+	Name    string
+	Kind    string
+	Pending int
+	Dropped uint64
+}
+
+type Stats struct {
+	Published     uint64
+	Dropped       uint64
+	DroppedByKind map[string]uint64
+	Subscribers   []SubscriberStats
+}
+
+// Abonnement aux évènements des types spécifiés (tous si aucun) : les
+// évènements encore présents dans l'historique dont l'identifiant est
+// supérieur à lastId sont renvoyés en premier
+func (h *Hub) Subscribe(size int, lastId uint64, types ...string) *Subscriber {
+	return h.SubscribeAs("", size, lastId, types...)
+}
+
+// Abonnement nommé, le nom permettant d'identifier l'abonné dans les
+// statistiques
+func (h *Hub) SubscribeAs(name string, size int, lastId uint64, types ...string) *Subscriber {
+
+	if size <= 0 {
+		size = DEFAULT_BUFFER
+	}
+
+	channel := make(chan *Event, size)
+
+	s := &Subscriber{
+		C:       channel,
+		Name:    name,
+		Kind:    SubscriberKind(name),
+		hub:     h,
+		channel: channel,
+	}
+
+	if len(types) > 0 {
+		s.types = make(map[string]bool, len(types))
+		for _, eventType := range types {
+			s.types[eventType] = true
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if lastId > 0 {
+		for idx := range h.history {
+			event := h.history[(h.historyIdx+idx)%len(h.history)]
+			if event.Id > lastId {
+				s.send(event)
+			}
+		}
+	}
+
+	h.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// Nombre d'abonnés actuellement connectés
+func (h *Hub) Subscribers() int {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return len(h.subscribers)
+}
+
+// Nombre d'évènements publiés & perdus par abonné
+func (h *Hub) Stats() Stats {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	stats := Stats{
+		Published:     h.lastId,
+		Dropped:       atomic.LoadUint64(&h.dropped),
+		DroppedByKind: make(map[string]uint64, len(h.droppedByKind)),
+		Subscribers:   make([]SubscriberStats, 0, len(h.subscribers)),
+	}
+
+	for kind, dropped := range h.droppedByKind {
+		stats.DroppedByKind[kind] = dropped
+	}
+
+	for subscriber := range h.subscribers {
+		stats.Subscribers = append(stats.Subscribers, SubscriberStats{
+			Name:    subscriber.Name,
+			Kind:    subscriber.Kind,
+			Pending: len(subscriber.channel),
+			Dropped: subscriber.Dropped(),
+		})
+	}
+
+	return stats
+}
+
+// Identifiant du dernier évènement publié
+func (h *Hub) LastId() uint64 {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.lastId
+}
+
+// Se désabonne du hub & ferme le channel
+func (s *Subscriber) Close() {
+
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	delete(s.hub.subscribers, s)
+	close(s.channel)
+}
+
+// Nombre d'évènements perdus car l'abonné ne les consommait pas assez vite
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Envoi non bloquant : doit être appelé avec le mutex du hub verrouillé
+func (s *Subscriber) send(event *Event) {
+
+	if s.types != nil && s.types[event.Type] == false {
+		return
+	}
+
+	select {
+	case s.channel <- event:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		atomic.AddUint64(&s.hub.dropped, 1)
+		s.hub.droppedByKind[s.Kind]++
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const RECORDER_BUFFER = 1024
+
+// Enregistre les évènements du hub dans un fichier, un objet JSON par ligne
+type Recorder struct {
+	Path string
+
+	subscriber *Subscriber
+	file       *os.File
+	done       chan struct{}
+	count      uint64
+	err        error
+	mutex      sync.Mutex
+}
+
+func NewRecorder(hub *Hub, path string, types ...string) (r *Recorder, err error) {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+
+	r = &Recorder{
+		Path:       path,
+		subscriber: hub.SubscribeAs("recorder:"+path, RECORDER_BUFFER, 0, types...),
+		file:       file,
+		done:       make(chan struct{}),
+	}
+
+	go r.record()
+
+	return
+}
+
+func (r *Recorder) record() {
+
+	defer close(r.done)
+
+	writer := bufio.NewWriter(r.file)
+	encoder := json.NewEncoder(writer)
+
+	for event := range r.subscriber.C {
+
+		if err := encoder.Encode(event); err != nil {
+			r.setError(err)
+			continue
+		}
+
+		r.mutex.Lock()
+		r.count++
+		r.mutex.Unlock()
+
+		// Ecriture sur disque dès que plus rien n'est en attente
+		if len(r.subscriber.C) == 0 {
+			if err := writer.Flush(); err != nil {
+				r.setError(err)
+			}
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		r.setError(err)
+	}
+}
+
+func (r *Recorder) setError(err error) {
+	r.mutex.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mutex.Unlock()
+}
+
+// Nombre d'évènements enregistrés
+func (r *Recorder) Count() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.count
+}
+
+// Nombre d'évènements perdus
+func (r *Recorder) Dropped() uint64 {
+	return r.subscriber.Dropped()
+}
+
+// Arrête l'enregistrement & ferme le fichier
+func (r *Recorder) Stop() error {
+
+	r.subscriber.Close()
+	<-r.done
+
+	if err := r.file.Close(); err != nil {
+		r.setError(err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.err
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/opengl"
+)
+
+const (
+	// Valeurs conservées pour les courbes
+	HUD_HISTORY = 120
+
+	// Marge autour des panneaux, largeur des panneaux & des jauges, en
+	// pixels
+	HUD_MARGIN      = 8
+	HUD_PANEL_WIDTH = 256
+	HUD_BAR_WIDTH   = 120
+	HUD_DIAL_SIZE   = 36
+
+	// Au-delà, le capteur est considéré muet & la hauteur n'est plus
+	// affichée
+	SAMPLE_TIMEOUT = time.Second
+	PITCH_TIMEOUT  = 500 * time.Millisecond
+
+	// Taux d'erreurs CRC d'une liaison dégradée & mauvaise
+	CRC_WARNING = 0.01
+	CRC_ERROR   = 0.05
+
+	// Écarts de justesse, en cents, affichés en vert & en orange
+	CENTS_GOOD    = 5
+	CENTS_WARNING = 15
+)
+
+// Dernières valeurs reçues d'un capteur
+type hudSensor struct {
+	values   *input.AccelGyro
+	received time.Time
+
+	// Lacet, tangage & roulis en degrés, les plus récents à la fin
+	history [3][]float32
+}
+
+// Affichage tête haute de view3d, alimenté par les évènements du hub &
+// dessiné par la fenêtre à chaque image
+type hud struct {
+	devices *input.Manager
+
+	// Repères orientés ou déplacés par chaque capteur
+	nodes func(device string) string
+
+	mutex   sync.Mutex
+	sensors map[string]*hudSensor
+
+	pitch    *audio.Pitch
+	detected time.Time
+	cents    []float32
+}
+
+func newHud(devices *input.Manager, nodes func(device string) string) *hud {
+	return &hud{
+		devices: devices,
+		nodes:   nodes,
+		sensors: make(map[string]*hudSensor),
+	}
+}
+
+// Valeurs les plus récentes à la fin, au plus HUD_HISTORY
+func appendHistory(history []float32, value float32) []float32 {
+
+	if len(history) >= HUD_HISTORY {
+		history = append(history[:0], history[1:]...)
+	}
+
+	return append(history, value)
+}
+
+func (h *hud) apply(event *events.Event) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch data := event.Data.(type) {
+	case *input.AccelGyro:
+
+		sensor, ok := h.sensors[data.Device]
+		if ok == false {
+			sensor = new(hudSensor)
+			h.sensors[data.Device] = sensor
+		}
+
+		sensor.values = data
+		sensor.received = event.Time
+
+		angles := yawPitchRoll(data)
+		for idx := range angles {
+			sensor.history[idx] = appendHistory(sensor.history[idx], angles[idx])
+		}
+
+	case *audio.Pitch:
+		if data.Note == nil {
+			return
+		}
+
+		h.pitch = data
+		h.detected = event.Time
+		h.cents = appendHistory(h.cents, float32(data.Note.Cents))
+	}
+}
+
+// Lacet, tangage & roulis en degrés, calculés depuis le quaternion s'il
+// est transmis
+func yawPitchRoll(values *input.AccelGyro) (angles [3]float32) {
+
+	if values.Status&(input.QUATERNION|input.BUFFER) != 0 {
+		yaw, pitch, roll := values.YawPitchRoll()
+		return [3]float32{
+			mgl32.RadToDeg(float32(yaw)),
+			mgl32.RadToDeg(float32(pitch)),
+			mgl32.RadToDeg(float32(roll)),
+		}
+	}
+
+	return [3]float32{
+		mgl32.RadToDeg(values.Yaw),
+		mgl32.RadToDeg(values.Pitch),
+		mgl32.RadToDeg(values.Roll),
+	}
+}
+
+func (h *hud) draw(o *opengl.Overlay, now time.Time) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	line := o.LineHeight()
+
+	x, y := float32(HUD_MARGIN), float32(HUD_MARGIN)
+	y = h.drawFrames(o, x, y, line)
+
+	// Capteurs ouverts puis capteurs dont des valeurs ont été reçues
+	// (lecture d'une session)
+	statuses := make(map[string]input.DeviceStatus)
+	for _, status := range h.devices.Status() {
+		statuses[status.Id] = status
+	}
+
+	ids := make([]string, 0, len(statuses)+len(h.sensors))
+	for id := range statuses {
+		ids = append(ids, id)
+	}
+	for id := range h.sensors {
+		if _, ok := statuses[id]; ok == false {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	if len(ids) == 0 {
+		o.Text(x, y+HUD_MARGIN, opengl.HUD_LABEL, "no sensor")
+	}
+
+	for _, id := range ids {
+		status, opened := statuses[id]
+		y = h.drawSensor(o, x, y+HUD_MARGIN, line, now, id, status, opened, h.sensors[id])
+	}
+
+	h.drawPitch(o, now, line)
+}
+
+// Images par seconde & durée de la dernière image
+func (h *hud) drawFrames(o *opengl.Overlay, x float32, y float32, line float32) float32 {
+
+	frameTime := o.FrameTime()
+
+	o.Rect(x-4, y-2, HUD_PANEL_WIDTH, line+4, opengl.HUD_BACKGROUND)
+	o.Text(x, y, opengl.HUD_TEXT, "%3d fps %6.1f ms", o.Fps(), float64(frameTime)/float64(time.Millisecond))
+
+	return y + line + 2
+}
+
+func (h *hud) drawSensor(o *opengl.Overlay, x float32, y float32, line float32, now time.Time,
+	id string, status input.DeviceStatus, opened bool, sensor *hudSensor) float32 {
+
+	rows := 1
+	if opened {
+		rows++
+	}
+	if sensor != nil {
+		rows += 4
+	}
+
+	o.Rect(x-4, y-2, HUD_PANEL_WIDTH, float32(rows)*line+4, opengl.HUD_BACKGROUND)
+
+	name := h.nodes(id)
+	if name == "" {
+		name = "unbound"
+	}
+
+	// Santé de la liaison : connexion, trames reçues & erreurs, les valeurs
+	// d'un capteur non ouvert venant d'une session relue
+	health, state := opengl.HUD_GOOD, "connected"
+	silent := sensor == nil || now.Sub(sensor.received) > SAMPLE_TIMEOUT
+
+	switch {
+	case opened == false && silent:
+		health, state = opengl.HUD_ERROR, "closed"
+	case opened == false:
+		health, state = opengl.HUD_LABEL, "replay"
+	case status.Connected == false:
+		health, state = opengl.HUD_ERROR, "disconnected"
+	case silent:
+		health, state = opengl.HUD_ERROR, "no data"
+	case status.CrcErrorRate >= CRC_ERROR:
+		health, state = opengl.HUD_ERROR, "bad link"
+	case status.CrcErrorRate >= CRC_WARNING:
+		health, state = opengl.HUD_WARNING, "errors"
+	}
+
+	o.Rect(x, y+line/4, line/2, line/2, health)
+	o.Text(x+line, y, opengl.HUD_TEXT, "%s (%s) %s", id, name, state)
+	y += line
+
+	if opened {
+		o.Text(x, y, opengl.HUD_LABEL, "%5.1f frames/s  crc %.1f%%",
+			status.FrameRate, 100*status.CrcErrorRate)
+		y += line
+	}
+
+	if sensor == nil {
+		return y
+	}
+
+	// Âge de la dernière trame reçue
+	age := now.Sub(sensor.received)
+	ageColor := opengl.HUD_LABEL
+	if age > SAMPLE_TIMEOUT {
+		ageColor = opengl.HUD_ERROR
+	}
+	o.Text(x, y, ageColor, "age %s", formatAge(age))
+	y += line
+
+	angles := yawPitchRoll(sensor.values)
+
+	for idx, label := range []string{"yaw", "pitch", "roll"} {
+
+		limit := float32(180)
+		if label == "pitch" {
+			limit = 90
+		}
+
+		o.Text(x, y, opengl.HUD_LABEL, "%-5s", label)
+		o.Bar(x+48, y+line/4, HUD_BAR_WIDTH, line/2, angles[idx], -limit, limit, opengl.HUD_GOOD)
+		o.Sparkline(x+56+HUD_BAR_WIDTH, y+2, HUD_PANEL_WIDTH-72-HUD_BAR_WIDTH, line-4,
+			sensor.history[idx], -limit, limit, opengl.HUD_TEXT)
+		y += line
+	}
+
+	return y
+}
+
+func formatAge(age time.Duration) string {
+
+	if age < time.Second {
+		return fmt.Sprintf("%d ms", age/time.Millisecond)
+	}
+
+	return fmt.Sprintf("%.1f s", age.Seconds())
+}
+
+// Note détectée, justesse en cents & son historique, en haut à droite
+func (h *hud) drawPitch(o *opengl.Overlay, now time.Time, line float32) {
+
+	width := float32(HUD_BAR_WIDTH + 2*HUD_DIAL_SIZE)
+	x, y := o.Width()-width-HUD_MARGIN, float32(HUD_MARGIN)
+
+	o.Rect(x-4, y-2, width+8, 2*HUD_DIAL_SIZE+line+4, opengl.HUD_BACKGROUND)
+
+	if h.pitch == nil || now.Sub(h.detected) > PITCH_TIMEOUT {
+		o.Text(x, y, opengl.HUD_LABEL, "no pitch")
+		o.Dial(x+HUD_DIAL_SIZE, y+line+HUD_DIAL_SIZE, HUD_DIAL_SIZE-4, 0, -50, 50, opengl.HUD_TRACK)
+		o.Sparkline(x+2*HUD_DIAL_SIZE+4, y+line, HUD_BAR_WIDTH-4, 2*HUD_DIAL_SIZE-4, h.cents, -50, 50, opengl.HUD_LABEL)
+		return
+	}
+
+	note := h.pitch.Note
+	cents := float32(note.Cents)
+
+	color := opengl.HUD_ERROR
+	switch abs := math.Abs(note.Cents); {
+	case abs <= CENTS_GOOD:
+		color = opengl.HUD_GOOD
+	case abs <= CENTS_WARNING:
+		color = opengl.HUD_WARNING
+	}
+
+	o.Text(x, y, opengl.HUD_TEXT, "%s%d %7.2f Hz %+5.1f c",
+		note.Name, note.Octave, h.pitch.Frequency, note.Cents)
+	o.Dial(x+HUD_DIAL_SIZE, y+line+HUD_DIAL_SIZE, HUD_DIAL_SIZE-4, cents, -50, 50, color)
+	o.Sparkline(x+2*HUD_DIAL_SIZE+4, y+line, HUD_BAR_WIDTH-4, 2*HUD_DIAL_SIZE-4, h.cents, -50, 50, color)
+}
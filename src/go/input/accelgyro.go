@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"encoding/binary"
 	"fmt"
-	"github.com/tarm/serial"
 	"log"
 	"math"
 )
@@ -33,6 +32,9 @@ var INIT_STATUS []string = []string{
 }
 
 type AccelGyro struct {
+	// Identifiant du capteur ayant produit les valeurs
+	Device string
+
 	Status int
 
 	// Quaternion
@@ -97,42 +99,32 @@ func (a *AccelGyro) String() string {
 	return result
 }
 
-// Etablie la connexion avec le port série spécifié pour récupérer les
-// données provenant de l'accéléromètre & du gyroscope
-func AccelGyroSerial(device string, baudrate int) (chan *AccelGyro, error) {
-
-	// Création du channel
-	channel := make(chan *AccelGyro)
-
-	// Configuration du port série
-	c := &serial.Config{
-		Name: device,
-		Baud: baudrate,
-	}
-
-	// Ouverture
-	s, err := serial.OpenPort(c)
-	if err != nil {
-		return nil, err
-	}
+// Calcule le lacet, le tangage & le roulis (en radians) à partir du quaternion
+func (a *AccelGyro) YawPitchRoll() (yaw float64, pitch float64, roll float64) {
 
-	// Lecture
-	buf := bufio.NewReader(s)
+	w := float64(a.QuaternionW)
+	x := float64(a.QuaternionX)
+	y := float64(a.QuaternionY)
+	z := float64(a.QuaternionZ)
 
-	go fromBinary(buf, channel)
+	yaw = math.Atan2(2*(w*z+x*y), 1-2*(y*y+z*z))
+	pitch = math.Asin(math.Max(-1, math.Min(1, 2*(w*y-z*x))))
+	roll = math.Atan2(2*(w*x+y*z), 1-2*(x*x+y*y))
 
-	return channel, nil
+	return
 }
 
-var previousBuffer []byte
-
 // Réception des valeurs binaires OUTPUT_BINARY_ACCELGYRO
-func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
+func (d *Device) fromBinary(buf *bufio.Reader) {
 	var crc int
+	var previousBuffer []byte
+
+	defer close(d.channel)
 
 	for {
 		rcv, err := buf.ReadBytes('\n')
 		if err != nil {
+			d.closed(err)
 			return
 		}
 
@@ -172,6 +164,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 			expect := crc16(rcv)
 			if crc != expect {
 				log.Printf("invalid crc: got %04X, expect %04X\n", crc, expect)
+				d.crcError()
 				continue
 			}
 
@@ -181,12 +174,14 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 			// Récupération d'un status d'initialisation
 			if length == 2 && status < 5 {
 				log.Printf("%s: %d\n", INIT_STATUS[status], rcv[1])
+				d.initStatus(INIT_STATUS[status], int(rcv[1]))
 				continue
 			}
 
 			rcv = rcv[1:]
 
 			values := &AccelGyro{
+				Device: d.Id,
 				Status: int(status),
 			}
 
@@ -194,6 +189,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 16 {
 					log.Printf("Quaternion: invalid got %d expect %d\n", len(rcv), 16)
+					d.invalidFrame()
 					continue
 				}
 
@@ -209,6 +205,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 12 {
 					log.Printf("Euler: invalid got %d expect %d\n", len(rcv), 12)
+					d.invalidFrame()
 					continue
 				}
 
@@ -223,6 +220,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 12 {
 					log.Printf("Euler: invalid got %d expect %d\n", len(rcv), 12)
+					d.invalidFrame()
 					continue
 				}
 
@@ -237,6 +235,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 12 {
 					log.Printf("Euler: invalid got %d expect %d\n", len(rcv), 12)
+					d.invalidFrame()
 					continue
 				}
 
@@ -251,6 +250,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 12 {
 					log.Printf("Euler: invalid got %d expect %d\n", len(rcv), 12)
+					d.invalidFrame()
 					continue
 				}
 
@@ -265,6 +265,7 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 
 				if len(rcv) < 8 {
 					log.Printf("Buffer: invalid got %d expect %d\n", len(rcv), 8)
+					d.invalidFrame()
 					continue
 				}
 
@@ -276,7 +277,13 @@ func fromBinary(buf *bufio.Reader, channel chan *AccelGyro) {
 				rcv = rcv[8:]
 			}
 
-			channel <- values
+			d.frame()
+
+			select {
+			case d.channel <- values:
+			case <-d.done:
+				return
+			}
 		}
 	}
 }
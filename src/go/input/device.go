@@ -0,0 +1,252 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+const (
+	// Trames binaires produites par le firmware (OUTPUT_BINARY_ACCELGYRO)
+	PROTOCOL_BINARY = "binary"
+
+	DEFAULT_BAUDRATE = 38400
+	DEFAULT_PROTOCOL = PROTOCOL_BINARY
+)
+
+var PROTOCOLS = []string{PROTOCOL_BINARY}
+
+// Emplacements usuels des ports série des cartes Arduino
+var PORTS_PATTERNS = []string{
+	"/dev/ttyACM*",
+	"/dev/ttyUSB*",
+	"/dev/tty.usbmodem*",
+	"/dev/tty.usbserial*",
+}
+
+type DeviceStatus struct {
+	Id        string         `json:"id"`
+	Name      string         `json:"name"`
+	Baudrate  int            `json:"baudrate"`
+	Protocol  string         `json:"protocol"`
+	Connected bool           `json:"connected"`
+	Opened    time.Time      `json:"opened"`
+	Init      map[string]int `json:"init"`
+
+	Frames        uint64  `json:"frames"`
+	CrcErrors     uint64  `json:"crc_errors"`
+	InvalidFrames uint64  `json:"invalid_frames"`
+	FrameRate     float64 `json:"frame_rate"`
+	CrcErrorRate  float64 `json:"crc_error_rate"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Capteur connecté sur un port série
+type Device struct {
+	Id       string
+	Name     string
+	Baudrate int
+	Protocol string
+
+	port    io.ReadCloser
+	channel chan *AccelGyro
+	done    chan struct{}
+
+	mutex  sync.Mutex
+	status DeviceStatus
+	onInit func(d *Device, step string, value int)
+
+	// Calcul du nombre de trames par seconde
+	windowStart  time.Time
+	windowFrames uint64
+}
+
+// Liste les ports série susceptibles d'accueillir un capteur
+func ListPorts() []string {
+
+	var ports []string
+
+	for _, pattern := range PORTS_PATTERNS {
+		matches, _ := filepath.Glob(pattern)
+		ports = append(ports, matches...)
+	}
+
+	sort.Strings(ports)
+
+	return ports
+}
+
+// Etablie la connexion avec le port série spécifié pour récupérer les
+// données provenant de l'accéléromètre & du gyroscope. onInit, appelée à la
+// réception de chaque status d'initialisation, peut être nil.
+func OpenDevice(name string, baudrate int, protocol string,
+	onInit func(d *Device, step string, value int)) (d *Device, err error) {
+
+	if baudrate <= 0 {
+		baudrate = DEFAULT_BAUDRATE
+	}
+
+	if protocol == "" {
+		protocol = DEFAULT_PROTOCOL
+	}
+
+	if protocol != PROTOCOL_BINARY {
+		return nil, fmt.Errorf("unsupported protocol '%s'", protocol)
+	}
+
+	// Configuration du port série
+	c := &serial.Config{
+		Name: name,
+		Baud: baudrate,
+	}
+
+	// Ouverture
+	s, err := serial.OpenPort(c)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	d = &Device{
+		Id:       filepath.Base(name),
+		Name:     name,
+		Baudrate: baudrate,
+		Protocol: protocol,
+		port:     s,
+		channel:  make(chan *AccelGyro),
+		done:     make(chan struct{}),
+		status: DeviceStatus{
+			Id:        filepath.Base(name),
+			Name:      name,
+			Baudrate:  baudrate,
+			Protocol:  protocol,
+			Connected: true,
+			Opened:    now,
+			Init:      make(map[string]int),
+		},
+		windowStart: now,
+		onInit:      onInit,
+	}
+
+	// Lecture
+	go d.fromBinary(bufio.NewReader(s))
+
+	return
+}
+
+// Conservé pour compatibilité : retourne directement le channel des valeurs
+func AccelGyroSerial(device string, baudrate int) (chan *AccelGyro, error) {
+
+	d, err := OpenDevice(device, baudrate, DEFAULT_PROTOCOL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Samples(), nil
+}
+
+// Channel des valeurs reçues, fermé à la déconnexion du capteur
+func (d *Device) Samples() chan *AccelGyro {
+	return d.channel
+}
+
+func (d *Device) Close() error {
+
+	d.mutex.Lock()
+	select {
+	case <-d.done:
+		d.mutex.Unlock()
+		return nil
+	default:
+		close(d.done)
+	}
+	d.mutex.Unlock()
+
+	return d.port.Close()
+}
+
+// Etat de la connexion & statistiques de réception
+func (d *Device) Status() DeviceStatus {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	status := d.status
+
+	status.Init = make(map[string]int, len(d.status.Init))
+	for step, value := range d.status.Init {
+		status.Init[step] = value
+	}
+
+	total := status.Frames + status.CrcErrors + status.InvalidFrames
+	if total > 0 {
+		status.CrcErrorRate = float64(status.CrcErrors) / float64(total)
+	}
+
+	// Plus aucune trame reçue depuis la dernière mesure
+	if elapsed := time.Since(d.windowStart); elapsed > 2*time.Second {
+		status.FrameRate = float64(d.windowFrames) / elapsed.Seconds()
+	}
+
+	return status
+}
+
+func (d *Device) frame() {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.status.Frames++
+	d.windowFrames++
+
+	if elapsed := time.Since(d.windowStart); elapsed >= time.Second {
+		d.status.FrameRate = float64(d.windowFrames) / elapsed.Seconds()
+		d.windowFrames = 0
+		d.windowStart = time.Now()
+	}
+}
+
+func (d *Device) crcError() {
+	d.mutex.Lock()
+	d.status.CrcErrors++
+	d.mutex.Unlock()
+}
+
+func (d *Device) invalidFrame() {
+	d.mutex.Lock()
+	d.status.InvalidFrames++
+	d.mutex.Unlock()
+}
+
+func (d *Device) initStatus(step string, value int) {
+
+	d.mutex.Lock()
+	d.status.Init[step] = value
+	d.mutex.Unlock()
+
+	if d.onInit != nil {
+		d.onInit(d, step, value)
+	}
+}
+
+func (d *Device) closed(err error) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.status.Connected = false
+
+	select {
+	case <-d.done:
+	default:
+		d.status.Error = err.Error()
+	}
+}
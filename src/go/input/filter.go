@@ -0,0 +1,113 @@
+package input
+
+import (
+	"sync"
+)
+
+const ALL_OUTPUTS = QUATERNION | EULER | YAWPITCHROLL | REALACCEL | WORLDACCEL | BUFFER
+
+// Traitements appliqués côté hôte sur les valeurs reçues : tare de
+// l'orientation & masque des sorties transmises. Les sorties réellement
+// produites restent choisies à la compilation du firmware (OUTPUT_*).
+type Filter struct {
+	mutex     sync.Mutex
+	reference *AccelGyro
+	mask      int
+	tare      bool
+}
+
+func NewFilter() *Filter {
+	return &Filter{
+		mask: ALL_OUTPUTS,
+	}
+}
+
+// La prochaine orientation reçue servira de référence
+func (f *Filter) Tare() {
+	f.mutex.Lock()
+	f.tare = true
+	f.reference = nil
+	f.mutex.Unlock()
+}
+
+func (f *Filter) ResetTare() {
+	f.mutex.Lock()
+	f.tare = false
+	f.reference = nil
+	f.mutex.Unlock()
+}
+
+// Orientation de référence courante, nil sans tare
+func (f *Filter) Reference() *AccelGyro {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.reference == nil {
+		return nil
+	}
+
+	reference := *f.reference
+	return &reference
+}
+
+// Utilise l'orientation spécifiée comme référence (calibration)
+func (f *Filter) SetReference(reference *AccelGyro) {
+	f.mutex.Lock()
+	f.tare = true
+	f.reference = &AccelGyro{
+		Status:      QUATERNION,
+		QuaternionW: reference.QuaternionW,
+		QuaternionX: reference.QuaternionX,
+		QuaternionY: reference.QuaternionY,
+		QuaternionZ: reference.QuaternionZ,
+	}
+	f.mutex.Unlock()
+}
+
+func (f *Filter) SetMask(mask int) {
+	f.mutex.Lock()
+	f.mask = mask & ALL_OUTPUTS
+	f.mutex.Unlock()
+}
+
+func (f *Filter) Mask() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.mask
+}
+
+// Applique la tare & le masque sur les valeurs reçues
+func (f *Filter) Apply(values *AccelGyro) *AccelGyro {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	hasQuaternion := values.Status&(QUATERNION|BUFFER) > 0
+
+	if f.tare && hasQuaternion {
+
+		if f.reference == nil {
+			reference := *values
+			f.reference = &reference
+		}
+
+		// q' = conjugué(référence) * q
+		rw := f.reference.QuaternionW
+		rx := -f.reference.QuaternionX
+		ry := -f.reference.QuaternionY
+		rz := -f.reference.QuaternionZ
+
+		w, x, y, z := values.QuaternionW, values.QuaternionX,
+			values.QuaternionY, values.QuaternionZ
+
+		values.QuaternionW = rw*w - rx*x - ry*y - rz*z
+		values.QuaternionX = rw*x + rx*w + ry*z - rz*y
+		values.QuaternionY = rw*y - rx*z + ry*w + rz*x
+		values.QuaternionZ = rw*z + rx*y - ry*x + rz*w
+	}
+
+	values.Status &= f.mask
+
+	return values
+}
@@ -0,0 +1,173 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// Gère l'ensemble des capteurs connectés : les valeurs reçues & les status
+// d'initialisation sont transmis aux fonctions fournies
+type Manager struct {
+	OnOpen   func(device *Device)
+	OnSample func(device *Device, values *AccelGyro)
+	OnInit   func(device *Device, step string, value int)
+	OnClose  func(device *Device)
+
+	mutex   sync.Mutex
+	devices map[string]*Device
+
+	// Ports en cours d'ouverture
+	opening map[string]bool
+
+	// Capteurs fermés à la demande, jusqu'à leur prochaine ouverture
+	closed map[string]bool
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		devices: make(map[string]*Device),
+		opening: make(map[string]bool),
+		closed:  make(map[string]bool),
+	}
+}
+
+// Connexion à un nouveau capteur, remplaçant celui du même port s'il a été
+// déconnecté. Le port est ouvert hors du verrou pour ne pas bloquer Status.
+func (m *Manager) Open(name string, baudrate int, protocol string) (d *Device, err error) {
+
+	m.mutex.Lock()
+
+	if m.opening[name] {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("device %s already opening", name)
+	}
+
+	for _, device := range m.devices {
+		if device.Name == name && device.Status().Connected {
+			m.mutex.Unlock()
+			return nil, fmt.Errorf("device %s already opened", name)
+		}
+	}
+
+	m.opening[name] = true
+	m.mutex.Unlock()
+
+	d, err = OpenDevice(name, baudrate, protocol, m.initStatus)
+
+	m.mutex.Lock()
+	delete(m.opening, name)
+	if err == nil {
+		m.devices[d.Id] = d
+		delete(m.closed, d.Id)
+	}
+	m.mutex.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	if m.OnOpen != nil {
+		m.OnOpen(d)
+	}
+
+	go m.forward(d)
+
+	return
+}
+
+func (m *Manager) initStatus(d *Device, step string, value int) {
+	if m.OnInit != nil {
+		m.OnInit(d, step, value)
+	}
+}
+
+func (m *Manager) forward(d *Device) {
+
+	for values := range d.Samples() {
+		if m.OnSample != nil {
+			m.OnSample(d, values)
+		}
+	}
+
+	if m.OnClose != nil {
+		m.OnClose(d)
+	}
+}
+
+// Déconnexion du capteur, qui n'est pas rouvert automatiquement
+func (m *Manager) Close(id string) error {
+
+	m.mutex.Lock()
+	d, ok := m.devices[id]
+	delete(m.devices, id)
+	if ok {
+		m.closed[id] = true
+	}
+	m.mutex.Unlock()
+
+	if ok == false {
+		return ErrDeviceNotFound
+	}
+
+	return d.Close()
+}
+
+func (m *Manager) CloseAll() {
+
+	m.mutex.Lock()
+	devices := m.devices
+	m.devices = make(map[string]*Device)
+	m.mutex.Unlock()
+
+	for _, d := range devices {
+		d.Close()
+	}
+}
+
+// Le capteur a été fermé par Close & pas rouvert depuis
+func (m *Manager) Closed(id string) bool {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.closed[id]
+}
+
+func (m *Manager) Get(id string) (*Device, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	d, ok := m.devices[id]
+	if ok == false {
+		return nil, ErrDeviceNotFound
+	}
+
+	return d, nil
+}
+
+// Etat de l'ensemble des capteurs triés par identifiant
+func (m *Manager) Status() []DeviceStatus {
+
+	m.mutex.Lock()
+	devices := make([]*Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		devices = append(devices, d)
+	}
+	m.mutex.Unlock()
+
+	result := make([]DeviceStatus, len(devices))
+	for idx, d := range devices {
+		result[idx] = d.Status()
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+
+	return result
+}
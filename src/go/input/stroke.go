@@ -0,0 +1,85 @@
+package input
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	STROKE_DOWN = "down"
+	STROKE_UP   = "up"
+
+	// Vitesse angulaire minimale (rad/s) pour considérer que l'archet bouge
+	DEFAULT_STROKE_THRESHOLD = 0.5
+)
+
+type Stroke struct {
+	Device    string
+	Direction string
+	Speed     float64
+	Time      time.Time
+}
+
+// Détecte les changements de sens de l'archet à partir de la variation du
+// lacet calculé depuis le quaternion
+type StrokeDetector struct {
+	Threshold float64
+
+	previousYaw  float64
+	previousTime time.Time
+	direction    string
+}
+
+func NewStrokeDetector() *StrokeDetector {
+	return &StrokeDetector{
+		Threshold: DEFAULT_STROKE_THRESHOLD,
+	}
+}
+
+// Retourne un nouveau coup d'archet lorsque le sens change, nil sinon
+func (d *StrokeDetector) Update(values *AccelGyro, now time.Time) *Stroke {
+
+	if values.Status&(QUATERNION|BUFFER) == 0 {
+		return nil
+	}
+
+	yaw, _, _ := values.YawPitchRoll()
+
+	previousYaw, previousTime := d.previousYaw, d.previousTime
+	d.previousYaw, d.previousTime = yaw, now
+
+	if previousTime.IsZero() {
+		return nil
+	}
+
+	elapsed := now.Sub(previousTime).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+
+	// Différence d'angle ramenée entre -π et π
+	delta := math.Remainder(yaw-previousYaw, 2*math.Pi)
+	speed := delta / elapsed
+
+	if math.Abs(speed) < d.Threshold {
+		return nil
+	}
+
+	direction := STROKE_DOWN
+	if speed < 0 {
+		direction = STROKE_UP
+	}
+
+	if direction == d.direction {
+		return nil
+	}
+
+	d.direction = direction
+
+	return &Stroke{
+		Device:    values.Device,
+		Direction: direction,
+		Speed:     math.Abs(speed),
+		Time:      now,
+	}
+}
@@ -1,56 +1,187 @@
 package main
 
 import (
-	// "github.com/ohohleo/violin/api"
+	"context"
+	"flag"
 	"fmt"
-	"github.com/ohohleo/violin/input"
-	"github.com/ohohleo/violin/opengl"
 	"log"
-	//"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
 )
 
+// Modes de fonctionnement, combinables : violin serve,view3d,audio
+const (
+	VIEW3D    = "view3d"
+	SERVE     = "serve"
+	RECORD    = "record"
+	REPLAY    = "replay"
+	EXPORT    = "export"
+	RENDER    = "render"
+	CALIBRATE = "calibrate"
+	TUNE      = "tune"
+	AUDIO     = "audio"
+)
+
+type mode struct {
+	Name        string
+	Description string
+
+	// Le mode se termine de lui-même (sinon jusqu'à l'interruption)
+	Finite bool
+
+	// Nécessite les capteurs ou le client JACK
+	Sensors bool
+	Audio   bool
+}
+
+var MODES = []mode{
+	{Name: VIEW3D, Description: "display the sensors orientation in 3D", Sensors: true},
+	{Name: SERVE, Description: "serve the web pages, API, streams & WebSocket", Sensors: true},
+	{Name: RECORD, Description: "record a practice session until interrupted", Sensors: true},
+	{Name: REPLAY, Description: "publish the events of a recorded session again", Finite: true},
+	{Name: EXPORT, Description: "export a recorded session (-format, -o)", Finite: true},
+	{Name: RENDER, Description: "render a recorded session offscreen to PNG files (-o, -fps, -encoder)", Finite: true},
+	{Name: CALIBRATE, Description: "measure the orientation reference (-duration, -calibration)", Finite: true, Sensors: true},
+	{Name: TUNE, Description: "print the played note & its tuning deviation", Audio: true},
+	{Name: AUDIO, Description: "analyse the JACK input (-links, -display)", Audio: true},
+}
+
+func init() {
+	// L'affichage OpenGL doit rester sur le thread principal
+	runtime.LockOSThread()
+}
+
 func main() {
 
-	accelerometer, err := input.AccelGyroSerial("/dev/ttyACM0", 38400)
+	flag.Usage = usage
+
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		usage()
+		os.Exit(2)
+	}
+
+	modes, err := parseModes(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		usage()
+		os.Exit(2)
+	}
+
+	path := configPath(os.Args[2:])
+
+	c, err := loadConfig(path)
 	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	o := newOptions(flag.CommandLine, path, c)
+	flag.CommandLine.Parse(os.Args[2:])
+
+	if err = o.finish(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err = run(o, modes); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	// err = api.New()
+func usage() {
 
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
+	out := flag.CommandLine.Output()
 
-	// err = api.NewStream(accelerometer)
+	fmt.Fprintf(out, "usage: %s <mode>[,<mode>...] [flags]\n\nmodes:\n", os.Args[0])
+	for _, mode := range MODES {
+		fmt.Fprintf(out, "  %-10s %s\n", mode.Name, mode.Description)
+	}
+
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
 
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
+func parseModes(list string) (modes map[string]bool, err error) {
 
-	//go func() {
-	window, err := opengl.CreateWindow()
-	if err != nil {
-		panic(err)
+	modes = make(map[string]bool)
+
+	for _, name := range splitList(list) {
+
+		if _, ok := findMode(name); ok == false {
+			return nil, fmt.Errorf("unknown mode '%s'", name)
+		}
+
+		modes[name] = true
 	}
 
-	object := window.AddObject()
-	go func() {
-		for {
-			values := <-accelerometer
-			fmt.Printf("%s", values)
-			object.GetTransform().SetRotate(
-				values.QuaternionW,
-				values.QuaternionX,
-				values.QuaternionY,
-				values.QuaternionZ)
+	if len(modes) == 0 {
+		return nil, fmt.Errorf("no mode specified")
+	}
+
+	for _, name := range []string{EXPORT, RENDER} {
+		if modes[name] && len(modes) > 1 {
+			return nil, fmt.Errorf("%s can't be combined with other modes", name)
 		}
-	}()
+	}
+
+	return
+}
+
+func findMode(name string) (mode, bool) {
+
+	for _, mode := range MODES {
+		if mode.Name == name {
+			return mode, true
+		}
+	}
+
+	return mode{}, false
+}
+
+func run(o *options, modes map[string]bool) (err error) {
 
-	window.Start()
+	sensors, audio, finite := false, false, true
+	for name := range modes {
+		mode, _ := findMode(name)
+		sensors = sensors || mode.Sensors
+		audio = audio || mode.Audio
+		finite = finite && mode.Finite
+	}
+
+	// Un seul affichage peut occuper le thread principal
+	if modes[VIEW3D] && audio && o.Display.Graphs.Enabled {
+		return fmt.Errorf("%s and the audio display can't be used together", VIEW3D)
+	}
+
+	a, err := newApp(o, modes)
+	if err != nil {
+		return
+	}
+
+	if modes[EXPORT] {
+		return a.export()
+	}
 
-	//}()
+	if modes[RENDER] {
+		return a.render()
+	}
+
+	if err = a.addComponents(sensors, audio, finite); err != nil {
+		return
+	}
+
+	// Interruption par Ctrl-C ou par le système, une seconde interruption
+	// terminant immédiatement le programme
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	// log.Println("Listening :5000 ...")
-	// http.ListenAndServe(":5000", nil)
+	return a.supervisor.Run(ctx)
 }
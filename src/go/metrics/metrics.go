@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	COUNTER = "counter"
+	GAUGE   = "gauge"
+
+	CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Retourne les métriques à exposer au moment de la requête
+type Collector func() []*Metric
+
+// Regroupe les collecteurs & les expose au format texte de Prometheus
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+func NewCounter(name string, help string) *Metric {
+	return &Metric{Name: name, Help: help, Type: COUNTER}
+}
+
+func NewGauge(name string, help string) *Metric {
+	return &Metric{Name: name, Help: help, Type: GAUGE}
+}
+
+// Ajoute une valeur, les labels étant fournis sous forme de paires nom/valeur
+func (m *Metric) Add(value float64, labels ...string) *Metric {
+
+	sample := Sample{Value: value}
+
+	for idx := 0; idx+1 < len(labels); idx += 2 {
+		sample.Labels = append(sample.Labels, Label{
+			Name:  labels[idx],
+			Value: labels[idx+1],
+		})
+	}
+
+	m.Samples = append(m.Samples, sample)
+
+	return m
+}
+
+func (r *Registry) Register(collector Collector) {
+	r.mutex.Lock()
+	r.collectors = append(r.collectors, collector)
+	r.mutex.Unlock()
+}
+
+// Collecte l'ensemble des métriques, celles de même nom étant regroupées
+func (r *Registry) Gather() []*Metric {
+
+	r.mutex.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mutex.Unlock()
+
+	byName := make(map[string]*Metric)
+	var result []*Metric
+
+	for _, collector := range collectors {
+		for _, metric := range collector() {
+
+			if existing, ok := byName[metric.Name]; ok {
+				existing.Samples = append(existing.Samples, metric.Samples...)
+				continue
+			}
+
+			byName[metric.Name] = metric
+			result = append(result, metric)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+
+	writer := &countWriter{writer: bufio.NewWriter(w)}
+
+	for _, metric := range r.Gather() {
+
+		if metric.Help != "" {
+			fmt.Fprintf(writer, "# HELP %s %s\n", metric.Name, escapeHelp(metric.Help))
+		}
+
+		if metric.Type != "" {
+			fmt.Fprintf(writer, "# TYPE %s %s\n", metric.Name, metric.Type)
+		}
+
+		for _, sample := range metric.Samples {
+
+			io.WriteString(writer, metric.Name)
+
+			if len(sample.Labels) > 0 {
+				labels := make([]string, len(sample.Labels))
+				for idx, label := range sample.Labels {
+					labels[idx] = label.Name + "=\"" + escapeLabel(label.Value) + "\""
+				}
+
+				io.WriteString(writer, "{"+strings.Join(labels, ",")+"}")
+			}
+
+			io.WriteString(writer, " "+formatValue(sample.Value)+"\n")
+		}
+	}
+
+	if writer.err == nil {
+		writer.err = writer.writer.Flush()
+	}
+
+	return writer.count, writer.err
+}
+
+func (r *Registry) Handler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", CONTENT_TYPE)
+		r.WriteTo(w)
+	})
+}
+
+func formatValue(value float64) string {
+
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var helpReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+var labelReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\"", "\\\"")
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
+
+type countWriter struct {
+	writer *bufio.Writer
+	count  int64
+	err    error
+}
+
+func (w *countWriter) Write(data []byte) (int, error) {
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.writer.Write(data)
+	w.count += int64(n)
+	w.err = err
+
+	return n, err
+}
@@ -0,0 +1,70 @@
+package midi
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ohohleo/violin/events"
+)
+
+const RECORDER_BUFFER = 1024
+
+// Transcrit en direct les évènements du hub & écrit le fichier MIDI à l'arrêt
+type Recorder struct {
+	Path string
+
+	transcriber *Transcriber
+	subscriber  *events.Subscriber
+	done        chan struct{}
+}
+
+func NewRecorder(hub *events.Hub, path string, bow string) *Recorder {
+
+	r := &Recorder{
+		Path:        path,
+		transcriber: NewTranscriber(bow),
+		subscriber: hub.SubscribeAs("midi:"+path, RECORDER_BUFFER, hub.LastId(),
+			events.PITCH, events.LEVEL, events.STROKE, events.SAMPLE),
+		done: make(chan struct{}),
+	}
+
+	go r.record()
+
+	return r
+}
+
+func (r *Recorder) record() {
+
+	defer close(r.done)
+
+	for event := range r.subscriber.C {
+		r.transcriber.Process(event)
+	}
+}
+
+// Nombre d'évènements perdus
+func (r *Recorder) Dropped() uint64 {
+	return r.subscriber.Dropped()
+}
+
+// Arrête la transcription & écrit le fichier
+func (r *Recorder) Stop() (err error) {
+
+	r.subscriber.Close()
+	<-r.done
+
+	r.transcriber.Finish(time.Now())
+
+	file, err := os.Create(r.Path)
+	if err != nil {
+		return
+	}
+
+	if _, err = r.transcriber.File(filepath.Base(r.Path)).WriteTo(file); err != nil {
+		file.Close()
+		return
+	}
+
+	return file.Close()
+}
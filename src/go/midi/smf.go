@@ -0,0 +1,198 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// Standard MIDI File : écriture des fichiers au format 1
+
+const (
+	// Résolution & tempo par défaut : 480 ticks par noire à 120 bpm,
+	// soit 960 ticks par seconde
+	DEFAULT_DIVISION = 480
+	DEFAULT_TEMPO    = 500000
+
+	// Messages canal
+	NOTE_OFF       = 0x80
+	NOTE_ON        = 0x90
+	CONTROL_CHANGE = 0xb0
+	PITCH_BEND     = 0xe0
+
+	// Méta-évènements
+	META            = 0xff
+	META_TEXT       = 0x01
+	META_TRACK_NAME = 0x03
+	META_MARKER     = 0x06
+	META_END        = 0x2f
+	META_TEMPO      = 0x51
+
+	// Valeur centrale de la molette de hauteur sur 14 bits
+	PITCH_BEND_CENTER = 8192
+	PITCH_BEND_MAX    = 16383
+
+	// Registered Parameter Number de l'amplitude de la molette de hauteur
+	CC_DATA_ENTRY = 6
+	CC_RPN_LSB    = 100
+	CC_RPN_MSB    = 101
+)
+
+type File struct {
+	Division uint16
+	Tracks   []*Track
+}
+
+type Track struct {
+	events []event
+}
+
+type event struct {
+	tick  uint32
+	order int
+	data  []byte
+}
+
+func NewFile() *File {
+	return &File{
+		Division: DEFAULT_DIVISION,
+	}
+}
+
+func (f *File) AddTrack(name string) *Track {
+
+	track := new(Track)
+	if name != "" {
+		track.Meta(0, META_TRACK_NAME, []byte(name))
+	}
+
+	f.Tracks = append(f.Tracks, track)
+	return track
+}
+
+func (t *Track) add(tick uint32, data ...byte) {
+	t.events = append(t.events, event{
+		tick:  tick,
+		order: len(t.events),
+		data:  data,
+	})
+}
+
+func (t *Track) NoteOn(tick uint32, channel, key, velocity uint8) {
+	t.add(tick, NOTE_ON|channel&0x0f, key&0x7f, velocity&0x7f)
+}
+
+func (t *Track) NoteOff(tick uint32, channel, key uint8) {
+	t.add(tick, NOTE_OFF|channel&0x0f, key&0x7f, 0)
+}
+
+func (t *Track) ControlChange(tick uint32, channel, controller, value uint8) {
+	t.add(tick, CONTROL_CHANGE|channel&0x0f, controller&0x7f, value&0x7f)
+}
+
+// Valeur sur 14 bits, PITCH_BEND_CENTER correspondant à l'absence de décalage
+func (t *Track) PitchBend(tick uint32, channel uint8, value uint16) {
+
+	if value > PITCH_BEND_MAX {
+		value = PITCH_BEND_MAX
+	}
+
+	t.add(tick, PITCH_BEND|channel&0x0f, uint8(value&0x7f), uint8(value>>7))
+}
+
+// Amplitude de la molette de hauteur en demi-tons
+func (t *Track) PitchBendRange(tick uint32, channel, semitones uint8) {
+	t.ControlChange(tick, channel, CC_RPN_MSB, 0)
+	t.ControlChange(tick, channel, CC_RPN_LSB, 0)
+	t.ControlChange(tick, channel, CC_DATA_ENTRY, semitones)
+	t.ControlChange(tick, channel, CC_RPN_MSB, 127)
+	t.ControlChange(tick, channel, CC_RPN_LSB, 127)
+}
+
+func (t *Track) Text(tick uint32, text string) {
+	t.Meta(tick, META_TEXT, []byte(text))
+}
+
+func (t *Track) Marker(tick uint32, text string) {
+	t.Meta(tick, META_MARKER, []byte(text))
+}
+
+// Durée d'une noire en microsecondes
+func (t *Track) Tempo(tick uint32, microseconds uint32) {
+	t.Meta(tick, META_TEMPO,
+		[]byte{byte(microseconds >> 16), byte(microseconds >> 8), byte(microseconds)})
+}
+
+func (t *Track) Meta(tick uint32, kind uint8, data []byte) {
+	message := append([]byte{META, kind}, variableLength(uint32(len(data)))...)
+	t.add(tick, append(message, data...)...)
+}
+
+// Ecrit le fichier : les évènements de chaque piste sont triés par date en
+// conservant l'ordre d'ajout des évènements simultanés
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("MThd")
+	binary.Write(&buffer, binary.BigEndian, uint32(6))
+	binary.Write(&buffer, binary.BigEndian, uint16(1))
+	binary.Write(&buffer, binary.BigEndian, uint16(len(f.Tracks)))
+	binary.Write(&buffer, binary.BigEndian, f.Division)
+
+	for _, track := range f.Tracks {
+		data := track.encode()
+
+		buffer.WriteString("MTrk")
+		binary.Write(&buffer, binary.BigEndian, uint32(len(data)))
+		buffer.Write(data)
+	}
+
+	writer := bufio.NewWriter(w)
+
+	count, err := buffer.WriteTo(writer)
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.Flush()
+}
+
+func (t *Track) encode() []byte {
+
+	events := make([]event, len(t.events))
+	copy(events, t.events)
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].tick != events[j].tick {
+			return events[i].tick < events[j].tick
+		}
+		return events[i].order < events[j].order
+	})
+
+	var data []byte
+	var last uint32
+
+	for _, event := range events {
+		data = append(data, variableLength(event.tick-last)...)
+		data = append(data, event.data...)
+		last = event.tick
+	}
+
+	// Fin de piste
+	return append(data, 0, META, META_END, 0)
+}
+
+// Quantité de longueur variable : 7 bits par octet, poids fort en premier
+func variableLength(value uint32) []byte {
+
+	result := []byte{byte(value & 0x7f)}
+
+	for value >>= 7; value > 0; value >>= 7 {
+		result = append([]byte{byte(value&0x7f | 0x80)}, result...)
+	}
+
+	return result
+}
@@ -0,0 +1,178 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVariableLength(t *testing.T) {
+
+	for _, test := range []struct {
+		value    uint32
+		expected []byte
+	}{
+		{0, []byte{0x00}},
+		{0x40, []byte{0x40}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x2000, []byte{0xc0, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{0x1fffff, []byte{0xff, 0xff, 0x7f}},
+		{0x200000, []byte{0x81, 0x80, 0x80, 0x00}},
+		{0x0fffffff, []byte{0xff, 0xff, 0xff, 0x7f}},
+	} {
+		if result := variableLength(test.value); bytes.Equal(result, test.expected) == false {
+			t.Errorf("%#x: % x, expect % x", test.value, result, test.expected)
+		}
+	}
+}
+
+func TestTrackEncode(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		events   func(track *Track)
+		expected []byte
+	}{
+		{
+			"empty",
+			func(track *Track) {},
+			[]byte{0x00, META, META_END, 0x00},
+		},
+		{
+			"delta times",
+			func(track *Track) {
+				track.NoteOn(0, 0, 69, 100)
+				track.NoteOff(480, 0, 69)
+				track.NoteOn(480+200, 1, 71, 64)
+			},
+			[]byte{
+				0x00, NOTE_ON, 69, 100,
+				0x83, 0x60, NOTE_OFF, 69, 0,
+				0x81, 0x48, NOTE_ON | 1, 71, 64,
+				0x00, META, META_END, 0x00,
+			},
+		},
+		{
+			"sorted by date, simultaneous events in order",
+			func(track *Track) {
+				track.NoteOff(10, 0, 69)
+				track.ControlChange(0, 0, 20, 127)
+				track.NoteOn(0, 0, 69, 80)
+				track.Marker(10, "up")
+			},
+			[]byte{
+				0x00, CONTROL_CHANGE, 20, 127,
+				0x00, NOTE_ON, 69, 80,
+				0x0a, NOTE_OFF, 69, 0,
+				0x00, META, META_MARKER, 0x02, 'u', 'p',
+				0x00, META, META_END, 0x00,
+			},
+		},
+		{
+			"tempo",
+			func(track *Track) {
+				track.Tempo(0, DEFAULT_TEMPO)
+			},
+			[]byte{
+				0x00, META, META_TEMPO, 0x03, 0x07, 0xa1, 0x20,
+				0x00, META, META_END, 0x00,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			track := new(Track)
+			test.events(track)
+
+			if data := track.encode(); bytes.Equal(data, test.expected) == false {
+				t.Errorf("% x, expect % x", data, test.expected)
+			}
+		})
+	}
+}
+
+func TestPitchBend(t *testing.T) {
+
+	for _, test := range []struct {
+		value    uint16
+		expected []byte
+	}{
+		{0, []byte{PITCH_BEND, 0x00, 0x00}},
+		{PITCH_BEND_CENTER, []byte{PITCH_BEND, 0x00, 0x40}},
+		{PITCH_BEND_MAX, []byte{PITCH_BEND, 0x7f, 0x7f}},
+		{PITCH_BEND_MAX + 100, []byte{PITCH_BEND, 0x7f, 0x7f}},
+	} {
+		track := new(Track)
+		track.PitchBend(0, 0, test.value)
+
+		if data := track.events[0].data; bytes.Equal(data, test.expected) == false {
+			t.Errorf("%d: % x, expect % x", test.value, data, test.expected)
+		}
+	}
+}
+
+// RPN 0,0 sélectionné, amplitude transmise puis RPN désélectionné
+func TestPitchBendRange(t *testing.T) {
+
+	track := new(Track)
+	track.PitchBendRange(0, 2, BEND_RANGE)
+
+	expected := []byte{
+		0x00, CONTROL_CHANGE | 2, CC_RPN_MSB, 0,
+		0x00, CONTROL_CHANGE | 2, CC_RPN_LSB, 0,
+		0x00, CONTROL_CHANGE | 2, CC_DATA_ENTRY, BEND_RANGE,
+		0x00, CONTROL_CHANGE | 2, CC_RPN_MSB, 127,
+		0x00, CONTROL_CHANGE | 2, CC_RPN_LSB, 127,
+		0x00, META, META_END, 0x00,
+	}
+
+	if data := track.encode(); bytes.Equal(data, expected) == false {
+		t.Errorf("% x, expect % x", data, expected)
+	}
+}
+
+func TestFileWrite(t *testing.T) {
+
+	file := NewFile()
+	file.AddTrack("tempo").Tempo(0, DEFAULT_TEMPO)
+	file.AddTrack("").NoteOn(0, 0, 69, 100)
+
+	var buffer bytes.Buffer
+	if _, err := file.WriteTo(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer.Bytes()
+
+	header := []byte{'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 1, 0, 2, 0x01, 0xe0}
+	if bytes.HasPrefix(data, header) == false {
+		t.Fatalf("header % x, expect % x", data[:len(header)], header)
+	}
+
+	// Chaque piste est précédée de sa taille
+	data = data[len(header):]
+	for idx, track := range file.Tracks {
+
+		if len(data) < 8 || string(data[:4]) != "MTrk" {
+			t.Fatalf("track %d: missing MTrk", idx)
+		}
+
+		encoded := track.encode()
+		if size := binary.BigEndian.Uint32(data[4:]); int(size) != len(encoded) {
+			t.Errorf("track %d: size %d, expect %d", idx, size, len(encoded))
+		}
+
+		if bytes.Equal(data[8:8+len(encoded)], encoded) == false {
+			t.Errorf("track %d: % x, expect % x", idx, data[8:8+len(encoded)], encoded)
+		}
+
+		data = data[8+len(encoded):]
+	}
+
+	if len(data) > 0 {
+		t.Errorf("%d trailing bytes", len(data))
+	}
+}
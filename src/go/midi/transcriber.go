@@ -0,0 +1,390 @@
+package midi
+
+import (
+	"math"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+)
+
+const (
+	// Durée minimale d'une hauteur stable pour débuter une note
+	MIN_NOTE_DURATION = 40 * time.Millisecond
+
+	// Durée de silence terminant une note
+	RELEASE_DURATION = 60 * time.Millisecond
+
+	// Augmentation du niveau entre deux périodes considérée comme une attaque
+	ONSET_DB = 9.0
+
+	// Fenêtre de calcul de la vélocité à partir du début de la note
+	VELOCITY_WINDOW = 100 * time.Millisecond
+
+	// Amplitude de la molette de hauteur & écart minimal entre deux valeurs
+	BEND_RANGE      = 2
+	BEND_RESOLUTION = 5.0
+
+	// Contrôleurs utilisés pour les annotations de l'archet
+	CC_BOW_DIRECTION = 20
+	CC_STRING        = 21
+
+	CHANNEL = 0
+
+	// Tempo par défaut : 960 ticks par seconde
+	TICKS_PER_SECOND = DEFAULT_DIVISION * 1000000 / DEFAULT_TEMPO
+)
+
+type String struct {
+	Name string
+	Midi int
+}
+
+// Cordes du violon, de la plus grave à la plus aiguë
+var STRINGS = []String{{"G", 55}, {"D", 62}, {"A", 69}, {"E", 76}}
+
+// Inclinaison de l'archet (roulis en radians) séparant deux cordes voisines
+var DEFAULT_STRING_ANGLES = []float64{-0.25, 0, 0.25}
+
+type Note struct {
+	Start    time.Time
+	End      time.Time
+	Midi     int
+	Velocity int
+
+	// Corde estimée depuis l'inclinaison de l'archet, -1 si inconnue
+	String int
+
+	// Changement de sens de l'archet au début de la note, vide sinon
+	Bow string
+
+	Bends []Bend
+
+	peak float64
+}
+
+// Ecart de justesse en cents par rapport à la note
+type Bend struct {
+	Time  time.Time
+	Cents float64
+}
+
+type candidate struct {
+	midi  int
+	start time.Time
+}
+
+// Découpe en notes le flux de hauteurs & de niveaux de l'analyse audio,
+// annotées par les changements de sens & l'inclinaison de l'archet
+type Transcriber struct {
+	// Identifiant du capteur fixé sur l'archet, vide pour tous les capteurs
+	Bow string
+
+	// Limites d'inclinaison entre les cordes
+	StringAngles []float64
+
+	start time.Time
+	notes []*Note
+
+	active    *Note
+	candidate *candidate
+	level     float64
+	silence   time.Time
+	bow       string
+	roll      float64
+	rollKnown bool
+}
+
+func NewTranscriber(bow string) *Transcriber {
+	return &Transcriber{
+		Bow:          bow,
+		StringAngles: DEFAULT_STRING_ANGLES,
+		level:        audio.SILENCE_LEVEL_DB,
+	}
+}
+
+// Traite un évènement du hub : hauteur, niveau, coup d'archet ou orientation
+func (t *Transcriber) Process(event *events.Event) {
+
+	if t.start.IsZero() {
+		t.start = event.Time
+	}
+
+	switch data := event.Data.(type) {
+	case *audio.Pitch:
+		t.pitch(event.Time, data)
+	case audio.Level:
+		t.levelChange(event.Time, data)
+	case *input.Stroke:
+		t.stroke(event.Time, data)
+	case *input.AccelGyro:
+		t.sample(data)
+	}
+}
+
+func (t *Transcriber) pitch(now time.Time, pitch *audio.Pitch) {
+
+	if pitch.Note == nil || t.level < audio.MIN_LEVEL {
+		t.candidate = nil
+		return
+	}
+
+	midi := pitch.Note.Midi
+
+	if t.active != nil && t.active.Midi == midi {
+
+		t.candidate = nil
+		t.bend(now, pitch.Note.Cents)
+		return
+	}
+
+	// La nouvelle hauteur doit être stable pour éviter les notes parasites
+	if t.candidate == nil || t.candidate.midi != midi {
+		t.candidate = &candidate{midi: midi, start: now}
+		return
+	}
+
+	if now.Sub(t.candidate.start) < MIN_NOTE_DURATION {
+		return
+	}
+
+	t.noteOn(t.candidate.start, midi)
+	t.candidate = nil
+	t.bend(now, pitch.Note.Cents)
+}
+
+func (t *Transcriber) levelChange(now time.Time, level audio.Level) {
+
+	previous := t.level
+	t.level = level.Db
+
+	if t.active == nil {
+		return
+	}
+
+	if level.Db < audio.MIN_LEVEL {
+
+		if t.silence.IsZero() {
+			t.silence = now
+		} else if now.Sub(t.silence) >= RELEASE_DURATION {
+			t.noteOff(t.silence)
+		}
+
+		return
+	}
+
+	t.silence = time.Time{}
+
+	// Nouvelle attaque sur la même hauteur
+	if level.Db-previous >= ONSET_DB && now.Sub(t.active.Start) >= MIN_NOTE_DURATION {
+		t.noteOn(now, t.active.Midi)
+	}
+
+	if now.Sub(t.active.Start) <= VELOCITY_WINDOW && level.Db > t.active.peak {
+		t.active.peak = level.Db
+	}
+}
+
+func (t *Transcriber) stroke(now time.Time, stroke *input.Stroke) {
+
+	if t.Bow != "" && stroke.Device != t.Bow {
+		return
+	}
+
+	t.bow = stroke.Direction
+
+	// Un changement d'archet rejoue la note en cours
+	if t.active != nil && now.Sub(t.active.Start) >= MIN_NOTE_DURATION {
+		t.noteOn(now, t.active.Midi)
+	}
+}
+
+func (t *Transcriber) sample(values *input.AccelGyro) {
+
+	if t.Bow != "" && values.Device != t.Bow {
+		return
+	}
+
+	if values.Status&(input.QUATERNION|input.BUFFER) > 0 {
+		_, _, t.roll = values.YawPitchRoll()
+		t.rollKnown = true
+	}
+}
+
+func (t *Transcriber) noteOn(now time.Time, midi int) {
+
+	bow := t.bow
+
+	if t.active != nil {
+
+		// La note en cours n'a pas eu le temps de sonner : elle est remplacée
+		if now.After(t.active.Start) == false {
+			if bow == "" {
+				bow = t.active.Bow
+			}
+			t.notes = t.notes[:len(t.notes)-1]
+		} else {
+			t.noteOff(now)
+		}
+	}
+
+	t.active = &Note{
+		Start:  now,
+		Midi:   midi,
+		String: t.string(midi),
+		Bow:    bow,
+		peak:   t.level,
+	}
+
+	t.bow = ""
+	t.silence = time.Time{}
+	t.notes = append(t.notes, t.active)
+}
+
+func (t *Transcriber) noteOff(now time.Time) {
+
+	t.active.End = now
+	t.active.Velocity = velocity(t.active.peak)
+	t.active = nil
+}
+
+func (t *Transcriber) bend(now time.Time, cents float64) {
+
+	bends := t.active.Bends
+	if len(bends) > 0 && math.Abs(bends[len(bends)-1].Cents-cents) < BEND_RESOLUTION {
+		return
+	}
+
+	t.active.Bends = append(bends, Bend{Time: now, Cents: cents})
+}
+
+// Corde désignée par l'inclinaison de l'archet, ramenée à une corde
+// permettant de jouer la note
+func (t *Transcriber) string(midi int) int {
+
+	if t.rollKnown == false {
+		return -1
+	}
+
+	index := 0
+	for _, angle := range t.StringAngles {
+		if t.roll > angle {
+			index++
+		}
+	}
+
+	if index >= len(STRINGS) {
+		index = len(STRINGS) - 1
+	}
+
+	for index > 0 && STRINGS[index].Midi > midi {
+		index--
+	}
+
+	return index
+}
+
+// Termine la note en cours
+func (t *Transcriber) Finish(now time.Time) {
+
+	if t.active != nil {
+		t.noteOff(now)
+	}
+
+	t.candidate = nil
+}
+
+func (t *Transcriber) Notes() []*Note {
+	return t.notes
+}
+
+// Vélocité proportionnelle au niveau crête du début de la note
+func velocity(db float64) int {
+
+	value := 1 + int(math.Round((db-audio.MIN_LEVEL)/-audio.MIN_LEVEL*126))
+
+	if value < 1 {
+		return 1
+	}
+
+	if value > 127 {
+		return 127
+	}
+
+	return value
+}
+
+// Génère le fichier MIDI des notes transcrites
+func (t *Transcriber) File(name string) *File {
+
+	file := NewFile()
+
+	tempo := file.AddTrack(name)
+	tempo.Tempo(0, DEFAULT_TEMPO)
+
+	track := file.AddTrack("violin")
+	track.PitchBendRange(0, CHANNEL, BEND_RANGE)
+
+	lastString := -1
+
+	for _, note := range t.notes {
+
+		start := t.tick(note.Start)
+		end := t.tick(note.End)
+		if end <= start {
+			end = start + 1
+		}
+
+		if note.Bow != "" {
+			value := uint8(0)
+			if note.Bow == input.STROKE_DOWN {
+				value = 127
+			}
+
+			track.ControlChange(start, CHANNEL, CC_BOW_DIRECTION, value)
+			track.Text(start, "bow "+note.Bow)
+		}
+
+		if note.String >= 0 && note.String != lastString {
+			track.ControlChange(start, CHANNEL, CC_STRING, uint8(note.String))
+			track.Text(start, "string "+STRINGS[note.String].Name)
+			lastString = note.String
+		}
+
+		// Justesse du début de note
+		cents := 0.0
+		if len(note.Bends) > 0 && t.tick(note.Bends[0].Time) <= start {
+			cents = note.Bends[0].Cents
+		}
+
+		track.PitchBend(start, CHANNEL, bendValue(cents))
+		track.NoteOn(start, CHANNEL, uint8(note.Midi), uint8(note.Velocity))
+
+		for _, bend := range note.Bends {
+			if tick := t.tick(bend.Time); tick > start && tick < end {
+				track.PitchBend(tick, CHANNEL, bendValue(bend.Cents))
+			}
+		}
+
+		track.NoteOff(end, CHANNEL, uint8(note.Midi))
+	}
+
+	return file
+}
+
+func (t *Transcriber) tick(date time.Time) uint32 {
+
+	if date.Before(t.start) {
+		return 0
+	}
+
+	return uint32(date.Sub(t.start).Seconds() * TICKS_PER_SECOND)
+}
+
+func bendValue(cents float64) uint16 {
+
+	value := PITCH_BEND_CENTER + cents/(BEND_RANGE*100)*PITCH_BEND_CENTER
+
+	return uint16(math.Max(0, math.Min(PITCH_BEND_MAX, math.Round(value))))
+}
@@ -0,0 +1,261 @@
+package midi
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+)
+
+const PERIOD = 10 * time.Millisecond
+
+var origin = time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func at(milliseconds int) time.Time {
+	return origin.Add(time.Duration(milliseconds) * time.Millisecond)
+}
+
+// Flux synthétique de l'analyse audio : une hauteur & un niveau toutes les
+// PERIOD, midi 0 pour l'absence de note
+type segment struct {
+	from  int
+	to    int
+	midi  int
+	cents float64
+	db    float64
+}
+
+func stream(segments ...segment) (result []*events.Event) {
+
+	for _, segment := range segments {
+		for ms := segment.from; ms < segment.to; ms += int(PERIOD / time.Millisecond) {
+
+			result = append(result, &events.Event{
+				Time: at(ms),
+				Data: audio.Level{Db: segment.db},
+			})
+
+			pitch := &audio.Pitch{}
+			if segment.midi > 0 {
+				pitch.Note = &audio.Note{Midi: segment.midi, Cents: segment.cents}
+			}
+
+			result = append(result, &events.Event{Time: at(ms), Data: pitch})
+		}
+	}
+
+	return
+}
+
+func transcribe(t *testing.T, end int, stream []*events.Event) []*Note {
+
+	transcriber := NewTranscriber("")
+
+	for _, event := range stream {
+		transcriber.Process(event)
+	}
+
+	transcriber.Finish(at(end))
+
+	return transcriber.Notes()
+}
+
+func TestTranscriberSegmentation(t *testing.T) {
+
+	type expected struct {
+		midi  int
+		start int
+		end   int
+	}
+
+	for _, test := range []struct {
+		name   string
+		stream []*events.Event
+		end    int
+		notes  []expected
+	}{
+		{
+			"single note until finish",
+			stream(segment{0, 300, 69, 0, -20}),
+			300,
+			[]expected{{69, 0, 300}},
+		},
+		{
+			"silence ends the note",
+			stream(
+				segment{0, 200, 69, 0, -20},
+				segment{200, 400, 0, 0, -80}),
+			400,
+			[]expected{{69, 0, 200}},
+		},
+		{
+			"pitch dropout ignored",
+			stream(
+				segment{0, 100, 69, 0, -20},
+				segment{100, 140, 0, 0, -25},
+				segment{140, 300, 69, 0, -20}),
+			300,
+			[]expected{{69, 0, 300}},
+		},
+		{
+			"short silence attacks again",
+			stream(
+				segment{0, 100, 69, 0, -20},
+				segment{100, 140, 0, 0, -80},
+				segment{140, 300, 69, 0, -20}),
+			300,
+			[]expected{{69, 0, 140}, {69, 140, 300}},
+		},
+		{
+			"pitch change",
+			stream(
+				segment{0, 200, 69, 0, -20},
+				segment{200, 400, 71, 0, -20}),
+			400,
+			[]expected{{69, 0, 200}, {71, 200, 400}},
+		},
+		{
+			"short pitch ignored",
+			stream(
+				segment{0, 100, 69, 0, -20},
+				segment{100, 130, 72, 0, -20},
+				segment{130, 300, 69, 0, -20}),
+			300,
+			[]expected{{69, 0, 300}},
+		},
+		{
+			"attack on the same pitch",
+			stream(
+				segment{0, 200, 69, 0, -40},
+				segment{200, 400, 69, 0, -20}),
+			400,
+			[]expected{{69, 0, 200}, {69, 200, 400}},
+		},
+		{
+			"pitch under the minimum level ignored",
+			stream(segment{0, 300, 69, 0, -60}),
+			300,
+			nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			notes := transcribe(t, test.end, test.stream)
+
+			if len(notes) != len(test.notes) {
+				t.Fatalf("%d notes, expect %d", len(notes), len(test.notes))
+			}
+
+			for idx, note := range notes {
+				want := test.notes[idx]
+
+				if note.Midi != want.midi || note.Start.Equal(at(want.start)) == false ||
+					note.End.Equal(at(want.end)) == false {
+					t.Errorf("note %d: %d %s-%s, expect %d %dms-%dms", idx, note.Midi,
+						note.Start.Sub(origin), note.End.Sub(origin), want.midi, want.start, want.end)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscriberVelocity(t *testing.T) {
+
+	for _, test := range []struct {
+		db       float64
+		velocity int
+	}{
+		{audio.MIN_LEVEL, 1},
+		{-20, 77},
+		{0, 127},
+		{10, 127},
+	} {
+		if velocity := velocity(test.db); velocity != test.velocity {
+			t.Errorf("%g dB: velocity %d, expect %d", test.db, velocity, test.velocity)
+		}
+	}
+
+	notes := transcribe(t, 300, stream(segment{0, 300, 69, 0, -20}))
+	if len(notes) != 1 || notes[0].Velocity != 77 {
+		t.Errorf("notes %+v, expect velocity 77", notes)
+	}
+}
+
+func TestTranscriberBends(t *testing.T) {
+
+	notes := transcribe(t, 300, stream(
+		segment{0, 100, 69, 2, -20},
+		segment{100, 200, 69, 4, -20},
+		segment{200, 300, 69, 20, -20}))
+
+	if len(notes) != 1 {
+		t.Fatalf("%d notes, expect 1", len(notes))
+	}
+
+	// Les écarts inférieurs à BEND_RESOLUTION sont ignorés
+	bends := notes[0].Bends
+	if len(bends) != 2 || bends[0].Cents != 2 || bends[1].Cents != 20 || bends[1].Time.Equal(at(200)) == false {
+		t.Errorf("bends %+v, expect 2 cents then 20 cents at 200ms", bends)
+	}
+
+	for _, test := range []struct {
+		cents float64
+		value uint16
+	}{
+		{0, PITCH_BEND_CENTER},
+		{100, 12288},
+		{-100, 4096},
+		{200, PITCH_BEND_MAX},
+		{-300, 0},
+	} {
+		if value := bendValue(test.cents); value != test.value {
+			t.Errorf("%g cents: %d, expect %d", test.cents, value, test.value)
+		}
+	}
+}
+
+func TestTranscriberBow(t *testing.T) {
+
+	samples := stream(segment{0, 300, 69, 0, -20})
+	transcriber := NewTranscriber("bow")
+
+	for _, event := range samples {
+		transcriber.Process(event)
+
+		if event.Time.Equal(at(150)) {
+			transcriber.Process(&events.Event{Time: at(150), Data: &input.Stroke{Device: "violin", Direction: input.STROKE_UP}})
+			transcriber.Process(&events.Event{Time: at(150), Data: &input.Stroke{Device: "bow", Direction: input.STROKE_DOWN}})
+		}
+	}
+
+	transcriber.Finish(at(300))
+
+	// Le changement de sens rejoue la note, le capteur du violon est ignoré
+	notes := transcriber.Notes()
+	if len(notes) != 2 || notes[1].Start.Equal(at(150)) == false || notes[1].Bow != input.STROKE_DOWN {
+		t.Fatalf("notes %+v, expect a second note at 150ms with bow down", notes)
+	}
+
+	// Annotation & note dans la piste du fichier
+	file := transcriber.File("test")
+	if len(file.Tracks) != 2 {
+		t.Fatalf("%d tracks, expect 2", len(file.Tracks))
+	}
+
+	data := file.Tracks[1].encode()
+	start := variableLength(150 * TICKS_PER_SECOND / 1000)
+
+	for _, expected := range [][]byte{
+		{CONTROL_CHANGE | CHANNEL, CC_BOW_DIRECTION, 127},
+		{META, META_TEXT, 8, 'b', 'o', 'w', ' ', 'd', 'o', 'w', 'n'},
+		append(append([]byte(nil), start...), NOTE_OFF|CHANNEL, 69, 0),
+		{NOTE_ON | CHANNEL, 69, 77},
+	} {
+		if bytes.Contains(data, expected) == false {
+			t.Errorf("track % x misses % x", data, expected)
+		}
+	}
+}
@@ -0,0 +1,936 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/ohohleo/violin/api"
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/config"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/opengl"
+	"github.com/ohohleo/violin/sessions"
+	"github.com/ohohleo/violin/supervisor"
+)
+
+const (
+	SHUTDOWN_TIMEOUT = 5 * time.Second
+
+	// Largeur de l'indicateur de justesse du mode tune (±50 cents)
+	TUNE_METER_WIDTH = 41
+
+	// Images du mode render
+	DEFAULT_RENDER_DIR = "frames"
+	DEFAULT_FPS        = 30
+
+	// Constante de temps du filtre ramenant la vitesse & le déplacement des
+	// liaisons "position" vers zéro, compensant la dérive de l'intégration
+	POSITION_DECAY = time.Second
+
+	// Intervalle entre deux valeurs au-delà duquel l'intégration reprend
+	// sans tenir compte de la précédente
+	MAX_SAMPLE_GAP = 100 * time.Millisecond
+)
+
+// Orientation des capteurs appliquée aux repères de la scène, sur le thread
+// principal
+func (a *app) view3d() supervisor.Component {
+	return supervisor.Component{
+		Name: VIEW3D,
+		Main: true,
+		Run:  a.runView3d,
+	}
+}
+
+func (a *app) runView3d(ctx context.Context) error {
+
+	window, err := opengl.CreateWindow(viewOptions(a.options.Display.View3D))
+	if err != nil {
+		return err
+	}
+	defer window.Stop()
+
+	instruments, err := a.addInstruments(window.Scene)
+	if err != nil {
+		return err
+	}
+
+	var display *hud
+	if view := a.options.Display.View3D; view.Hud.Enabled {
+		if display, err = a.addHud(window.Scene, view.Hud, instruments); err != nil {
+			return err
+		}
+	}
+
+	subscriber := a.hub.SubscribeAs(VIEW3D, events.DEFAULT_BUFFER, a.hub.LastId(),
+		events.SAMPLE, events.STROKE, events.PITCH)
+	defer subscriber.Close()
+
+	go func() {
+		for event := range subscriber.C {
+
+			if display != nil {
+				display.apply(event)
+			}
+
+			// Pose manipulée à la main
+			if event.Type == events.SAMPLE && window.Frozen() {
+				continue
+			}
+
+			instruments.apply(event)
+		}
+	}()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			window.Close()
+		case <-stopped:
+		}
+	}()
+
+	window.Start()
+
+	return nil
+}
+
+// La police par défaut peut manquer, la police intégrée la remplace alors
+func (a *app) addHud(scene *opengl.Scene, options config.Hud, i *instruments) (display *hud, err error) {
+
+	display = newHud(a.devices, i.nodes)
+
+	overlay := opengl.OverlayOptions{
+		Font: options.Font,
+		Size: options.Size,
+	}
+
+	if _, statErr := os.Stat(overlay.Font); overlay.Font != "" && os.IsNotExist(statErr) {
+		log.Printf("hud: font %s not found, using the built-in font", overlay.Font)
+		overlay.Font = ""
+	}
+
+	if _, err = scene.SetOverlay(overlay, display.draw); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func viewOptions(view config.View3D) opengl.Options {
+	return opengl.Options{
+		Width:    view.Width,
+		Height:   view.Height,
+		Shader:   view.Shader,
+		Texture:  view.Texture,
+		Camera:   view.Camera,
+		Cameras:  view.Cameras,
+		Bindings: view.Bindings,
+		Lighting: lighting(view),
+
+		Fullscreen:       view.Fullscreen,
+		Monitor:          view.Monitor,
+		Scale:            view.Scale,
+		Vsync:            view.Vsync,
+		Samples:          view.Samples,
+		DisableDepthTest: view.DepthTest == false,
+	}
+}
+
+// Violon, archet & repères de la configuration, orientés ou déplacés par
+// les capteurs
+type instruments struct {
+	bindings []sensorBinding
+
+	// Capteurs désignés par une liaison, les autres suivant les liaisons
+	// sans capteur
+	devices map[string]bool
+
+	// Trajectoire de la pointe de l'archet, nil si elle n'est pas affichée
+	trail *opengl.Trail
+}
+
+// Liaison d'un capteur au repère qu'il oriente ou déplace
+type sensorBinding struct {
+	config.SensorBinding
+
+	transform *opengl.Transform
+
+	// Position initiale, à laquelle le déplacement est ajouté
+	origin mgl32.Vec3
+
+	// Accélération intégrée deux fois depuis la dernière valeur reçue
+	velocity mgl32.Vec3
+	offset   mgl32.Vec3
+	last     time.Time
+}
+
+func (a *app) addInstruments(scene *opengl.Scene) (i *instruments, err error) {
+
+	view := a.options.Display.View3D
+
+	if err = addNodes(scene, view); err != nil {
+		return
+	}
+
+	i = &instruments{
+		devices: make(map[string]bool),
+	}
+
+	// L'archet n'est affiché que si son modèle est spécifié
+	if bow := scene.Node("bow"); bow != nil && view.Trail.Style != "" {
+		if i.trail, err = scene.AddTrail(bow.GetObject(), trailOptions(view.Trail)); err != nil {
+			return nil, err
+		}
+	}
+
+	bindings := view.Sensors
+	if len(bindings) == 0 {
+		bindings = defaultBindings(a.options.Bow())
+	}
+
+	for _, binding := range bindings {
+
+		if binding.Device != "" {
+			i.devices[binding.Device] = true
+		}
+
+		// Sans modèle d'archet, les valeurs de son capteur sont ignorées
+		node := scene.Node(binding.Node)
+		if node == nil {
+			continue
+		}
+
+		transform := node.GetTransform()
+
+		i.bindings = append(i.bindings, sensorBinding{
+			SensorBinding: binding,
+			transform:     transform,
+			origin:        transform.GetPosition(),
+		})
+	}
+
+	return
+}
+
+// Le capteur de l'archet oriente l'archet, les autres le violon
+func defaultBindings(bow string) (bindings []config.SensorBinding) {
+
+	if bow != "" {
+		bindings = append(bindings, config.SensorBinding{
+			Device: bow,
+			Node:   "bow",
+			Target: "rotation",
+		})
+	}
+
+	return append(bindings, config.SensorBinding{
+		Node:   "violin",
+		Target: "rotation",
+	})
+}
+
+// Violon, archet & repères, chaque parent étant ajouté avant ses enfants
+func addNodes(scene *opengl.Scene, view config.View3D) error {
+
+	nodes := []config.Node{{Name: "violin", Model: view.Violin}}
+
+	// L'archet n'est affiché que si son modèle est spécifié
+	if view.Bow.Path != "" {
+		nodes = append(nodes, config.Node{Name: "bow", Model: view.Bow})
+	}
+
+	nodes = append(nodes, view.Nodes...)
+
+	models := make(map[string]config.Model, len(nodes))
+	for _, node := range nodes {
+		models[node.Name] = node.Model
+	}
+
+	// Repères dont les parents sont en cours d'ajout
+	adding := make(map[string]bool)
+
+	var add func(name string) error
+	add = func(name string) (err error) {
+
+		if scene.Node(name) != nil {
+			return
+		}
+
+		model, ok := models[name]
+		if ok == false || adding[name] {
+			return fmt.Errorf("view3d: invalid node '%s'", name)
+		}
+		adding[name] = true
+
+		if model.Parent != "" {
+			if err = add(model.Parent); err != nil {
+				return
+			}
+		}
+
+		// Le violon sans modèle reste un triangle texturé
+		if model.Path == "" && name != "violin" {
+			_, err = scene.AddNode(nodeOptions(name, model))
+		} else {
+			_, err = scene.AddObject(objectOptions(name, model))
+		}
+
+		return
+	}
+
+	for _, node := range nodes {
+		if err := add(node.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Liaisons du capteur, à défaut celles sans capteur
+func (i *instruments) bound(binding *sensorBinding, device string) bool {
+	return binding.Device == device || (binding.Device == "" && i.devices[device] == false)
+}
+
+// Repères du capteur séparés par des virgules, vide s'il n'en a pas
+func (i *instruments) nodes(device string) string {
+
+	var nodes []string
+	seen := make(map[string]bool)
+
+	for idx := range i.bindings {
+		binding := &i.bindings[idx]
+		if i.bound(binding, device) && seen[binding.Node] == false {
+			nodes = append(nodes, binding.Node)
+			seen[binding.Node] = true
+		}
+	}
+
+	return strings.Join(nodes, ", ")
+}
+
+// Orientation & position des capteurs, sens du coup d'archet
+func (i *instruments) apply(event *events.Event) {
+
+	switch data := event.Data.(type) {
+	case *input.AccelGyro:
+		for idx := range i.bindings {
+			if binding := &i.bindings[idx]; i.bound(binding, data.Device) {
+				binding.apply(data, event.Time)
+			}
+		}
+
+	case *input.Stroke:
+		if i.trail != nil {
+			i.trail.SetStroke(data.Direction)
+		}
+	}
+}
+
+// Les valeurs absentes des trames du capteur sont ignorées
+func (b *sensorBinding) apply(values *input.AccelGyro, date time.Time) {
+
+	switch b.Target {
+	case "rotation":
+		rotation, ok := sensorRotation(values, b.Source)
+		if ok {
+			b.transform.SetWorldRotate(rotation.W, rotation.V.X(), rotation.V.Y(), rotation.V.Z())
+		}
+
+	case "position":
+		acceleration, ok := sensorAcceleration(values, b.Source)
+		if ok {
+			// L'accélération "world" suit les axes de la scène, ramenés dans
+			// le repère du parent comme pour Transform.Move. "real" reste dans
+			// celui du capteur.
+			if parent := b.transform.GetParent(); parent != nil && b.Source == "world" {
+				acceleration = parent.GetWorldRotation().Inverse().Rotate(acceleration)
+			}
+
+			position := b.origin.Add(b.integrate(acceleration, date).Mul(float32(b.Scale)))
+			b.transform.SetPosition(position.X(), position.Y(), position.Z())
+		}
+	}
+}
+
+// Déplacement obtenu en intégrant deux fois l'accélération, à travers un
+// filtre passe-haut : le repère suit les mouvements rapides du capteur puis
+// revient lentement à sa position initiale au lieu de dériver
+func (b *sensorBinding) integrate(acceleration mgl32.Vec3, date time.Time) mgl32.Vec3 {
+
+	elapsed := date.Sub(b.last)
+	b.last = date
+
+	if elapsed <= 0 || elapsed > MAX_SAMPLE_GAP {
+		return b.offset
+	}
+
+	dt := float32(elapsed.Seconds())
+	decay := float32(math.Exp(-elapsed.Seconds() / POSITION_DECAY.Seconds()))
+
+	b.velocity = b.velocity.Add(acceleration.Mul(dt)).Mul(decay)
+	b.offset = b.offset.Add(b.velocity.Mul(dt)).Mul(decay)
+
+	return b.offset
+}
+
+func sensorRotation(values *input.AccelGyro, source string) (rotation mgl32.Quat, ok bool) {
+
+	if source == "ypr" {
+		if values.Status&input.YAWPITCHROLL == 0 {
+			return
+		}
+
+		// Lacet autour de Z, tangage autour de Y puis roulis autour de X
+		rotation = mgl32.QuatRotate(values.Yaw, mgl32.Vec3{0, 0, 1}).
+			Mul(mgl32.QuatRotate(values.Pitch, mgl32.Vec3{0, 1, 0})).
+			Mul(mgl32.QuatRotate(values.Roll, mgl32.Vec3{1, 0, 0}))
+		return rotation, true
+	}
+
+	if values.Status&(input.QUATERNION|input.BUFFER) == 0 {
+		return
+	}
+
+	return mgl32.Quat{
+		W: values.QuaternionW,
+		V: mgl32.Vec3{values.QuaternionX, values.QuaternionY, values.QuaternionZ},
+	}, true
+}
+
+func sensorAcceleration(values *input.AccelGyro, source string) (acceleration mgl32.Vec3, ok bool) {
+
+	switch {
+	case source == "real" && values.Status&input.REALACCEL != 0:
+		return mgl32.Vec3{values.RealX, values.RealY, values.RealZ}, true
+
+	case source == "world" && values.Status&input.WORLDACCEL != 0:
+		return mgl32.Vec3{values.WorldX, values.WorldY, values.WorldZ}, true
+	}
+
+	return
+}
+
+func nodeOptions(name string, model config.Model) opengl.NodeOptions {
+
+	options := opengl.NodeOptions{
+		Name:   name,
+		Parent: model.Parent,
+	}
+
+	for idx := range model.Position {
+		options.Position[idx] = float32(model.Position[idx])
+		options.Rotation[idx] = float32(model.Rotation[idx])
+	}
+
+	return options
+}
+
+func objectOptions(name string, model config.Model) opengl.ObjectOptions {
+
+	options := opengl.ObjectOptions{
+		NodeOptions: nodeOptions(name, model),
+		Model:       model.Path,
+		Size:        float32(model.Size),
+	}
+
+	if model.Pivot != nil {
+		options.Pivot = &[3]float32{
+			float32(model.Pivot[0]),
+			float32(model.Pivot[1]),
+			float32(model.Pivot[2]),
+		}
+	}
+
+	return options
+}
+
+func trailOptions(trail config.Trail) opengl.TrailOptions {
+	return opengl.TrailOptions{
+		Style:    trail.Style,
+		ColorBy:  trail.Color,
+		Axis:     [3]float32(vec3(trail.Axis)),
+		Length:   float32(trail.Length),
+		Width:    float32(trail.Width),
+		Duration: trail.Duration.Duration,
+		MaxSpeed: float32(trail.MaxSpeed),
+	}
+}
+
+// Lumières de la configuration, nil pour garder celles par défaut
+func lighting(view config.View3D) *opengl.Lighting {
+
+	if view.Lights == nil && view.Ambient == nil {
+		return nil
+	}
+
+	lighting := opengl.DEFAULT_LIGHTING
+
+	if view.Ambient != nil {
+		lighting.Ambient = vec3(*view.Ambient)
+	}
+
+	if view.Lights != nil {
+		lighting.Lights = make([]opengl.Light, len(view.Lights))
+
+		for idx, light := range view.Lights {
+
+			color := [3]float64{1, 1, 1}
+			if light.Color != nil {
+				color = *light.Color
+			}
+
+			lighting.Lights[idx] = opengl.Light{
+				Type:      light.Type,
+				Direction: vec3(light.Direction),
+				Position:  vec3(light.Position),
+				Color:     vec3(color),
+				Intensity: float32(light.Intensity),
+			}
+		}
+	}
+
+	return &lighting
+}
+
+func vec3(v [3]float64) mgl32.Vec3 {
+	return mgl32.Vec3{float32(v[0]), float32(v[1]), float32(v[2])}
+}
+
+// Pages web, API, flux & WebSocket
+func (a *app) serve() supervisor.Component {
+
+	var server *api.Server
+
+	return supervisor.Component{
+		Name:    SERVE,
+		Restart: true,
+		Start: func(ctx context.Context) (err error) {
+
+			s := a.options.Server
+
+			server, err = api.New(api.Options{
+				Addr:           s.Listen,
+				WebDir:         s.Web,
+				AllowedOrigins: s.Origins,
+				Token:          s.Token,
+			}, a.store, a.devices)
+
+			if err != nil {
+				return
+			}
+
+			server.AddMetrics(a.registry)
+
+			if err = server.AddStream(a.hub); err != nil {
+				return
+			}
+
+			return server.AddWebSocket(a.hub, a.commands)
+		},
+		Run: func(ctx context.Context) error {
+
+			stopped := make(chan struct{})
+			defer close(stopped)
+
+			// Les requêtes en cours peuvent se terminer
+			go func() {
+				select {
+				case <-ctx.Done():
+					shutdown, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+					defer cancel()
+
+					server.Shutdown(shutdown)
+				case <-stopped:
+				}
+			}()
+
+			return server.ListenAndServe()
+		},
+	}
+}
+
+// Enregistre une session jusqu'à l'arrêt de l'application
+func (a *app) record() supervisor.Component {
+
+	var session *sessions.Session
+
+	return supervisor.Component{
+		Name: RECORD,
+		Start: func(ctx context.Context) (err error) {
+
+			session, err = a.store.Start(sessions.Metadata{
+				Player: a.options.Sessions.Player,
+				Piece:  a.options.Sessions.Piece,
+			})
+
+			if err == nil {
+				log.Printf("recording session %s", session.Id)
+			}
+
+			return
+		},
+		Stop: func() error {
+
+			stopped, err := a.store.Stop(session.Id)
+			if err != nil {
+				// La session a pu être arrêtée depuis les interfaces de contrôle
+				if errors.Is(err, sessions.ErrNotRecording) {
+					return nil
+				}
+				return err
+			}
+
+			log.Printf("session %s: %d events", stopped.Id, stopped.Events)
+			return nil
+		},
+	}
+}
+
+// Session spécifiée, ou la plus récente
+func (a *app) sessionId() (string, error) {
+
+	if a.options.Session != "" {
+		return a.options.Session, nil
+	}
+
+	list, err := a.store.List()
+	if err != nil {
+		return "", err
+	}
+
+	for _, session := range list {
+		if session.Recording == false {
+			return session.Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("no session in %s", a.options.Sessions.Dir)
+}
+
+// Republie les évènements d'une session en respectant leur cadencement
+func (a *app) replay() supervisor.Component {
+	return supervisor.Component{
+		Name:   REPLAY,
+		Finite: true,
+		Run:    a.runReplay,
+	}
+}
+
+func (a *app) runReplay(ctx context.Context) error {
+
+	if a.options.Speed <= 0 {
+		return fmt.Errorf("invalid replay speed %g", a.options.Speed)
+	}
+
+	id, err := a.sessionId()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("replaying session %s", id)
+
+	var first, start time.Time
+
+	err = a.store.Events(id, func(event *events.Event) error {
+
+		if first.IsZero() {
+			first, start = event.Time, time.Now()
+		}
+
+		offset := time.Duration(float64(event.Time.Sub(first)) / a.options.Speed)
+
+		if delay := time.Until(start.Add(offset)); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		a.hub.Publish(event.Type, event.Data)
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if err == nil {
+		log.Printf("session %s replayed", id)
+	}
+
+	return err
+}
+
+func (a *app) export() (err error) {
+
+	id, err := a.sessionId()
+	if err != nil {
+		return
+	}
+
+	var w io.Writer = os.Stdout
+
+	if a.options.Output != "" {
+
+		var file *os.File
+		if file, err = os.Create(a.options.Output); err != nil {
+			return
+		}
+
+		// Pas de fichier incomplet en cas d'échec
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+
+			if err != nil {
+				os.Remove(a.options.Output)
+			}
+		}()
+
+		w = file
+	}
+
+	err = a.store.Export(id, a.options.Format, w)
+	return
+}
+
+// Rend hors écran l'orientation des capteurs d'une session, image par
+// image à la cadence -fps du temps de la session divisé par -speed
+func (a *app) render() (err error) {
+
+	if a.options.Speed <= 0 {
+		return fmt.Errorf("invalid replay speed %g", a.options.Speed)
+	}
+
+	id, err := a.sessionId()
+	if err != nil {
+		return
+	}
+
+	var samples []*events.Event
+
+	err = a.store.Events(id, func(event *events.Event) error {
+		switch event.Type {
+		case events.SAMPLE, events.STROKE:
+			samples = append(samples, event)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return
+	}
+
+	if len(samples) == 0 {
+		return fmt.Errorf("session %s has no sensor samples", id)
+	}
+
+	view := a.options.Display.View3D
+
+	offscreen, err := opengl.CreateOffscreen(viewOptions(view))
+	if err != nil {
+		return
+	}
+	defer offscreen.Destroy()
+
+	instruments, err := a.addInstruments(offscreen.Scene)
+	if err != nil {
+		return
+	}
+
+	dir := a.options.Output
+	if dir == "" && a.options.Encoder == "" {
+		dir = DEFAULT_RENDER_DIR
+	}
+
+	output, err := opengl.CreateFrameOutput(dir, a.options.Encoder, view.Width, view.Height, a.options.Fps)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	first := samples[0].Time
+	offset := func(event *events.Event) time.Duration {
+		return time.Duration(float64(event.Time.Sub(first)) / a.options.Speed)
+	}
+
+	log.Printf("rendering session %s", id)
+
+	// Chaque image montre les dernières orientations reçues à son instant
+	next := 0
+	frames, err := offscreen.Record(output, a.options.Fps, offset(samples[len(samples)-1]),
+		func(t time.Duration) error {
+			for ; next < len(samples) && offset(samples[next]) <= t; next++ {
+				instruments.apply(samples[next])
+			}
+			return nil
+		})
+
+	if err == nil {
+		log.Printf("session %s rendered: %d frames", id, frames)
+	}
+
+	return
+}
+
+// Moyenne de l'orientation pendant la durée spécifiée, utilisée ensuite
+// comme référence & enregistrée dans le fichier de calibration
+func (a *app) calibrate() supervisor.Component {
+	return supervisor.Component{
+		Name:   CALIBRATE,
+		Finite: true,
+		Run:    a.runCalibrate,
+	}
+}
+
+func (a *app) runCalibrate(ctx context.Context) error {
+
+	a.filter.ResetTare()
+
+	subscriber := a.hub.SubscribeAs(CALIBRATE, 1024, a.hub.LastId(), events.SAMPLE)
+	defer subscriber.Close()
+
+	log.Printf("calibrating for %s, keep the instrument still", a.options.Duration)
+
+	timeout := time.After(a.options.Duration)
+
+	var first *input.AccelGyro
+	var w, x, y, z float64
+	var count int
+
+	for done := false; done == false; {
+		select {
+		case event := <-subscriber.C:
+			values, ok := event.Data.(*input.AccelGyro)
+			if ok == false || values.Status&(input.QUATERNION|input.BUFFER) == 0 {
+				continue
+			}
+
+			if first == nil {
+				first = values
+			}
+
+			// q & -q représentent la même orientation
+			sign := 1.0
+			if first.QuaternionW*values.QuaternionW+first.QuaternionX*values.QuaternionX+
+				first.QuaternionY*values.QuaternionY+first.QuaternionZ*values.QuaternionZ < 0 {
+				sign = -1
+			}
+
+			w += sign * float64(values.QuaternionW)
+			x += sign * float64(values.QuaternionX)
+			y += sign * float64(values.QuaternionY)
+			z += sign * float64(values.QuaternionZ)
+			count++
+
+		case <-timeout:
+			done = true
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	norm := math.Sqrt(w*w + x*x + y*y + z*z)
+	if count == 0 || norm == 0 {
+		return errors.New("no orientation received")
+	}
+
+	reference := &input.AccelGyro{
+		Status:      input.QUATERNION,
+		QuaternionW: float32(w / norm),
+		QuaternionX: float32(x / norm),
+		QuaternionY: float32(y / norm),
+		QuaternionZ: float32(z / norm),
+	}
+
+	a.filter.SetReference(reference)
+
+	yaw, pitch, roll := reference.YawPitchRoll()
+	log.Printf("reference from %d samples: yaw %.1f° pitch %.1f° roll %.1f°",
+		count, yaw*180/math.Pi, pitch*180/math.Pi, roll*180/math.Pi)
+
+	if a.options.Calibration == "" {
+		return nil
+	}
+
+	if err := a.saveCalibration(reference); err != nil {
+		return err
+	}
+
+	log.Printf("calibration saved to %s", a.options.Calibration)
+	return nil
+}
+
+// Affiche en continu la note jouée & son écart de justesse
+func (a *app) tune() supervisor.Component {
+	return supervisor.Component{
+		Name: TUNE,
+		Run:  a.runTune,
+	}
+}
+
+func (a *app) runTune(ctx context.Context) error {
+
+	subscriber := a.hub.SubscribeAs(TUNE, events.DEFAULT_BUFFER, a.hub.LastId(), events.PITCH)
+	defer subscriber.Close()
+
+	defer fmt.Println()
+
+	for {
+		select {
+		case event := <-subscriber.C:
+
+			pitch, ok := event.Data.(*audio.Pitch)
+			if ok == false || pitch.Note == nil {
+				continue
+			}
+
+			fmt.Printf("\x1b[2K\r%-2s%d %s %+6.1f cents %8.2f Hz",
+				pitch.Note.Name, pitch.Note.Octave, tuneMeter(pitch.Note.Cents),
+				pitch.Note.Cents, pitch.Frequency)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func tuneMeter(cents float64) string {
+
+	meter := []byte(strings.Repeat("-", TUNE_METER_WIDTH))
+	center := TUNE_METER_WIDTH / 2
+	meter[center] = '|'
+
+	position := center + int(math.Round(cents/50*float64(center)))
+	if position < 0 {
+		position = 0
+	} else if position >= TUNE_METER_WIDTH {
+		position = TUNE_METER_WIDTH - 1
+	}
+
+	meter[position] = '*'
+
+	return "[" + string(meter) + "]"
+}
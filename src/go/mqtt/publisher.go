@@ -0,0 +1,392 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/metrics"
+)
+
+const (
+	DEFAULT_BROKER = "tcp://localhost:1883"
+	DEFAULT_PREFIX = "violin"
+
+	// Etat de l'application publié en message retenu, "offline" étant
+	// également le testament transmis par le broker en cas de coupure
+	ONLINE  = "online"
+	OFFLINE = "offline"
+
+	// Période de publication de l'état des capteurs
+	HEALTH_INTERVAL = 10 * time.Second
+
+	CONNECT_TIMEOUT       = 5 * time.Second
+	MAX_RECONNECT_DELAY   = 30 * time.Second
+	DISCONNECT_QUIESCE_MS = 250
+
+	PUBLISHER_BUFFER = 256
+
+	// Acquittements en attente au-delà desquels la lecture du hub est
+	// suspendue
+	PENDING_ACKS = 256
+)
+
+// Sujets par défaut relatifs au préfixe, "{device}" étant remplacé par
+// l'identifiant du capteur
+var DEFAULT_TOPICS = map[string]string{
+	events.SAMPLE:   "devices/{device}/sample",
+	events.STROKE:   "devices/{device}/stroke",
+	events.STATUS:   "events/status",
+	events.PITCH:    "audio/pitch",
+	events.LEVEL:    "audio/level",
+	events.SPECTRUM: "audio/spectrum",
+}
+
+const (
+	STATUS_TOPIC        = "status"
+	DEVICE_STATUS_TOPIC = "devices/{device}/status"
+)
+
+type Options struct {
+	// Adresse du broker (tcp://, ssl://, ws://)
+	Broker   string
+	ClientId string
+	Username string
+	Password string
+
+	// Préfixe de tous les sujets
+	Prefix string
+
+	// Sujets par type d'évènement, un sujet vide désactive la publication
+	Topics map[string]string
+
+	// Qualité de service des évènements & des messages d'état retenus
+	QoS       byte
+	StatusQoS byte
+}
+
+type Stats struct {
+	Published  uint64
+	Dropped    uint64
+	Errors     uint64
+	Reconnects uint64
+	Connected  bool
+}
+
+// Publie les évènements du hub sur un bus MQTT : chaque type d'évènement est
+// envoyé sur son propre sujet, l'état des capteurs en message retenu
+type Publisher struct {
+	options Options
+	client  paho.Client
+
+	subscriber *events.Subscriber
+	done       chan struct{}
+	stop       chan struct{}
+	pending    chan paho.Token
+
+	mutex     sync.Mutex
+	stats     Stats
+	connected bool
+	devices   map[string]input.DeviceStatus
+}
+
+func NewPublisher(hub *events.Hub, options Options) (p *Publisher, err error) {
+
+	if options.Broker == "" {
+		options.Broker = DEFAULT_BROKER
+	}
+
+	if options.Prefix == "" {
+		options.Prefix = DEFAULT_PREFIX
+	}
+
+	if options.ClientId == "" {
+		hostname, _ := os.Hostname()
+		options.ClientId = fmt.Sprintf("violin-%s-%d", hostname, os.Getpid())
+	}
+
+	if options.QoS > 2 || options.StatusQoS > 2 {
+		return nil, errors.New("MQTT QoS must be 0, 1 or 2")
+	}
+
+	topics := make(map[string]string, len(DEFAULT_TOPICS))
+	for eventType, topic := range DEFAULT_TOPICS {
+		topics[eventType] = topic
+	}
+
+	for eventType, topic := range options.Topics {
+		if _, ok := DEFAULT_TOPICS[eventType]; ok == false {
+			return nil, fmt.Errorf("unknown MQTT event type '%s'", eventType)
+		}
+		topics[eventType] = topic
+	}
+
+	options.Topics = topics
+
+	p = &Publisher{
+		options: options,
+		done:    make(chan struct{}),
+		stop:    make(chan struct{}),
+		pending: make(chan paho.Token, PENDING_ACKS),
+		devices: make(map[string]input.DeviceStatus),
+	}
+
+	clientOptions := paho.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientId).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetConnectTimeout(CONNECT_TIMEOUT).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(MAX_RECONNECT_DELAY).
+		SetWill(p.topic(STATUS_TOPIC, ""), OFFLINE, options.StatusQoS, true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(p.onConnectionLost).
+		SetReconnectingHandler(func(paho.Client, *paho.ClientOptions) {
+			p.mutex.Lock()
+			p.stats.Reconnects++
+			p.mutex.Unlock()
+		})
+
+	p.client = paho.NewClient(clientOptions)
+
+	// Avec SetConnectRetry la connexion est retentée en arrière-plan : un
+	// broker absent au démarrage n'empêche pas l'application de fonctionner
+	p.client.Connect()
+
+	p.subscriber = hub.SubscribeAs("mqtt:"+options.Broker, PUBLISHER_BUFFER, hub.LastId())
+
+	go p.publish()
+	go p.acknowledge()
+
+	return
+}
+
+func (p *Publisher) topic(template string, device string) string {
+	return p.options.Prefix + "/" + strings.Replace(template, "{device}", device, -1)
+}
+
+// Les messages retenus sont republiés à chaque connexion au cas où le
+// broker les aurait perdus
+func (p *Publisher) onConnect(client paho.Client) {
+
+	log.Printf("mqtt: connected to %s", p.options.Broker)
+
+	p.mutex.Lock()
+	p.connected = true
+	devices := make([]input.DeviceStatus, 0, len(p.devices))
+	for _, status := range p.devices {
+		devices = append(devices, status)
+	}
+	p.mutex.Unlock()
+
+	p.send(p.topic(STATUS_TOPIC, ""), p.options.StatusQoS, true, ONLINE)
+
+	for _, status := range devices {
+		p.deviceStatus(status)
+	}
+}
+
+func (p *Publisher) onConnectionLost(client paho.Client, err error) {
+
+	log.Printf("mqtt: connection to %s lost: %s", p.options.Broker, err)
+
+	p.mutex.Lock()
+	p.connected = false
+	p.mutex.Unlock()
+}
+
+func (p *Publisher) publish() {
+
+	defer close(p.done)
+
+	for event := range p.subscriber.C {
+
+		if status, ok := event.Data.(input.DeviceStatus); ok {
+			p.deviceStatus(status)
+		}
+
+		template := p.options.Topics[event.Type]
+		if template == "" {
+			continue
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			p.count(func(stats *Stats) { stats.Errors++ })
+			continue
+		}
+
+		p.send(p.topic(template, eventDevice(event)), p.options.QoS, false, data)
+	}
+}
+
+// Capteur à l'origine de l'évènement
+func eventDevice(event *events.Event) string {
+
+	switch data := event.Data.(type) {
+	case *input.AccelGyro:
+		return data.Device
+	case *input.Stroke:
+		return data.Device
+	case input.DeviceStatus:
+		return data.Id
+	}
+
+	return "unknown"
+}
+
+func (p *Publisher) deviceStatus(status input.DeviceStatus) {
+
+	p.mutex.Lock()
+	p.devices[status.Id] = status
+	p.mutex.Unlock()
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		p.count(func(stats *Stats) { stats.Errors++ })
+		return
+	}
+
+	p.send(p.topic(DEVICE_STATUS_TOPIC, status.Id), p.options.StatusQoS, true, data)
+}
+
+// Publie l'état des capteurs à intervalle régulier
+func (p *Publisher) SetDevices(devices *input.Manager) {
+
+	go func() {
+		ticker := time.NewTicker(HEALTH_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				for _, status := range devices.Status() {
+					p.deviceStatus(status)
+				}
+
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Pendant les déconnexions seuls les messages d'état retenus sont conservés
+// par le client jusqu'à la reconnexion, les évènements sont abandonnés quelle
+// que soit leur qualité de service
+func (p *Publisher) send(topic string, qos byte, retained bool, payload interface{}) {
+
+	p.mutex.Lock()
+	connected := p.connected
+	p.mutex.Unlock()
+
+	if connected == false && retained == false {
+		p.count(func(stats *Stats) { stats.Dropped++ })
+		return
+	}
+
+	token := p.client.Publish(topic, qos, retained, payload)
+
+	select {
+	case p.pending <- token:
+	case <-p.stop:
+	}
+}
+
+// Compte les acquittements dans l'ordre des publications, en arrière-plan
+// pour ne pas ralentir la lecture du hub
+func (p *Publisher) acknowledge() {
+
+	for {
+		var token paho.Token
+
+		select {
+		case token = <-p.pending:
+		case <-p.stop:
+			return
+		}
+
+		select {
+		case <-token.Done():
+		case <-p.stop:
+			return
+		}
+
+		if err := token.Error(); err != nil {
+			p.count(func(stats *Stats) { stats.Errors++ })
+			continue
+		}
+
+		p.count(func(stats *Stats) { stats.Published++ })
+	}
+}
+
+func (p *Publisher) count(update func(stats *Stats)) {
+	p.mutex.Lock()
+	update(&p.stats)
+	p.mutex.Unlock()
+}
+
+func (p *Publisher) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.stats
+	stats.Connected = p.connected
+
+	return stats
+}
+
+// Statistiques de publication
+func (p *Publisher) Metrics() metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		stats := p.Stats()
+
+		connected := 0.0
+		if stats.Connected {
+			connected = 1
+		}
+
+		return []*metrics.Metric{
+			metrics.NewGauge("violin_mqtt_connected",
+				"Whether the MQTT broker is connected").Add(connected),
+			metrics.NewCounter("violin_mqtt_published_total",
+				"Messages acknowledged by the MQTT client").Add(float64(stats.Published)),
+			metrics.NewCounter("violin_mqtt_dropped_total",
+				"Messages dropped while the broker was disconnected").Add(float64(stats.Dropped)),
+			metrics.NewCounter("violin_mqtt_errors_total",
+				"Messages that could not be published").Add(float64(stats.Errors)),
+			metrics.NewCounter("violin_mqtt_reconnects_total",
+				"Reconnection attempts to the MQTT broker").Add(float64(stats.Reconnects)),
+		}
+	}
+}
+
+// Publie l'état "offline" & se déconnecte du broker
+func (p *Publisher) Close() {
+
+	close(p.stop)
+
+	p.subscriber.Close()
+	<-p.done
+
+	if p.Stats().Connected {
+		token := p.client.Publish(p.topic(STATUS_TOPIC, ""), p.options.StatusQoS, true, OFFLINE)
+		token.WaitTimeout(CONNECT_TIMEOUT)
+	}
+
+	p.client.Disconnect(DISCONNECT_QUIESCE_MS)
+}
@@ -0,0 +1,408 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+)
+
+const TIMEOUT = 5 * time.Second
+
+type message struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+// Broker minimal sur une connexion TCP locale : accepte les clients,
+// acquitte les publications selon leur QoS & les transmet au test
+type broker struct {
+	listener net.Listener
+
+	connects chan *packets.ConnectPacket
+	messages chan message
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newBroker(t *testing.T) *broker {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &broker{
+		listener: listener,
+		connects: make(chan *packets.ConnectPacket, 16),
+		messages: make(chan message, 256),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	go b.accept()
+
+	t.Cleanup(func() {
+		listener.Close()
+		b.drop()
+	})
+
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *broker) accept() {
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		b.mutex.Lock()
+		b.conns[conn] = struct{}{}
+		b.mutex.Unlock()
+
+		go b.serve(conn)
+	}
+}
+
+// Coupe brutalement les connexions en cours, le broker restant joignable
+func (b *broker) drop() {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for conn := range b.conns {
+		conn.Close()
+		delete(b.conns, conn)
+	}
+}
+
+func (b *broker) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		var reply packets.ControlPacket
+
+		switch packet := packet.(type) {
+		case *packets.ConnectPacket:
+			b.connects <- packet
+			reply = packets.NewControlPacket(packets.Connack)
+
+		case *packets.PublishPacket:
+			b.messages <- message{packet.TopicName, packet.Qos, packet.Retain, packet.Payload}
+
+			switch packet.Qos {
+			case 1:
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = packet.MessageID
+				reply = ack
+			case 2:
+				rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				rec.MessageID = packet.MessageID
+				reply = rec
+			}
+
+		case *packets.PubrelPacket:
+			comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			comp.MessageID = packet.MessageID
+			reply = comp
+
+		case *packets.PingreqPacket:
+			reply = packets.NewControlPacket(packets.Pingresp)
+
+		case *packets.DisconnectPacket:
+			return
+		}
+
+		if reply != nil {
+			if err = reply.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (b *broker) connected(t *testing.T) *packets.ConnectPacket {
+
+	select {
+	case connect := <-b.connects:
+		return connect
+	case <-time.After(TIMEOUT):
+		t.Fatal("no connection to the broker")
+	}
+
+	return nil
+}
+
+// Prochain message publié sur le sujet, les autres sont ignorés
+func (b *broker) expect(t *testing.T, topic string) message {
+
+	timeout := time.After(TIMEOUT)
+
+	for {
+		select {
+		case message := <-b.messages:
+			if message.topic == topic {
+				return message
+			}
+		case <-timeout:
+			t.Fatalf("nothing published on %s", topic)
+		}
+	}
+}
+
+func newTestPublisher(t *testing.T, b *broker, hub *events.Hub, options Options) *Publisher {
+
+	options.Broker = b.url()
+	options.Prefix = "test"
+
+	publisher, err := NewPublisher(hub, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.connected(t)
+
+	if online := b.expect(t, "test/"+STATUS_TOPIC); string(online.payload) != ONLINE || online.retained == false {
+		t.Fatalf("status %s retained %t, expect %s retained", online.payload, online.retained, ONLINE)
+	}
+
+	return publisher
+}
+
+func TestPublisherTopics(t *testing.T) {
+
+	b := newBroker(t)
+	hub := events.NewHub(0)
+
+	publisher := newTestPublisher(t, b, hub, Options{
+		QoS: 1,
+		Topics: map[string]string{
+			events.PITCH:    "tuner",
+			events.SPECTRUM: "",
+		},
+	})
+	defer publisher.Close()
+
+	hub.Publish(events.SAMPLE, &input.AccelGyro{Device: "violin"})
+	hub.Publish(events.STROKE, &input.Stroke{Device: "bow", Direction: input.STROKE_UP})
+	hub.Publish(events.SPECTRUM, []float64{1, 2})
+	hub.Publish(events.PITCH, &audio.Pitch{Frequency: 440})
+	hub.Publish(events.LEVEL, audio.Level{Db: -20})
+
+	for _, test := range []struct {
+		topic     string
+		eventType string
+	}{
+		{"test/devices/violin/sample", events.SAMPLE},
+		{"test/devices/bow/stroke", events.STROKE},
+		{"test/tuner", events.PITCH},
+		{"test/audio/level", events.LEVEL},
+	} {
+		message := b.expect(t, test.topic)
+
+		if message.qos != 1 || message.retained {
+			t.Errorf("%s: QoS %d retained %t, expect QoS 1 not retained", test.topic, message.qos, message.retained)
+		}
+
+		var event events.Event
+		if err := json.Unmarshal(message.payload, &event); err != nil || event.Type != test.eventType {
+			t.Errorf("%s: payload %s, expect %s event", test.topic, message.payload, test.eventType)
+		}
+	}
+
+	// Le spectre désactivé n'est jamais publié
+	select {
+	case message := <-b.messages:
+		t.Errorf("unexpected message on %s", message.topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Les acquittements sont comptés en arrière-plan
+	deadline := time.Now().Add(TIMEOUT)
+	for publisher.Stats().Published < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats := publisher.Stats(); stats.Connected == false || stats.Published != 5 || stats.Errors != 0 {
+		t.Errorf("stats %+v, expect 5 published", stats)
+	}
+}
+
+func TestPublisherStatus(t *testing.T) {
+
+	b := newBroker(t)
+	hub := events.NewHub(0)
+
+	options := Options{StatusQoS: 2}
+	options.Broker = b.url()
+	options.Prefix = "test"
+
+	publisher, err := NewPublisher(hub, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Testament transmis par le broker si la connexion est perdue
+	connect := b.connected(t)
+	if connect.WillFlag == false || connect.WillTopic != "test/"+STATUS_TOPIC ||
+		string(connect.WillMessage) != OFFLINE || connect.WillRetain == false || connect.WillQos != 2 {
+		t.Errorf("will %s %s QoS %d retained %t, expect test/%s %s QoS 2 retained",
+			connect.WillTopic, connect.WillMessage, connect.WillQos, connect.WillRetain, STATUS_TOPIC, OFFLINE)
+	}
+
+	b.expect(t, "test/"+STATUS_TOPIC)
+
+	hub.Publish(events.STATUS, input.DeviceStatus{Id: "violin", Connected: true})
+
+	status := b.expect(t, "test/devices/violin/status")
+	if status.qos != 2 || status.retained == false {
+		t.Errorf("device status QoS %d retained %t, expect QoS 2 retained", status.qos, status.retained)
+	}
+
+	var device input.DeviceStatus
+	if err := json.Unmarshal(status.payload, &device); err != nil || device.Id != "violin" || device.Connected == false {
+		t.Errorf("device status %s", status.payload)
+	}
+
+	if event := b.expect(t, "test/events/status"); event.retained {
+		t.Error("status event retained")
+	}
+
+	// Etat "offline" publié explicitement à l'arrêt
+	publisher.Close()
+
+	offline := b.expect(t, "test/"+STATUS_TOPIC)
+	if string(offline.payload) != OFFLINE || offline.retained == false || offline.qos != 2 {
+		t.Errorf("status %s QoS %d retained %t, expect %s QoS 2 retained",
+			offline.payload, offline.qos, offline.retained, OFFLINE)
+	}
+}
+
+func TestPublisherReconnect(t *testing.T) {
+
+	b := newBroker(t)
+	hub := events.NewHub(0)
+
+	publisher := newTestPublisher(t, b, hub, Options{QoS: 1, StatusQoS: 1})
+	defer publisher.Close()
+
+	hub.Publish(events.STATUS, input.DeviceStatus{Id: "violin"})
+	b.expect(t, "test/devices/violin/status")
+
+	b.drop()
+
+	// Les messages retenus sont republiés à la reconnexion
+	b.connected(t)
+
+	if online := b.expect(t, "test/"+STATUS_TOPIC); string(online.payload) != ONLINE {
+		t.Errorf("status %s, expect %s", online.payload, ONLINE)
+	}
+
+	if status := b.expect(t, "test/devices/violin/status"); status.retained == false {
+		t.Error("device status not retained after reconnection")
+	}
+
+	hub.Publish(events.SAMPLE, &input.AccelGyro{Device: "violin"})
+	b.expect(t, "test/devices/violin/sample")
+
+	if stats := publisher.Stats(); stats.Reconnects == 0 || stats.Connected == false {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestPublisherOutage(t *testing.T) {
+
+	b := newBroker(t)
+	hub := events.NewHub(0)
+
+	publisher := newTestPublisher(t, b, hub, Options{QoS: 1, StatusQoS: 1})
+	defer publisher.Close()
+
+	// Broker injoignable jusqu'à la réouverture de son port
+	address := b.listener.Addr().String()
+	b.listener.Close()
+	b.drop()
+
+	deadline := time.Now().Add(TIMEOUT)
+	for publisher.Stats().Connected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Les évènements sont abandonnés malgré la QoS, l'état du capteur est
+	// conservé
+	hub.Publish(events.SAMPLE, &input.AccelGyro{Device: "violin"})
+	hub.Publish(events.STATUS, input.DeviceStatus{Id: "violin"})
+
+	deadline = time.Now().Add(TIMEOUT)
+	for publisher.Stats().Dropped < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats := publisher.Stats(); stats.Connected || stats.Dropped != 2 {
+		t.Fatalf("stats %+v, expect 2 dropped while disconnected", stats)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("broker port not reusable: %s", err)
+	}
+	defer listener.Close()
+
+	b.listener = listener
+	go b.accept()
+
+	b.connected(t)
+
+	if status := b.expect(t, "test/devices/violin/status"); status.retained == false {
+		t.Error("device status not retained after the outage")
+	}
+
+	if stats := publisher.Stats(); stats.Dropped != 2 {
+		t.Errorf("stats %+v, expect 2 dropped", stats)
+	}
+}
+
+func TestPublisherOptions(t *testing.T) {
+
+	hub := events.NewHub(0)
+
+	if _, err := NewPublisher(hub, Options{QoS: 3}); err == nil {
+		t.Error("QoS 3 accepted")
+	}
+
+	if _, err := NewPublisher(hub, Options{Topics: map[string]string{"unknown": "x"}}); err == nil {
+		t.Error("unknown event type accepted")
+	}
+
+	// La configuration valide les sujets d'après la liste des types
+	if len(DEFAULT_TOPICS) != len(events.TYPES) {
+		t.Errorf("%d default topics for %d event types", len(DEFAULT_TOPICS), len(events.TYPES))
+	}
+
+	for _, eventType := range events.TYPES {
+		if _, ok := DEFAULT_TOPICS[eventType]; ok == false {
+			t.Errorf("no default topic for %s events", eventType)
+		}
+	}
+}
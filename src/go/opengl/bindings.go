@@ -0,0 +1,297 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"sort"
+	"strings"
+)
+
+// Actions du clavier sur l'objet sélectionné
+const (
+	MOVE_LEFT     = "move_left"
+	MOVE_RIGHT    = "move_right"
+	MOVE_UP       = "move_up"
+	MOVE_DOWN     = "move_down"
+	MOVE_FORWARD  = "move_forward"
+	MOVE_BACKWARD = "move_backward"
+
+	ROTATE_X_POSITIVE = "rotate_x+"
+	ROTATE_X_NEGATIVE = "rotate_x-"
+	ROTATE_Y_POSITIVE = "rotate_y+"
+	ROTATE_Y_NEGATIVE = "rotate_y-"
+	ROTATE_Z_POSITIVE = "rotate_z+"
+	ROTATE_Z_NEGATIVE = "rotate_z-"
+
+	SCALE_UP   = "scale_up"
+	SCALE_DOWN = "scale_down"
+	RESET      = "reset"
+
+	SELECT_NEXT = "select_next"
+
+	// Suspend l'orientation par les capteurs pour manipuler l'objet
+	FREEZE = "freeze"
+
+	// Masque ou affiche l'affichage tête haute
+	TOGGLE_HUD = "hud"
+
+	// Plein écran & passage du plein écran à l'écran suivant
+	FULLSCREEN   = "fullscreen"
+	NEXT_MONITOR = "next_monitor"
+)
+
+// Actions de la caméra : vues prédéfinies, enregistrement de la vue courante
+// à la place de l'une d'elles & changement de projection
+const (
+	VIEW_PLAYER   = "view_player"
+	VIEW_AUDIENCE = "view_audience"
+	VIEW_TOP      = "view_top"
+
+	SAVE_PLAYER   = "save_player"
+	SAVE_AUDIENCE = "save_audience"
+	SAVE_TOP      = "save_top"
+
+	TOGGLE_PROJECTION = "projection"
+)
+
+// Actions de la souris sur l'objet sélectionné : déplacement avec un bouton
+// maintenu ou molette
+const (
+	MOUSE_ROTATE = "mouse_rotate"
+	MOUSE_MOVE   = "mouse_move"
+	MOUSE_SCALE  = "mouse_scale"
+)
+
+// Pas des actions du clavier, la rotation étant en radians
+const (
+	MOVE_STEP   = 0.05
+	ROTATE_STEP = 0.05
+	SCALE_STEP  = 0.05
+
+	// Radians par pixel de déplacement de la souris
+	MOUSE_ROTATE_SPEED = 0.01
+)
+
+// Raccourcis "modificateurs+touche", par exemple "ctrl+Left" ou "shift+Up"
+var DEFAULT_BINDINGS = map[string]string{
+	MOVE_LEFT:     "Left",
+	MOVE_RIGHT:    "Right",
+	MOVE_UP:       "Up",
+	MOVE_DOWN:     "Down",
+	MOVE_FORWARD:  "PageUp",
+	MOVE_BACKWARD: "PageDown",
+
+	ROTATE_X_POSITIVE: "alt+Up",
+	ROTATE_X_NEGATIVE: "alt+Down",
+	ROTATE_Y_POSITIVE: "ctrl+Right",
+	ROTATE_Y_NEGATIVE: "ctrl+Left",
+	ROTATE_Z_POSITIVE: "alt+Right",
+	ROTATE_Z_NEGATIVE: "alt+Left",
+
+	SCALE_UP:   "shift+Up",
+	SCALE_DOWN: "shift+Down",
+	RESET:      "R",
+
+	SELECT_NEXT: "Tab",
+	FREEZE:      "Space",
+	TOGGLE_HUD:  "H",
+
+	FULLSCREEN:   "F11",
+	NEXT_MONITOR: "shift+F11",
+
+	VIEW_PLAYER:   "F1",
+	VIEW_AUDIENCE: "F2",
+	VIEW_TOP:      "F3",
+
+	SAVE_PLAYER:   "shift+F1",
+	SAVE_AUDIENCE: "shift+F2",
+	SAVE_TOP:      "shift+F3",
+
+	TOGGLE_PROJECTION: "P",
+
+	MOUSE_ROTATE: "ctrl+left",
+	MOUSE_MOVE:   "ctrl+right",
+	MOUSE_SCALE:  "ctrl+scroll",
+}
+
+var MODIFIERS = map[string]glfw.ModifierKey{
+	"shift": glfw.ModShift,
+	"ctrl":  glfw.ModControl,
+	"alt":   glfw.ModAlt,
+	"super": glfw.ModSuper,
+}
+
+var KEYS = map[string]glfw.Key{
+	"Space":      glfw.KeySpace,
+	"Tab":        glfw.KeyTab,
+	"Enter":      glfw.KeyEnter,
+	"Escape":     glfw.KeyEscape,
+	"Backspace":  glfw.KeyBackspace,
+	"Insert":     glfw.KeyInsert,
+	"Delete":     glfw.KeyDelete,
+	"Left":       glfw.KeyLeft,
+	"Right":      glfw.KeyRight,
+	"Up":         glfw.KeyUp,
+	"Down":       glfw.KeyDown,
+	"PageUp":     glfw.KeyPageUp,
+	"PageDown":   glfw.KeyPageDown,
+	"Home":       glfw.KeyHome,
+	"End":        glfw.KeyEnd,
+	"Minus":      glfw.KeyMinus,
+	"Equal":      glfw.KeyEqual,
+	"KPAdd":      glfw.KeyKPAdd,
+	"KPSubtract": glfw.KeyKPSubtract,
+}
+
+// Boutons de la souris, "scroll" désignant la molette
+var BUTTONS = map[string]glfw.MouseButton{
+	"left":   glfw.MouseButtonLeft,
+	"right":  glfw.MouseButtonRight,
+	"middle": glfw.MouseButtonMiddle,
+}
+
+const SCROLL = "scroll"
+
+func init() {
+	for c := 'A'; c <= 'Z'; c++ {
+		KEYS[string(c)] = glfw.KeyA + glfw.Key(c-'A')
+	}
+
+	for c := '0'; c <= '9'; c++ {
+		KEYS[string(c)] = glfw.Key0 + glfw.Key(c-'0')
+	}
+
+	for n := 1; n <= 12; n++ {
+		KEYS[fmt.Sprintf("F%d", n)] = glfw.KeyF1 + glfw.Key(n-1)
+	}
+}
+
+// Touche ou bouton & modificateurs devant être maintenus
+type binding struct {
+	key       glfw.Key
+	button    glfw.MouseButton
+	scroll    bool
+	modifiers glfw.ModifierKey
+}
+
+type Bindings struct {
+	keys    map[binding]string
+	buttons map[binding]string
+	scroll  map[glfw.ModifierKey]string
+}
+
+// Raccourcis par défaut remplacés par ceux spécifiés, un raccourci vide
+// désactivant l'action
+func ParseBindings(overrides map[string]string) (b *Bindings, err error) {
+
+	b = &Bindings{
+		keys:    make(map[binding]string),
+		buttons: make(map[binding]string),
+		scroll:  make(map[glfw.ModifierKey]string),
+	}
+
+	specs := make(map[string]string, len(DEFAULT_BINDINGS))
+	for action, spec := range DEFAULT_BINDINGS {
+		specs[action] = spec
+	}
+
+	for action, spec := range overrides {
+		if _, ok := DEFAULT_BINDINGS[action]; ok == false {
+			return nil, fmt.Errorf("unknown binding action '%s'", action)
+		}
+		specs[action] = spec
+	}
+
+	// Ordre stable pour que les erreurs de doublon soient reproductibles
+	actions := make([]string, 0, len(specs))
+	for action := range specs {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	mouse := map[string]bool{MOUSE_ROTATE: true, MOUSE_MOVE: true, MOUSE_SCALE: true}
+	used := make(map[binding]string)
+
+	for _, action := range actions {
+
+		spec := specs[action]
+		if spec == "" {
+			continue
+		}
+
+		parsed, err := parseBinding(spec, mouse[action])
+		if err != nil {
+			return nil, fmt.Errorf("binding '%s': %s", action, err)
+		}
+
+		if action == MOUSE_SCALE && parsed.scroll == false {
+			return nil, fmt.Errorf("binding '%s': expect scroll", action)
+		}
+
+		if action != MOUSE_SCALE && parsed.scroll {
+			return nil, fmt.Errorf("binding '%s': scroll only for %s", action, MOUSE_SCALE)
+		}
+
+		if previous, ok := used[parsed]; ok {
+			return nil, fmt.Errorf("binding '%s': '%s' already used by '%s'", action, spec, previous)
+		}
+		used[parsed] = action
+
+		switch {
+		case parsed.scroll:
+			b.scroll[parsed.modifiers] = action
+		case mouse[action]:
+			b.buttons[parsed] = action
+		default:
+			b.keys[parsed] = action
+		}
+	}
+
+	return
+}
+
+func parseBinding(spec string, mouse bool) (b binding, err error) {
+
+	parts := strings.Split(spec, "+")
+	name := parts[len(parts)-1]
+
+	for _, modifier := range parts[:len(parts)-1] {
+		value, ok := MODIFIERS[strings.ToLower(modifier)]
+		if ok == false {
+			return b, fmt.Errorf("unknown modifier '%s' (expect shift, ctrl, alt or super)", modifier)
+		}
+		b.modifiers |= value
+	}
+
+	if mouse {
+		if name == SCROLL {
+			b.scroll = true
+			return
+		}
+
+		button, ok := BUTTONS[name]
+		if ok == false {
+			return b, fmt.Errorf("unknown mouse button '%s' (expect left, right, middle or scroll)", name)
+		}
+
+		b.button = button
+		return
+	}
+
+	key, ok := KEYS[name]
+	if ok == false {
+		return b, fmt.Errorf("unknown key '%s'", name)
+	}
+
+	b.key = key
+	return
+}
+
+// Action associée à la touche, vide s'il n'y en a pas
+func (b *Bindings) key(key glfw.Key, modifiers glfw.ModifierKey) string {
+	return b.keys[binding{key: key, modifiers: modifiers}]
+}
+
+func (b *Bindings) button(button glfw.MouseButton, modifiers glfw.ModifierKey) string {
+	return b.buttons[binding{button: button, modifiers: modifiers}]
+}
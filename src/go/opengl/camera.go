@@ -0,0 +1,361 @@
+package opengl
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	PERSPECTIVE  = "perspective"
+	ORTHOGRAPHIC = "orthographic"
+
+	// Vues prédéfinies
+	PLAYER   = "player"
+	AUDIENCE = "audience"
+	TOP      = "top"
+
+	// Limites du plan de coupe & de la distance à la cible
+	CAMERA_NEAR  = 0.01
+	CAMERA_FAR   = 100.0
+	MIN_DISTANCE = 0.1
+	MAX_DISTANCE = 50.0
+
+	// Inclinaison maximale, la vue devenant indéfinie à la verticale
+	MAX_PITCH = 89.9
+
+	// Degrés par pixel de déplacement de la souris & facteur par cran de
+	// molette
+	ORBIT_SPEED = 0.3
+	ZOOM_FACTOR = 1.1
+)
+
+// Position de la caméra, enregistrable : elle tourne autour de la cible à
+// la distance indiquée. Les angles sont en degrés.
+type CameraPreset struct {
+	Projection string     `json:"projection"`
+	Target     [3]float32 `json:"target"`
+	Yaw        float32    `json:"yaw"`
+	Pitch      float32    `json:"pitch"`
+	Distance   float32    `json:"distance"`
+
+	// Angle de vue vertical en perspective
+	Fov float32 `json:"fov"`
+}
+
+// Violon tenu horizontalement, le manche vers -Z
+var DEFAULT_CAMERA_PRESETS = map[string]CameraPreset{
+	// Depuis le menton du violoniste, le long du manche
+	PLAYER: {
+		Projection: PERSPECTIVE,
+		Yaw:        180,
+		Pitch:      25,
+		Distance:   1.5,
+		Fov:        60,
+	},
+	// Face au violoniste
+	AUDIENCE: {
+		Projection: PERSPECTIVE,
+		Pitch:      10,
+		Distance:   3,
+		Fov:        45,
+	},
+	TOP: {
+		Projection: ORTHOGRAPHIC,
+		Pitch:      MAX_PITCH,
+		Distance:   3,
+		Fov:        45,
+	},
+}
+
+// Presets affichés & enregistrés par les actions des raccourcis
+var CAMERA_VIEWS = map[string]string{
+	VIEW_PLAYER:   PLAYER,
+	VIEW_AUDIENCE: AUDIENCE,
+	VIEW_TOP:      TOP,
+}
+
+var CAMERA_SAVES = map[string]string{
+	SAVE_PLAYER:   PLAYER,
+	SAVE_AUDIENCE: AUDIENCE,
+	SAVE_TOP:      TOP,
+}
+
+type Camera struct {
+	CameraPreset
+
+	aspect float32
+
+	// Vues enregistrées & fichier dans lequel elles sont sauvegardées
+	presets map[string]CameraPreset
+	path    string
+
+	// Bouton de la souris maintenu & dernière position du curseur
+	dragging bool
+	button   glfw.MouseButton
+	cursorX  float64
+	cursorY  float64
+}
+
+// Caméra placée selon le preset, les presets étant chargés depuis le
+// fichier s'il existe
+func CreateCamera(width int, height int, preset string, path string) (c *Camera, err error) {
+
+	c = &Camera{
+		presets: make(map[string]CameraPreset),
+		path:    path,
+	}
+
+	for name, p := range DEFAULT_CAMERA_PRESETS {
+		c.presets[name] = p
+	}
+
+	if path != "" {
+		if err = c.loadPresets(); err != nil {
+			return
+		}
+	}
+
+	if err = c.SetPreset(preset); err != nil {
+		return
+	}
+
+	c.SetAspect(width, height)
+
+	return
+}
+
+func (c *Camera) loadPresets() error {
+
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var presets map[string]CameraPreset
+	if err = json.Unmarshal(data, &presets); err != nil {
+		return fmt.Errorf("%s: %s", c.path, err)
+	}
+
+	for name, preset := range presets {
+		if err = preset.check(); err != nil {
+			return fmt.Errorf("%s: preset '%s': %s", c.path, name, err)
+		}
+		c.presets[name] = preset
+	}
+
+	return nil
+}
+
+func (p *CameraPreset) check() error {
+
+	if p.Projection != PERSPECTIVE && p.Projection != ORTHOGRAPHIC {
+		return fmt.Errorf("unknown projection '%s' (expect %s or %s)",
+			p.Projection, PERSPECTIVE, ORTHOGRAPHIC)
+	}
+
+	if p.Distance < MIN_DISTANCE || p.Distance > MAX_DISTANCE {
+		return fmt.Errorf("distance %g out of range (expect %g-%g)", p.Distance, MIN_DISTANCE, MAX_DISTANCE)
+	}
+
+	if p.Fov <= 0 || p.Fov >= 180 {
+		return fmt.Errorf("invalid field of view %g", p.Fov)
+	}
+
+	return nil
+}
+
+// Noms des presets disponibles
+func (c *Camera) Presets() (names []string) {
+
+	for name := range c.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
+
+func (c *Camera) SetPreset(name string) error {
+
+	preset, ok := c.presets[name]
+	if ok == false {
+		return fmt.Errorf("unknown camera preset '%s'", name)
+	}
+
+	c.CameraPreset = preset
+
+	return nil
+}
+
+// Enregistre la position courante sous ce nom & sauvegarde l'ensemble des
+// presets dans le fichier
+func (c *Camera) SavePreset(name string) error {
+
+	c.presets[name] = c.CameraPreset
+
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.presets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+func (c *Camera) SetAspect(width int, height int) {
+	if width > 0 && height > 0 {
+		c.aspect = float32(width) / float32(height)
+	}
+}
+
+func (c *Camera) ToggleProjection() {
+	if c.Projection == PERSPECTIVE {
+		c.Projection = ORTHOGRAPHIC
+	} else {
+		c.Projection = PERSPECTIVE
+	}
+}
+
+// Position de la caméra sur la sphère centrée sur la cible
+func (c *Camera) GetPosition() mgl32.Vec3 {
+
+	yaw := float64(mgl32.DegToRad(c.Yaw))
+	pitch := float64(mgl32.DegToRad(c.Pitch))
+
+	offset := mgl32.Vec3{
+		float32(math.Cos(pitch) * math.Sin(yaw)),
+		float32(math.Sin(pitch)),
+		float32(math.Cos(pitch) * math.Cos(yaw)),
+	}
+
+	return mgl32.Vec3(c.Target).Add(offset.Mul(c.Distance))
+}
+
+func (c *Camera) GetView() mgl32.Mat4 {
+	return mgl32.LookAtV(c.GetPosition(), mgl32.Vec3(c.Target), mgl32.Vec3{0, 1, 0})
+}
+
+// En orthographique, la hauteur visible est celle de la perspective à la
+// distance de la cible, pour que le changement de projection garde la taille
+// des objets
+func (c *Camera) GetProjection() mgl32.Mat4 {
+
+	if c.Projection == ORTHOGRAPHIC {
+		height := c.Distance * float32(math.Tan(float64(mgl32.DegToRad(c.Fov))/2))
+		width := height * c.aspect
+
+		return mgl32.Ortho(-width, width, -height, height, -CAMERA_FAR, CAMERA_FAR)
+	}
+
+	return mgl32.Perspective(mgl32.DegToRad(c.Fov), c.aspect, CAMERA_NEAR, CAMERA_FAR)
+}
+
+// Rotation autour de la cible, en degrés
+func (c *Camera) Orbit(yaw float32, pitch float32) {
+
+	c.Yaw = float32(math.Mod(float64(c.Yaw+yaw), 360))
+	c.Pitch += pitch
+
+	if c.Pitch > MAX_PITCH {
+		c.Pitch = MAX_PITCH
+	} else if c.Pitch < -MAX_PITCH {
+		c.Pitch = -MAX_PITCH
+	}
+}
+
+// Déplacement de la cible dans le plan de l'écran, en fractions de la
+// hauteur visible
+func (c *Camera) Pan(x float32, y float32) {
+
+	c.Target = mgl32.Vec3(c.Target).Sub(c.ScreenOffset(x, y))
+}
+
+// Axes horizontal & vertical de l'écran dans le repère de la scène
+func (c *Camera) GetAxes() (right mgl32.Vec3, up mgl32.Vec3) {
+
+	view := c.GetView()
+	right = mgl32.Vec3{view[0], view[4], view[8]}
+	up = mgl32.Vec3{view[1], view[5], view[9]}
+
+	return
+}
+
+// Déplacement dans la scène correspondant à un déplacement à l'écran, en
+// fractions de la hauteur visible à la distance de la cible (y vers le bas)
+func (c *Camera) ScreenOffset(x float32, y float32) mgl32.Vec3 {
+
+	right, up := c.GetAxes()
+	height := 2 * c.Distance * float32(math.Tan(float64(mgl32.DegToRad(c.Fov))/2))
+
+	return right.Mul(x * height).Sub(up.Mul(y * height))
+}
+
+// Rapproche la caméra de la cible pour un facteur supérieur à 1
+func (c *Camera) Zoom(factor float32) {
+
+	if factor <= 0 {
+		return
+	}
+
+	c.Distance /= factor
+
+	if c.Distance < MIN_DISTANCE {
+		c.Distance = MIN_DISTANCE
+	} else if c.Distance > MAX_DISTANCE {
+		c.Distance = MAX_DISTANCE
+	}
+}
+
+// Bouton gauche : rotation, bouton droit ou central : déplacement
+func (c *Camera) mouseButton(window *glfw.Window, button glfw.MouseButton, action glfw.Action, modifiers glfw.ModifierKey) {
+
+	switch action {
+	case glfw.Press:
+		if c.dragging == false {
+			c.dragging = true
+			c.button = button
+			c.cursorX, c.cursorY = window.GetCursorPos()
+		}
+
+	case glfw.Release:
+		if button == c.button {
+			c.dragging = false
+		}
+	}
+}
+
+func (c *Camera) cursorPosition(window *glfw.Window, x float64, y float64) {
+
+	if c.dragging == false {
+		return
+	}
+
+	dx, dy := float32(x-c.cursorX), float32(y-c.cursorY)
+	c.cursorX, c.cursorY = x, y
+
+	if c.button == glfw.MouseButtonLeft {
+		c.Orbit(-dx*ORBIT_SPEED, dy*ORBIT_SPEED)
+		return
+	}
+
+	_, height := window.GetSize()
+	if height > 0 {
+		c.Pan(dx/float32(height), dy/float32(height))
+	}
+}
+
+func (c *Camera) scroll(window *glfw.Window, x float64, y float64) {
+	c.Zoom(float32(math.Pow(ZOOM_FACTOR, y)))
+}
@@ -0,0 +1,119 @@
+//go:build egl && !osmesa
+// +build egl,!osmesa
+
+package opengl
+
+/*
+#cgo LDFLAGS: -lEGL
+
+#include <stdlib.h>
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+// Affichage sans surface de Mesa, à défaut l'affichage par défaut
+static EGLDisplay surfacelessDisplay() {
+
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC) eglGetProcAddress("eglGetPlatformDisplayEXT");
+
+	if (getPlatformDisplay != NULL) {
+		EGLDisplay display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+		if (display != EGL_NO_DISPLAY) {
+			return display;
+		}
+	}
+
+	return eglGetDisplay(EGL_DEFAULT_DISPLAY);
+}
+
+static EGLContext createContext(EGLDisplay display) {
+
+	// Aucun type de surface n'est exigé, le rendu se faisant dans un FBO
+	EGLint configAttributes[] = {
+		EGL_SURFACE_TYPE, 0,
+		EGL_RENDERABLE_TYPE, EGL_OPENGL_BIT,
+		EGL_NONE
+	};
+
+	EGLint contextAttributes[] = {
+		EGL_CONTEXT_MAJOR_VERSION, 4,
+		EGL_CONTEXT_MINOR_VERSION, 1,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+		EGL_NONE
+	};
+
+	EGLConfig config;
+	EGLint count = 0;
+
+	if (eglChooseConfig(display, configAttributes, &config, 1, &count) == EGL_FALSE || count == 0) {
+		return EGL_NO_CONTEXT;
+	}
+
+	return eglCreateContext(display, config, EGL_NO_CONTEXT, contextAttributes);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+const CONTEXT_API = "EGL"
+
+// Contexte OpenGL sans fenêtre, sur l'affichage sans surface de Mesa
+// (llvmpipe sans GPU) ou sur celui du pilote
+type headlessContext struct {
+	display C.EGLDisplay
+	context C.EGLContext
+}
+
+func createHeadlessContext(width int, height int) (c *headlessContext, err error) {
+
+	c = &headlessContext{
+		display: C.surfacelessDisplay(),
+	}
+
+	// cgo représente EGLDisplay par un entier
+	if c.display == 0 {
+		return nil, fmt.Errorf("egl: no display")
+	}
+
+	var major, minor C.EGLint
+	if C.eglInitialize(c.display, &major, &minor) == C.EGL_FALSE {
+		return nil, fmt.Errorf("egl: initialization failed (0x%x)", C.eglGetError())
+	}
+
+	if C.eglBindAPI(C.EGL_OPENGL_API) == C.EGL_FALSE {
+		C.eglTerminate(c.display)
+		return nil, fmt.Errorf("egl: OpenGL not supported (0x%x)", C.eglGetError())
+	}
+
+	c.context = C.createContext(c.display)
+	if c.context == nil {
+		C.eglTerminate(c.display)
+		return nil, fmt.Errorf("egl: OpenGL 4.1 core context creation failed (0x%x)", C.eglGetError())
+	}
+
+	if C.eglMakeCurrent(c.display, nil, nil, c.context) == C.EGL_FALSE {
+		c.destroy()
+		return nil, fmt.Errorf("egl: surfaceless context not supported (0x%x)", C.eglGetError())
+	}
+
+	return
+}
+
+func (c *headlessContext) procAddress(name string) unsafe.Pointer {
+
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	return unsafe.Pointer(C.eglGetProcAddress(cname))
+}
+
+func (c *headlessContext) destroy() {
+
+	C.eglMakeCurrent(c.display, nil, nil, nil)
+	C.eglDestroyContext(c.display, c.context)
+	C.eglTerminate(c.display)
+}
@@ -0,0 +1,26 @@
+//go:build !egl && !osmesa
+// +build !egl,!osmesa
+
+package opengl
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const CONTEXT_API = "none"
+
+// Sans -tags egl ni -tags osmesa, aucune bibliothèque de contexte sans
+// fenêtre n'est liée & le rendu hors écran est indisponible
+type headlessContext struct{}
+
+func createHeadlessContext(width int, height int) (c *headlessContext, err error) {
+	return nil, errors.New("offscreen rendering not built in (build with -tags egl or -tags osmesa)")
+}
+
+func (c *headlessContext) procAddress(name string) unsafe.Pointer {
+	return nil
+}
+
+func (c *headlessContext) destroy() {
+}
@@ -0,0 +1,76 @@
+//go:build osmesa
+// +build osmesa
+
+package opengl
+
+/*
+#cgo LDFLAGS: -lOSMesa
+
+#include <stdlib.h>
+#include <GL/osmesa.h>
+
+static OSMesaContext createContext() {
+
+	const int attributes[] = {
+		OSMESA_FORMAT, OSMESA_RGBA,
+		OSMESA_DEPTH_BITS, 24,
+		OSMESA_PROFILE, OSMESA_CORE_PROFILE,
+		OSMESA_CONTEXT_MAJOR_VERSION, 4,
+		OSMESA_CONTEXT_MINOR_VERSION, 1,
+		0
+	};
+
+	return OSMesaCreateContextAttribs(attributes, NULL);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+const CONTEXT_API = "OSMesa"
+
+// Contexte OpenGL rendu par le processeur, sans affichage ni GPU
+type headlessContext struct {
+	context C.OSMesaContext
+
+	// Framebuffer par défaut exigé par OSMesa, alloué hors de la mémoire
+	// gérée par Go
+	buffer unsafe.Pointer
+}
+
+func createHeadlessContext(width int, height int) (c *headlessContext, err error) {
+
+	c = &headlessContext{
+		context: C.createContext(),
+	}
+
+	if c.context == nil {
+		return nil, fmt.Errorf("osmesa: OpenGL 4.1 core context creation failed")
+	}
+
+	c.buffer = C.malloc(C.size_t(width * height * 4))
+
+	if C.OSMesaMakeCurrent(c.context, c.buffer, C.GL_UNSIGNED_BYTE, C.GLsizei(width), C.GLsizei(height)) == C.GL_FALSE {
+		c.destroy()
+		return nil, fmt.Errorf("osmesa: context activation failed")
+	}
+
+	return
+}
+
+func (c *headlessContext) procAddress(name string) unsafe.Pointer {
+
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	return unsafe.Pointer(C.OSMesaGetProcAddress(cname))
+}
+
+func (c *headlessContext) destroy() {
+
+	C.OSMesaDestroyContext(c.context)
+	C.free(c.buffer)
+}
@@ -0,0 +1,249 @@
+package opengl
+
+import (
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"log"
+	"sync/atomic"
+)
+
+// Objet manipulé au clavier & à la souris, nil s'il n'y en a pas
+func (w *Window) Selected() *Object {
+
+	if w.selected < 0 || w.selected >= len(w.objects) {
+		return nil
+	}
+
+	return w.objects[w.selected]
+}
+
+func (w *Window) selectNext() {
+
+	if len(w.objects) == 0 {
+		return
+	}
+
+	w.selected = (w.selected + 1) % len(w.objects)
+
+	name := w.objects[w.selected].name
+	if name == "" {
+		name = "object"
+	}
+
+	log.Printf("view3d: %s selected (%d/%d)", name, w.selected+1, len(w.objects))
+}
+
+// Les capteurs ne doivent pas modifier les objets pendant leur manipulation
+func (w *Window) Frozen() bool {
+	return atomic.LoadInt32(&w.frozen) == 1
+}
+
+func (w *Window) SetFrozen(frozen bool) {
+
+	var value int32
+	if frozen {
+		value = 1
+	}
+
+	if atomic.SwapInt32(&w.frozen, value) != value {
+		if frozen {
+			log.Printf("view3d: sensors frozen")
+		} else {
+			log.Printf("view3d: sensors resumed")
+		}
+	}
+}
+
+func (w *Window) keyboard(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, modifiers glfw.ModifierKey) {
+
+	if action == glfw.Release {
+		return
+	}
+
+	bound := w.bindings.key(key, modifiers)
+
+	// Les actions ponctuelles ne sont pas répétées par l'appui prolongé
+	switch bound {
+	case SELECT_NEXT, FREEZE, RESET, TOGGLE_HUD, FULLSCREEN, NEXT_MONITOR, TOGGLE_PROJECTION,
+		VIEW_PLAYER, VIEW_AUDIENCE, VIEW_TOP, SAVE_PLAYER, SAVE_AUDIENCE, SAVE_TOP:
+		if action == glfw.Press {
+			w.apply(bound)
+		}
+	default:
+		w.apply(bound)
+	}
+}
+
+func (w *Window) apply(action string) {
+
+	switch action {
+	case SELECT_NEXT:
+		w.selectNext()
+		return
+	case FREEZE:
+		w.SetFrozen(w.Frozen() == false)
+		return
+	case TOGGLE_HUD:
+		if w.overlay != nil {
+			w.overlay.SetHidden(w.overlay.Hidden() == false)
+		}
+		return
+	case FULLSCREEN:
+		w.SetFullscreen(w.Fullscreen() == false)
+		return
+	case NEXT_MONITOR:
+		w.nextMonitor()
+		return
+	case TOGGLE_PROJECTION:
+		w.camera.ToggleProjection()
+		return
+	}
+
+	if preset, ok := CAMERA_VIEWS[action]; ok {
+		w.camera.SetPreset(preset)
+		return
+	}
+
+	if preset, ok := CAMERA_SAVES[action]; ok {
+		if err := w.camera.SavePreset(preset); err != nil {
+			log.Printf("camera: %s", err)
+		} else {
+			log.Printf("camera: %s view saved", preset)
+		}
+		return
+	}
+
+	object := w.Selected()
+	if object == nil {
+		return
+	}
+
+	transform := object.GetTransform()
+
+	switch action {
+	case MOVE_LEFT:
+		transform.Move(-MOVE_STEP, 0.0, 0.0)
+	case MOVE_RIGHT:
+		transform.Move(MOVE_STEP, 0.0, 0.0)
+	case MOVE_UP:
+		transform.Move(0.0, MOVE_STEP, 0.0)
+	case MOVE_DOWN:
+		transform.Move(0.0, -MOVE_STEP, 0.0)
+	case MOVE_FORWARD:
+		transform.Move(0.0, 0.0, -MOVE_STEP)
+	case MOVE_BACKWARD:
+		transform.Move(0.0, 0.0, MOVE_STEP)
+
+	case ROTATE_X_POSITIVE:
+		transform.Rotate(ROTATE_STEP, 0.0, 0.0)
+	case ROTATE_X_NEGATIVE:
+		transform.Rotate(-ROTATE_STEP, 0.0, 0.0)
+	case ROTATE_Y_POSITIVE:
+		transform.Rotate(0.0, ROTATE_STEP, 0.0)
+	case ROTATE_Y_NEGATIVE:
+		transform.Rotate(0.0, -ROTATE_STEP, 0.0)
+	case ROTATE_Z_POSITIVE:
+		transform.Rotate(0.0, 0.0, ROTATE_STEP)
+	case ROTATE_Z_NEGATIVE:
+		transform.Rotate(0.0, 0.0, -ROTATE_STEP)
+
+	case SCALE_UP:
+		transform.Scale(SCALE_STEP, SCALE_STEP, SCALE_STEP)
+	case SCALE_DOWN:
+		transform.Scale(-SCALE_STEP, -SCALE_STEP, -SCALE_STEP)
+
+	case RESET:
+		transform.Reset()
+	}
+}
+
+// Le bouton associé à une action manipule l'objet sélectionné, les autres
+// la caméra
+func (w *Window) mouseButton(window *glfw.Window, button glfw.MouseButton, action glfw.Action, modifiers glfw.ModifierKey) {
+
+	switch action {
+	case glfw.Press:
+		if w.dragging == "" {
+			if w.dragging = w.bindings.button(button, modifiers); w.dragging != "" {
+				w.dragButton = button
+				w.cursorX, w.cursorY = window.GetCursorPos()
+				return
+			}
+		}
+
+	case glfw.Release:
+		if w.dragging != "" && button == w.dragButton {
+			w.dragging = ""
+			return
+		}
+	}
+
+	w.camera.mouseButton(window, button, action, modifiers)
+}
+
+func (w *Window) cursorPosition(window *glfw.Window, x float64, y float64) {
+
+	if w.dragging == "" {
+		w.camera.cursorPosition(window, x, y)
+		return
+	}
+
+	dx, dy := float32(x-w.cursorX), float32(y-w.cursorY)
+	w.cursorX, w.cursorY = x, y
+
+	object := w.Selected()
+	if object == nil {
+		return
+	}
+
+	transform := object.GetTransform()
+
+	switch w.dragging {
+	case MOUSE_ROTATE:
+		// L'objet suit le curseur autour des axes de l'écran
+		right, up := w.camera.GetAxes()
+		transform.RotateAxis(dx*MOUSE_ROTATE_SPEED, up)
+		transform.RotateAxis(dy*MOUSE_ROTATE_SPEED, right)
+
+	case MOUSE_MOVE:
+		_, height := window.GetSize()
+		if height > 0 {
+			offset := w.camera.ScreenOffset(dx/float32(height), dy/float32(height))
+			transform.Move(offset.X(), offset.Y(), offset.Z())
+		}
+	}
+}
+
+// La molette ne transmet pas les modificateurs, relus depuis le clavier
+func (w *Window) scroll(window *glfw.Window, x float64, y float64) {
+
+	if w.bindings.scroll[currentModifiers(window)] == MOUSE_SCALE {
+		if object := w.Selected(); object != nil {
+			step := float32(y) * SCALE_STEP
+			object.GetTransform().Scale(step, step, step)
+		}
+		return
+	}
+
+	w.camera.scroll(window, x, y)
+}
+
+func currentModifiers(window *glfw.Window) (modifiers glfw.ModifierKey) {
+
+	for _, key := range []struct {
+		keys     []glfw.Key
+		modifier glfw.ModifierKey
+	}{
+		{[]glfw.Key{glfw.KeyLeftShift, glfw.KeyRightShift}, glfw.ModShift},
+		{[]glfw.Key{glfw.KeyLeftControl, glfw.KeyRightControl}, glfw.ModControl},
+		{[]glfw.Key{glfw.KeyLeftAlt, glfw.KeyRightAlt}, glfw.ModAlt},
+		{[]glfw.Key{glfw.KeyLeftSuper, glfw.KeyRightSuper}, glfw.ModSuper},
+	} {
+		for _, k := range key.keys {
+			if window.GetKey(k) == glfw.Press {
+				modifiers |= key.modifier
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Densité d'un écran affiché sans agrandissement, en points par pouce
+const STANDARD_DPI = 96
+
+// Écran désigné par son nom ou son numéro à partir de 0, à défaut l'écran
+// principal
+func findMonitor(name string) (*glfw.Monitor, error) {
+
+	monitors := glfw.GetMonitors()
+	if len(monitors) == 0 {
+		return nil, fmt.Errorf("no monitor found")
+	}
+
+	if name == "" {
+		if primary := glfw.GetPrimaryMonitor(); primary != nil {
+			return primary, nil
+		}
+		return monitors[0], nil
+	}
+
+	if index, err := strconv.Atoi(name); err == nil {
+		if index < 0 || index >= len(monitors) {
+			return nil, fmt.Errorf("invalid monitor %d (expect 0-%d)", index, len(monitors)-1)
+		}
+		return monitors[index], nil
+	}
+
+	names := make([]string, len(monitors))
+	for idx, monitor := range monitors {
+		if names[idx] = monitor.GetName(); names[idx] == name {
+			return monitor, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown monitor '%s' (expect %s or a number)", name, strings.Join(names, ", "))
+}
+
+// Agrandissement déduit de la densité de l'écran, par demi-unité
+func monitorScale(monitor *glfw.Monitor) float32 {
+
+	mode := monitor.GetVideoMode()
+	width, _ := monitor.GetPhysicalSize()
+	if mode == nil || width <= 0 {
+		return 1
+	}
+
+	dpi := float64(mode.Width) / (float64(width) / 25.4)
+
+	return float32(math.Max(1, math.Round(2*dpi/STANDARD_DPI)/2))
+}
+
+func (w *Window) Fullscreen() bool {
+	return w.window.GetMonitor() != nil
+}
+
+// La fenêtre retrouve sa position & sa taille en quittant le plein écran
+func (w *Window) SetFullscreen(fullscreen bool) {
+
+	if fullscreen == w.Fullscreen() {
+		return
+	}
+
+	if fullscreen {
+		x, y := w.window.GetPos()
+		width, height := w.window.GetSize()
+		w.windowed = [4]int{x, y, width, height}
+
+		mode := w.monitor.GetVideoMode()
+		w.window.SetMonitor(w.monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+	} else {
+		w.window.SetMonitor(nil, w.windowed[0], w.windowed[1], w.windowed[2], w.windowed[3], 0)
+	}
+
+	// Certains pilotes oublient l'intervalle d'échange
+	w.setVsync()
+}
+
+// Plein écran sur l'écran suivant, par exemple un projecteur
+func (w *Window) nextMonitor() {
+
+	monitors := glfw.GetMonitors()
+	if len(monitors) < 2 {
+		return
+	}
+
+	next := monitors[0]
+	for idx, monitor := range monitors {
+		if monitor == w.monitor {
+			next = monitors[(idx+1)%len(monitors)]
+		}
+	}
+
+	w.monitor = next
+	log.Printf("view3d: monitor %s", next.GetName())
+
+	if w.Fullscreen() {
+		mode := next.GetVideoMode()
+		w.window.SetMonitor(next, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+		w.setVsync()
+	}
+
+	w.updateScale()
+}
+
+func (w *Window) setVsync() {
+
+	if w.options.Vsync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+}
+
+func (w *Window) windowSize(window *glfw.Window, width int, height int) {
+	w.updateScale()
+}
+
+func (w *Window) framebufferSize(window *glfw.Window, width int, height int) {
+	w.resize(width, height)
+	w.updateScale()
+}
+
+// Le framebuffer est plus grand que la fenêtre sur les écrans à haute
+// densité de certains systèmes, ailleurs la densité de l'écran est utilisée
+func (w *Window) updateScale() {
+
+	if w.options.Scale > 0 {
+		return
+	}
+
+	width, _ := w.window.GetSize()
+	framebuffer, _ := w.window.GetFramebufferSize()
+	if width <= 0 || framebuffer <= 0 {
+		return
+	}
+
+	if ratio := float32(framebuffer) / float32(width); ratio > 1 {
+		w.scale = ratio
+		return
+	}
+
+	monitor := w.window.GetMonitor()
+	if monitor == nil {
+		monitor = w.monitor
+	}
+
+	w.scale = monitorScale(monitor)
+}
@@ -0,0 +1,211 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+)
+
+// Caractères rendus dans l'atlas : ASCII imprimable & symboles des mesures,
+// les autres étant remplacés par '?'
+const FONT_CHARACTERS = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~°±µ"
+
+// Largeur de l'atlas, sa hauteur dépendant de la taille de la police
+const ATLAS_WIDTH = 512
+
+// Taille par défaut des caractères, en pixels
+const DEFAULT_FONT_SIZE = 14
+
+// Caractère placé dans l'atlas, décalé par rapport à la ligne de base
+type glyph struct {
+	offset  mgl32.Vec2
+	size    mgl32.Vec2
+	uv      [2]mgl32.Vec2
+	advance float32
+}
+
+// Police rendue une fois pour toutes dans une texture, chaque caractère étant
+// ensuite un rectangle de cette texture
+type Font struct {
+	texture *Texture
+	glyphs  map[rune]glyph
+
+	// Pixels de l'atlas par pixel affiché
+	scale float32
+
+	// Hauteur d'une ligne & distance du haut de la ligne à la ligne de base
+	height float32
+	ascent float32
+
+	// Point opaque de l'atlas, utilisé pour les formes pleines
+	solid mgl32.Vec2
+}
+
+// Police TrueType ou OpenType de la taille spécifiée, à défaut la police
+// bitmap 7x13 intégrée. Les caractères vectoriels sont rendus avec scale
+// pixels de l'atlas par pixel affiché pour rester nets sur les écrans à haute
+// densité, la police bitmap étant agrandie.
+func LoadFont(filename string, size float64, scale float32) (f *Font, err error) {
+
+	if size <= 0 {
+		size = DEFAULT_FONT_SIZE
+	}
+
+	if scale <= 0 || filename == "" {
+		scale = 1
+	}
+
+	face := font.Face(basicfont.Face7x13)
+
+	if filename != "" {
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := opentype.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+
+		if face, err = opentype.NewFace(parsed, &opentype.FaceOptions{
+			Size:    size * float64(scale),
+			DPI:     72,
+			Hinting: font.HintingFull,
+		}); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		defer face.Close()
+	}
+
+	return createFont(face, scale)
+}
+
+func createFont(face font.Face, scale float32) (f *Font, err error) {
+
+	metrics := face.Metrics()
+
+	f = &Font{
+		glyphs: make(map[rune]glyph),
+		scale:  scale,
+		height: float32(metrics.Height.Ceil()) / scale,
+		ascent: float32(metrics.Ascent.Ceil()) / scale,
+	}
+
+	type placed struct {
+		r      rune
+		mask   *image.Alpha
+		bounds image.Rectangle
+	}
+
+	var characters []placed
+
+	// Rangement des caractères ligne par ligne, après un carré opaque de
+	// 2x2 pixels en haut à gauche
+	const margin = 1
+	x, y, rowHeight := 2+2*margin, margin, 2
+
+	for _, r := range FONT_CHARACTERS {
+
+		bounds, mask, from, advance, ok := face.Glyph(fixed.Point26_6{}, r)
+		if ok == false {
+			continue
+		}
+
+		size := bounds.Size()
+		if x+size.X+margin > ATLAS_WIDTH {
+			x, y, rowHeight = margin, y+rowHeight+margin, 0
+		}
+
+		// Le masque peut être réutilisé par l'appel suivant
+		copied := image.NewAlpha(image.Rect(0, 0, size.X, size.Y))
+		draw.Draw(copied, copied.Bounds(), mask, from, draw.Src)
+
+		characters = append(characters, placed{r, copied, bounds.Add(image.Pt(x, y).Sub(bounds.Min))})
+
+		f.glyphs[r] = glyph{
+			offset:  mgl32.Vec2{float32(bounds.Min.X), float32(bounds.Min.Y)}.Mul(1 / scale),
+			size:    mgl32.Vec2{float32(size.X), float32(size.Y)}.Mul(1 / scale),
+			advance: float32(advance) / 64 / scale,
+		}
+
+		x += size.X + margin
+		if size.Y > rowHeight {
+			rowHeight = size.Y
+		}
+	}
+
+	if _, ok := f.glyphs['?']; ok == false {
+		return nil, fmt.Errorf("font: missing basic characters")
+	}
+
+	height := y + rowHeight + margin
+
+	// Caractères blancs, seule la transparence variant
+	atlas := image.NewRGBA(image.Rect(0, 0, ATLAS_WIDTH, height))
+	white := image.NewUniform(color.White)
+
+	solid := image.Rect(margin, margin, margin+2, margin+2)
+	draw.Draw(atlas, solid, white, image.Point{}, draw.Src)
+
+	uv := func(x int, y int) mgl32.Vec2 {
+		return mgl32.Vec2{float32(x) / ATLAS_WIDTH, float32(y) / float32(height)}
+	}
+
+	f.solid = uv(margin+1, margin+1)
+
+	for _, character := range characters {
+
+		draw.DrawMask(atlas, character.bounds, white, image.Point{}, character.mask, image.Point{}, draw.Over)
+
+		g := f.glyphs[character.r]
+		g.uv = [2]mgl32.Vec2{
+			uv(character.bounds.Min.X, character.bounds.Min.Y),
+			uv(character.bounds.Max.X, character.bounds.Max.Y),
+		}
+		f.glyphs[character.r] = g
+	}
+
+	if f.texture, err = CreateTextureFromImage(atlas); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Hauteur d'une ligne de texte, en pixels affichés
+func (f *Font) LineHeight() float32 {
+	return f.height
+}
+
+// Largeur du texte, en pixels affichés
+func (f *Font) Width(text string) (width float32) {
+
+	for _, r := range text {
+		width += f.glyph(r).advance
+	}
+
+	return
+}
+
+func (f *Font) glyph(r rune) glyph {
+
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+
+	return f.glyphs['?']
+}
+
+func (f *Font) Destroy() {
+	f.texture.Destroy()
+}
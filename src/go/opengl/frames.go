@@ -0,0 +1,123 @@
+package opengl
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Nom des images PNG, numérotées à partir de 0
+const FRAME_PATTERN = "frame_%06d.png"
+
+// Destination des images rendues : fichiers PNG d'un répertoire et/ou
+// images brutes envoyées à un encodeur
+type FrameOutput struct {
+	dir    string
+	frames int
+
+	encoder *exec.Cmd
+	stdin   io.WriteCloser
+}
+
+// Le répertoire est créé s'il n'existe pas, vide pour ne pas écrire de PNG.
+// L'encodeur est une commande exécutée par sh -c recevant les images RGBA
+// sur son entrée, {width}, {height} & {fps} y étant remplacés, par exemple
+// "ffmpeg -f rawvideo -pix_fmt rgba -s {width}x{height} -r {fps} -i - violin.mp4"
+func CreateFrameOutput(dir string, encoder string, width int, height int, fps float64) (f *FrameOutput, err error) {
+
+	if dir == "" && encoder == "" {
+		return nil, fmt.Errorf("no frame output")
+	}
+
+	f = &FrameOutput{
+		dir: dir,
+	}
+
+	if dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if encoder != "" {
+
+		command := strings.NewReplacer(
+			"{width}", strconv.Itoa(width),
+			"{height}", strconv.Itoa(height),
+			"{fps}", strconv.FormatFloat(fps, 'g', -1, 64),
+		).Replace(encoder)
+
+		f.encoder = exec.Command("sh", "-c", command)
+		f.encoder.Stdout = os.Stdout
+		f.encoder.Stderr = os.Stderr
+
+		if f.stdin, err = f.encoder.StdinPipe(); err != nil {
+			return nil, err
+		}
+
+		if err = f.encoder.Start(); err != nil {
+			return nil, fmt.Errorf("encoder: %s", err)
+		}
+	}
+
+	return
+}
+
+func (f *FrameOutput) Write(img *image.RGBA) (err error) {
+
+	if f.dir != "" {
+		if err = writePng(filepath.Join(f.dir, fmt.Sprintf(FRAME_PATTERN, f.frames)), img); err != nil {
+			return
+		}
+	}
+
+	if f.stdin != nil {
+		if _, err = f.stdin.Write(img.Pix); err != nil {
+			return fmt.Errorf("encoder: %s", err)
+		}
+	}
+
+	f.frames++
+	return
+}
+
+func (f *FrameOutput) Frames() int {
+	return f.frames
+}
+
+// Attend la fin de l'encodage
+func (f *FrameOutput) Close() error {
+
+	if f.encoder == nil {
+		return nil
+	}
+
+	f.stdin.Close()
+
+	if err := f.encoder.Wait(); err != nil {
+		return fmt.Errorf("encoder: %s", err)
+	}
+
+	return nil
+}
+
+func writePng(filename string, img image.Image) (err error) {
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return
+	}
+
+	if err = png.Encode(file, img); err != nil {
+		file.Close()
+		return
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,765 @@
+package opengl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Fichier binaire .glb : en-tête puis morceaux JSON & BIN
+	GLB_MAGIC = 0x46546C67
+	GLB_JSON  = 0x4E4F534A
+	GLB_BIN   = 0x004E4942
+
+	GLTF_TRIANGLES = 4
+
+	// Types des composantes des accessors
+	GLTF_BYTE           = 5120
+	GLTF_UNSIGNED_BYTE  = 5121
+	GLTF_SHORT          = 5122
+	GLTF_UNSIGNED_SHORT = 5123
+	GLTF_UNSIGNED_INT   = 5125
+	GLTF_FLOAT          = 5126
+)
+
+var GLTF_COMPONENTS = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+}
+
+// Sous-ensemble de la spécification glTF 2.0 utilisé pour l'affichage
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+
+	Nodes []struct {
+		Mesh        *int      `json:"mesh"`
+		Children    []int     `json:"children"`
+		Matrix      []float32 `json:"matrix"`
+		Translation []float32 `json:"translation"`
+		Rotation    []float32 `json:"rotation"`
+		Scale       []float32 `json:"scale"`
+	} `json:"nodes"`
+
+	Meshes []struct {
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Material   *int           `json:"material"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+
+	Materials []struct {
+		Name                 string `json:"name"`
+		PbrMetallicRoughness struct {
+			BaseColorFactor  []float32 `json:"baseColorFactor"`
+			BaseColorTexture *struct {
+				Index int `json:"index"`
+			} `json:"baseColorTexture"`
+			MetallicFactor  *float32 `json:"metallicFactor"`
+			RoughnessFactor *float32 `json:"roughnessFactor"`
+		} `json:"pbrMetallicRoughness"`
+		NormalTexture *struct {
+			Index int `json:"index"`
+		} `json:"normalTexture"`
+	} `json:"materials"`
+
+	Textures []struct {
+		Source *int `json:"source"`
+	} `json:"textures"`
+
+	Images []struct {
+		Uri        string `json:"uri"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+
+	Accessors []struct {
+		BufferView    *int             `json:"bufferView"`
+		ByteOffset    int              `json:"byteOffset"`
+		ComponentType int              `json:"componentType"`
+		Normalized    bool             `json:"normalized"`
+		Count         int              `json:"count"`
+		Type          string           `json:"type"`
+		Sparse        *json.RawMessage `json:"sparse"`
+	} `json:"accessors"`
+
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+
+	Buffers []struct {
+		Uri        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+}
+
+type gltfLoader struct {
+	dir string
+	doc gltfDocument
+
+	// Morceau binaire d'un fichier .glb
+	bin []byte
+
+	buffers   [][]byte
+	materials []*Material
+	images    []image.Image
+
+	model *Model
+}
+
+// Charge un fichier glTF 2.0, ses buffers & images externes ou embarqués.
+// Les transformations des noeuds de la scène sont appliquées aux sommets,
+// l'origine de la scène servant de pivot.
+func loadGltf(filename string) (m *Model, err error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	l := &gltfLoader{
+		dir:   filepath.Dir(filename),
+		model: new(Model),
+	}
+
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == GLB_MAGIC {
+		if data, err = l.readGlb(data); err != nil {
+			return
+		}
+	}
+
+	if err = json.Unmarshal(data, &l.doc); err != nil {
+		return
+	}
+
+	if strings.HasPrefix(l.doc.Asset.Version, "2.") == false {
+		return nil, fmt.Errorf("unsupported glTF version '%s'", l.doc.Asset.Version)
+	}
+
+	if err = l.loadBuffers(); err != nil {
+		return
+	}
+
+	if err = l.loadMaterials(); err != nil {
+		return
+	}
+
+	for _, node := range l.rootNodes() {
+		if err = l.node(node, mgl32.Ident4(), 0); err != nil {
+			return
+		}
+	}
+
+	return l.model, nil
+}
+
+// Conteneur binaire : en-tête de 12 octets puis morceaux longueur, type &
+// données
+func (l *gltfLoader) readGlb(data []byte) (document []byte, err error) {
+
+	if len(data) < 12 {
+		return nil, errors.New("truncated glb header")
+	}
+
+	if version := binary.LittleEndian.Uint32(data[4:]); version != 2 {
+		return nil, fmt.Errorf("unsupported glb version %d", version)
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, errors.New("truncated glb file")
+	}
+
+	for offset := 12; offset+8 <= length; {
+
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		offset += 8
+
+		if chunkLength < 0 || offset+chunkLength > length {
+			return nil, errors.New("truncated glb chunk")
+		}
+
+		chunk := data[offset : offset+chunkLength]
+		offset += chunkLength
+
+		switch chunkType {
+		case GLB_JSON:
+			if document == nil {
+				document = chunk
+			}
+		case GLB_BIN:
+			if l.bin == nil {
+				l.bin = chunk
+			}
+		}
+	}
+
+	if document == nil {
+		return nil, errors.New("missing glb JSON chunk")
+	}
+
+	return
+}
+
+func (l *gltfLoader) loadBuffers() (err error) {
+
+	l.buffers = make([][]byte, len(l.doc.Buffers))
+
+	for idx, buffer := range l.doc.Buffers {
+
+		var data []byte
+
+		// Sans uri, le buffer est le morceau binaire du fichier .glb
+		if buffer.Uri == "" {
+			if l.bin == nil {
+				return fmt.Errorf("buffers[%d]: missing data", idx)
+			}
+			data = l.bin
+		} else if data, err = l.readUri(buffer.Uri); err != nil {
+			return fmt.Errorf("buffers[%d]: %s", idx, err)
+		}
+
+		if len(data) < buffer.ByteLength {
+			return fmt.Errorf("buffers[%d]: %d bytes, expect %d", idx, len(data), buffer.ByteLength)
+		}
+
+		l.buffers[idx] = data
+	}
+
+	return
+}
+
+// Données embarquées "data:...;base64," ou fichier relatif au modèle
+func (l *gltfLoader) readUri(uri string) ([]byte, error) {
+
+	if strings.HasPrefix(uri, "data:") {
+
+		idx := strings.IndexByte(uri, ',')
+		if idx < 0 || strings.HasSuffix(uri[:idx], ";base64") == false {
+			return nil, errors.New("unsupported data uri (expect base64)")
+		}
+
+		return base64.StdEncoding.DecodeString(uri[idx+1:])
+	}
+
+	path, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(filepath.Join(l.dir, filepath.FromSlash(path)))
+}
+
+// Octets d'un bufferView
+func (l *gltfLoader) bufferView(index int) (data []byte, stride int, err error) {
+
+	if index < 0 || index >= len(l.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("unknown bufferView %d", index)
+	}
+
+	view := l.doc.BufferViews[index]
+
+	if view.Buffer < 0 || view.Buffer >= len(l.buffers) {
+		return nil, 0, fmt.Errorf("bufferViews[%d]: unknown buffer %d", index, view.Buffer)
+	}
+
+	buffer := l.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset+view.ByteLength > len(buffer) {
+		return nil, 0, fmt.Errorf("bufferViews[%d]: out of buffer range", index)
+	}
+
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+// Valeurs d'un accessor converties en flottants, les entiers normalisés
+// étant ramenés entre 0 & 1 (ou -1 & 1)
+func (l *gltfLoader) accessor(index int, components int) (values []float32, err error) {
+
+	if index < 0 || index >= len(l.doc.Accessors) {
+		return nil, fmt.Errorf("unknown accessor %d", index)
+	}
+
+	accessor := l.doc.Accessors[index]
+
+	if accessor.Sparse != nil {
+		return nil, fmt.Errorf("accessors[%d]: sparse accessors unsupported", index)
+	}
+
+	if count, ok := GLTF_COMPONENTS[accessor.Type]; ok == false || count != components {
+		return nil, fmt.Errorf("accessors[%d]: type %s, expect %d components", index, accessor.Type, components)
+	}
+
+	if accessor.Count < 0 || accessor.ByteOffset < 0 {
+		return nil, fmt.Errorf("accessors[%d]: invalid count or offset", index)
+	}
+
+	var size int
+	switch accessor.ComponentType {
+	case GLTF_BYTE, GLTF_UNSIGNED_BYTE:
+		size = 1
+	case GLTF_SHORT, GLTF_UNSIGNED_SHORT:
+		size = 2
+	case GLTF_UNSIGNED_INT, GLTF_FLOAT:
+		size = 4
+	default:
+		return nil, fmt.Errorf("accessors[%d]: unknown component type %d", index, accessor.ComponentType)
+	}
+
+	values = make([]float32, accessor.Count*components)
+
+	// Sans bufferView, les valeurs sont nulles
+	if accessor.BufferView == nil {
+		return
+	}
+
+	data, stride, err := l.bufferView(*accessor.BufferView)
+	if err != nil {
+		return nil, fmt.Errorf("accessors[%d]: %s", index, err)
+	}
+
+	if stride == 0 {
+		stride = size * components
+	}
+
+	if accessor.Count > 0 &&
+		accessor.ByteOffset+(accessor.Count-1)*stride+size*components > len(data) {
+		return nil, fmt.Errorf("accessors[%d]: out of bufferView range", index)
+	}
+
+	for element := 0; element < accessor.Count; element++ {
+		offset := accessor.ByteOffset + element*stride
+
+		for component := 0; component < components; component++ {
+			value := data[offset+component*size:]
+
+			var v float32
+
+			switch accessor.ComponentType {
+			case GLTF_FLOAT:
+				v = math.Float32frombits(binary.LittleEndian.Uint32(value))
+			case GLTF_UNSIGNED_INT:
+				v = float32(binary.LittleEndian.Uint32(value))
+			case GLTF_UNSIGNED_SHORT:
+				v = float32(binary.LittleEndian.Uint16(value))
+				if accessor.Normalized {
+					v /= math.MaxUint16
+				}
+			case GLTF_SHORT:
+				v = float32(int16(binary.LittleEndian.Uint16(value)))
+				if accessor.Normalized {
+					v = float32(math.Max(float64(v)/math.MaxInt16, -1))
+				}
+			case GLTF_UNSIGNED_BYTE:
+				v = float32(value[0])
+				if accessor.Normalized {
+					v /= math.MaxUint8
+				}
+			case GLTF_BYTE:
+				v = float32(int8(value[0]))
+				if accessor.Normalized {
+					v = float32(math.Max(float64(v)/math.MaxInt8, -1))
+				}
+			}
+
+			values[element*components+component] = v
+		}
+	}
+
+	return
+}
+
+// Indices lus sans passer par les flottants, qui ne représentent pas
+// exactement les entiers au delà de 2^24
+func (l *gltfLoader) indices(index int) (indices []uint32, err error) {
+
+	if index < 0 || index >= len(l.doc.Accessors) {
+		return nil, fmt.Errorf("unknown accessor %d", index)
+	}
+
+	accessor := l.doc.Accessors[index]
+
+	if accessor.Type != "SCALAR" || accessor.BufferView == nil || accessor.Sparse != nil ||
+		accessor.Count < 0 || accessor.ByteOffset < 0 {
+		return nil, fmt.Errorf("accessors[%d]: invalid indices", index)
+	}
+
+	var size int
+	switch accessor.ComponentType {
+	case GLTF_UNSIGNED_BYTE:
+		size = 1
+	case GLTF_UNSIGNED_SHORT:
+		size = 2
+	case GLTF_UNSIGNED_INT:
+		size = 4
+	default:
+		return nil, fmt.Errorf("accessors[%d]: invalid indices type %d", index, accessor.ComponentType)
+	}
+
+	data, _, err := l.bufferView(*accessor.BufferView)
+	if err != nil {
+		return nil, fmt.Errorf("accessors[%d]: %s", index, err)
+	}
+
+	if accessor.ByteOffset+accessor.Count*size > len(data) {
+		return nil, fmt.Errorf("accessors[%d]: out of bufferView range", index)
+	}
+
+	indices = make([]uint32, accessor.Count)
+	data = data[accessor.ByteOffset:]
+
+	for idx := range indices {
+		switch size {
+		case 1:
+			indices[idx] = uint32(data[idx])
+		case 2:
+			indices[idx] = uint32(binary.LittleEndian.Uint16(data[idx*2:]))
+		case 4:
+			indices[idx] = binary.LittleEndian.Uint32(data[idx*4:])
+		}
+	}
+
+	return
+}
+
+// Couleur, métal, rugosité, texture de base & texture de normales des
+// matériaux PBR
+func (l *gltfLoader) loadMaterials() (err error) {
+
+	l.images = make([]image.Image, len(l.doc.Images))
+	l.materials = make([]*Material, len(l.doc.Materials))
+
+	for idx, m := range l.doc.Materials {
+
+		// Valeurs par défaut de la spécification
+		material := &Material{
+			Name:      m.Name,
+			Color:     mgl32.Vec4{1, 1, 1, 1},
+			Metallic:  1,
+			Roughness: 1,
+		}
+
+		pbr := m.PbrMetallicRoughness
+
+		if len(pbr.BaseColorFactor) == 4 {
+			copy(material.Color[:], pbr.BaseColorFactor)
+		}
+
+		if pbr.MetallicFactor != nil {
+			material.Metallic = *pbr.MetallicFactor
+		}
+
+		if pbr.RoughnessFactor != nil {
+			material.Roughness = *pbr.RoughnessFactor
+		}
+
+		if m.NormalTexture != nil {
+			if material.NormalImage, err = l.texture(m.NormalTexture.Index); err != nil {
+				return fmt.Errorf("materials[%d]: %s", idx, err)
+			}
+		}
+
+		if pbr.BaseColorTexture != nil {
+			if material.Image, err = l.texture(pbr.BaseColorTexture.Index); err != nil {
+				return fmt.Errorf("materials[%d]: %s", idx, err)
+			}
+		}
+
+		l.materials[idx] = material
+	}
+
+	return
+}
+
+func (l *gltfLoader) texture(index int) (img image.Image, err error) {
+
+	if index < 0 || index >= len(l.doc.Textures) {
+		return nil, fmt.Errorf("unknown texture %d", index)
+	}
+
+	source := l.doc.Textures[index].Source
+	if source == nil {
+		return nil, fmt.Errorf("textures[%d]: missing source", index)
+	}
+
+	if *source < 0 || *source >= len(l.doc.Images) {
+		return nil, fmt.Errorf("textures[%d]: unknown image %d", index, *source)
+	}
+
+	// Une image peut être partagée par plusieurs textures
+	if l.images[*source] != nil {
+		return l.images[*source], nil
+	}
+
+	var data []byte
+
+	if view := l.doc.Images[*source].BufferView; view != nil {
+		data, _, err = l.bufferView(*view)
+	} else {
+		data, err = l.readUri(l.doc.Images[*source].Uri)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("images[%d]: %s", *source, err)
+	}
+
+	if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("images[%d]: %s", *source, err)
+	}
+
+	l.images[*source] = img
+
+	return
+}
+
+// Noeuds de la scène affichée, à défaut ceux qui ne sont l'enfant d'aucun
+// autre
+func (l *gltfLoader) rootNodes() (nodes []int) {
+
+	if len(l.doc.Scenes) > 0 {
+		scene := 0
+		if l.doc.Scene != nil && *l.doc.Scene >= 0 && *l.doc.Scene < len(l.doc.Scenes) {
+			scene = *l.doc.Scene
+		}
+
+		return l.doc.Scenes[scene].Nodes
+	}
+
+	children := make(map[int]bool)
+	for _, node := range l.doc.Nodes {
+		for _, child := range node.Children {
+			children[child] = true
+		}
+	}
+
+	for idx := range l.doc.Nodes {
+		if children[idx] == false {
+			nodes = append(nodes, idx)
+		}
+	}
+
+	return
+}
+
+// Matrice du noeud : matrice explicite ou translation * rotation * échelle
+func (l *gltfLoader) nodeMatrix(index int) mgl32.Mat4 {
+
+	node := l.doc.Nodes[index]
+
+	if len(node.Matrix) == 16 {
+		var matrix mgl32.Mat4
+		copy(matrix[:], node.Matrix)
+		return matrix
+	}
+
+	matrix := mgl32.Ident4()
+
+	if len(node.Translation) == 3 {
+		matrix = matrix.Mul4(mgl32.Translate3D(node.Translation[0], node.Translation[1], node.Translation[2]))
+	}
+
+	// Quaternion x, y, z, w
+	if len(node.Rotation) == 4 {
+		rotation := mgl32.Quat{
+			W: node.Rotation[3],
+			V: mgl32.Vec3{node.Rotation[0], node.Rotation[1], node.Rotation[2]},
+		}
+		matrix = matrix.Mul4(rotation.Normalize().Mat4())
+	}
+
+	if len(node.Scale) == 3 {
+		matrix = matrix.Mul4(mgl32.Scale3D(node.Scale[0], node.Scale[1], node.Scale[2]))
+	}
+
+	return matrix
+}
+
+func (l *gltfLoader) node(index int, parent mgl32.Mat4, depth int) (err error) {
+
+	if index < 0 || index >= len(l.doc.Nodes) {
+		return fmt.Errorf("unknown node %d", index)
+	}
+
+	// Un noeud ne peut pas être son propre ancêtre
+	if depth > len(l.doc.Nodes) {
+		return fmt.Errorf("nodes[%d]: cycle in the node hierarchy", index)
+	}
+
+	matrix := parent.Mul4(l.nodeMatrix(index))
+	node := l.doc.Nodes[index]
+
+	if node.Mesh != nil {
+		if err = l.mesh(*node.Mesh, matrix); err != nil {
+			return fmt.Errorf("nodes[%d]: %s", index, err)
+		}
+	}
+
+	for _, child := range node.Children {
+		if err = l.node(child, matrix, depth+1); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Primitives du maillage placées dans le repère de la scène
+func (l *gltfLoader) mesh(index int, matrix mgl32.Mat4) (err error) {
+
+	if index < 0 || index >= len(l.doc.Meshes) {
+		return fmt.Errorf("unknown mesh %d", index)
+	}
+
+	// Les normales suivent l'inverse de la transposée
+	normalMatrix := matrix.Mat3().Inv().Transpose()
+
+	for idx, p := range l.doc.Meshes[index].Primitives {
+
+		if p.Mode != nil && *p.Mode != GLTF_TRIANGLES {
+			return fmt.Errorf("meshes[%d].primitives[%d]: unsupported mode %d (expect triangles)", index, idx, *p.Mode)
+		}
+
+		primitive, err := l.primitive(p.Attributes, p.Indices, p.Material, matrix, normalMatrix)
+		if err != nil {
+			return fmt.Errorf("meshes[%d].primitives[%d]: %s", index, idx, err)
+		}
+
+		l.model.Primitives = append(l.model.Primitives, primitive)
+	}
+
+	return
+}
+
+func (l *gltfLoader) primitive(attributes map[string]int, indices *int, material *int,
+	matrix mgl32.Mat4, normalMatrix mgl32.Mat3) (primitive *Primitive, err error) {
+
+	position, ok := attributes["POSITION"]
+	if ok == false {
+		return nil, errors.New("missing POSITION attribute")
+	}
+
+	positions, err := l.accessor(position, 3)
+	if err != nil {
+		return
+	}
+
+	count := len(positions) / 3
+
+	primitive = &Primitive{
+		Vertices: make([]Vertex, count),
+		Material: DEFAULT_MATERIAL,
+	}
+
+	for idx := range primitive.Vertices {
+		position := mgl32.Vec3{positions[idx*3], positions[idx*3+1], positions[idx*3+2]}
+		primitive.Vertices[idx].Position = matrix.Mul4x1(position.Vec4(1)).Vec3()
+	}
+
+	if attribute, ok := attributes["TEXCOORD_0"]; ok {
+
+		textureCoords, err := l.accessor(attribute, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(textureCoords) != count*2 {
+			return nil, errors.New("TEXCOORD_0 and POSITION counts differ")
+		}
+
+		for idx := range primitive.Vertices {
+			primitive.Vertices[idx].TextureCoord = mgl32.Vec2{textureCoords[idx*2], textureCoords[idx*2+1]}
+		}
+	}
+
+	if attribute, ok := attributes["NORMAL"]; ok {
+
+		normals, err := l.accessor(attribute, 3)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(normals) != count*3 {
+			return nil, errors.New("NORMAL and POSITION counts differ")
+		}
+
+		for idx := range primitive.Vertices {
+			normal := mgl32.Vec3{normals[idx*3], normals[idx*3+1], normals[idx*3+2]}
+			if normal = normalMatrix.Mul3x1(normal); normal.Len() > 0 {
+				normal = normal.Normalize()
+			}
+			primitive.Vertices[idx].Normal = normal
+		}
+	}
+
+	// Tangentes du fichier, le sens de la bitangente (w) étant conservé
+	if attribute, ok := attributes["TANGENT"]; ok {
+
+		tangents, err := l.accessor(attribute, 4)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tangents) != count*4 {
+			return nil, errors.New("TANGENT and POSITION counts differ")
+		}
+
+		tangentMatrix := matrix.Mat3()
+
+		for idx := range primitive.Vertices {
+			tangent := mgl32.Vec3{tangents[idx*4], tangents[idx*4+1], tangents[idx*4+2]}
+			if tangent = tangentMatrix.Mul3x1(tangent); tangent.Len() > 0 {
+				tangent = tangent.Normalize()
+			}
+			primitive.Vertices[idx].Tangent = tangent.Vec4(tangents[idx*4+3])
+		}
+	}
+
+	if indices != nil {
+		if primitive.Indices, err = l.indices(*indices); err != nil {
+			return
+		}
+
+		for _, index := range primitive.Indices {
+			if int(index) >= count {
+				return nil, fmt.Errorf("index %d out of range", index)
+			}
+		}
+	} else {
+		primitive.Indices = make([]uint32, count)
+		for idx := range primitive.Indices {
+			primitive.Indices[idx] = uint32(idx)
+		}
+	}
+
+	if material != nil {
+		if *material < 0 || *material >= len(l.materials) {
+			return nil, fmt.Errorf("unknown material %d", *material)
+		}
+		primitive.Material = l.materials[*material]
+	}
+
+	return
+}
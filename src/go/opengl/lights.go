@@ -0,0 +1,78 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Nombre de lumières déclarées par les shaders
+const MAX_LIGHTS = 4
+
+const (
+	// Lumière à l'infini éclairant dans une direction, comme le soleil
+	DIRECTIONAL = "directional"
+
+	// Lumière ponctuelle, atténuée avec le carré de la distance
+	POINT = "point"
+)
+
+type Light struct {
+	Type      string
+	Direction mgl32.Vec3
+	Position  mgl32.Vec3
+	Color     mgl32.Vec3
+	Intensity float32
+}
+
+type Lighting struct {
+	// Lumière ambiante, éclairant aussi les faces à l'ombre
+	Ambient mgl32.Vec3
+	Lights  []Light
+}
+
+// Lumière principale en haut à gauche devant l'instrument, complétée par
+// une lumière plus faible venant de derrière
+var DEFAULT_LIGHTING = Lighting{
+	Ambient: mgl32.Vec3{0.15, 0.15, 0.15},
+	Lights: []Light{
+		{
+			Type:      DIRECTIONAL,
+			Direction: mgl32.Vec3{0.4, -1, -0.6},
+			Color:     mgl32.Vec3{1, 0.96, 0.9},
+			Intensity: 3,
+		},
+		{
+			Type:      DIRECTIONAL,
+			Direction: mgl32.Vec3{-0.3, -0.2, 1},
+			Color:     mgl32.Vec3{0.8, 0.85, 1},
+			Intensity: 1,
+		},
+	},
+}
+
+func (l *Lighting) check() error {
+
+	if len(l.Lights) > MAX_LIGHTS {
+		return fmt.Errorf("%d lights, expect at most %d", len(l.Lights), MAX_LIGHTS)
+	}
+
+	for idx, light := range l.Lights {
+
+		switch light.Type {
+		case DIRECTIONAL:
+			if light.Direction.LenSqr() == 0 {
+				return fmt.Errorf("light %d: missing direction", idx)
+			}
+		case POINT:
+		default:
+			return fmt.Errorf("light %d: unknown type '%s' (expect %s or %s)",
+				idx, light.Type, DIRECTIONAL, POINT)
+		}
+
+		if light.Intensity < 0 {
+			return fmt.Errorf("light %d: negative intensity", idx)
+		}
+	}
+
+	return nil
+}
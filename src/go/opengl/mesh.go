@@ -9,11 +9,22 @@ import (
 type Vertex struct {
 	Position     mgl32.Vec3
 	TextureCoord mgl32.Vec2
+	Normal       mgl32.Vec3
+
+	// Tangente & sens de la bitangente (w)
+	Tangent mgl32.Vec4
+
+	// Couleur des lignes & rubans, ignorée par les modèles
+	Color mgl32.Vec4
 }
 
 const (
 	POSITION_VB = iota
 	TEXTURECOORD_VB
+	NORMAL_VB
+	TANGENT_VB
+	COLOR_VB
+	INDEX_VB
 	NUM_BUFFERS
 )
 
@@ -21,85 +32,151 @@ type Mesh struct {
 	vertexArrayObject  uint32
 	vertexArrayBuffers []uint32
 	drawCount          int32
+	indexed            bool
+
+	// Primitive dessinée (gl.TRIANGLES, gl.LINE_STRIP, gl.TRIANGLE_STRIP...)
+	mode uint32
+
+	// gl.STATIC_DRAW, ou gl.DYNAMIC_DRAW pour les sommets modifiés à chaque
+	// image
+	usage uint32
 }
 
 func CreateMesh(vertices []Vertex) *Mesh {
-	numVertices := len(vertices)
+	return CreateIndexedMesh(vertices, nil)
+}
+
+// Les sommets partagés par plusieurs triangles ne sont transférés qu'une
+// fois, les triangles étant décrits par les indices de leurs sommets. Les
+// normales & tangentes absentes sont calculées.
+func CreateIndexedMesh(vertices []Vertex, indices []uint32) *Mesh {
+
+	vertices = append([]Vertex(nil), vertices...)
+	computeNormals(vertices, indices)
+	computeTangents(vertices, indices)
+
+	m := createMesh(gl.TRIANGLES, gl.STATIC_DRAW)
+	m.upload(vertices, indices)
+
+	return m
+}
+
+// Maillage dont les sommets sont remplacés par Update, par exemple une
+// trajectoire dessinée en lignes ou en ruban
+func CreateDynamicMesh(mode uint32) *Mesh {
+	return createMesh(mode, gl.DYNAMIC_DRAW)
+}
+
+func createMesh(mode uint32, usage uint32) *Mesh {
 
 	m := &Mesh{
-		drawCount: int32(numVertices),
+		mode:  mode,
+		usage: usage,
 	}
 
 	// Alloue le tableau de vertices
 	gl.GenVertexArrays(1, &m.vertexArrayObject)
 	gl.BindVertexArray(m.vertexArrayObject)
 
-	// Récupération des positions & des coordonnées des textures
-	positions := make([]mgl32.Vec3, numVertices)
-	textureCoords := make([]mgl32.Vec2, numVertices)
-
-	for i := 0; i < numVertices; i++ {
-		positions[i] = vertices[i].Position
-		textureCoords[i] = vertices[i].TextureCoord
-	}
-
 	m.vertexArrayBuffers = make([]uint32, NUM_BUFFERS)
 
 	// Création des buffers
 	gl.GenBuffers(NUM_BUFFERS, &m.vertexArrayBuffers[POSITION_VB])
 
-	// **********************
-	// Gestion de la position
+	// Chaque attribut est lu dans son buffer, à l'emplacement de même indice
+	for location, size := range []int32{3, 2, 3, 4, 4} {
 
-	// Alloue les buffers
-	gl.BindBuffer(gl.ARRAY_BUFFER, m.vertexArrayBuffers[POSITION_VB])
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.vertexArrayBuffers[location])
 
-	// Récupère la taille du vertex
-	vertexSize := int(unsafe.Sizeof(positions[0]))
+		// Active la lecture des attributs
+		gl.EnableVertexAttribArray(uint32(location))
 
-	// Transfère les données dans les buffers
-	gl.BufferData(gl.ARRAY_BUFFER, numVertices*vertexSize,
-		gl.Ptr(positions), gl.STATIC_DRAW)
+		// Lis les attributs
+		gl.VertexAttribPointer(uint32(location), size, gl.FLOAT, false, 0, nil)
+	}
 
-	// Active la lecture des attributs
-	gl.EnableVertexAttribArray(0)
+	// Le buffer d'indices est mémorisé par le tableau de vertices
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.vertexArrayBuffers[INDEX_VB])
 
-	// Lis les attributs
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, nil)
+	gl.BindVertexArray(0)
 
-	// *********************
-	// Gestion de la texture
+	return m
+}
 
-	// Alloue les buffers
-	gl.BindBuffer(gl.ARRAY_BUFFER, m.vertexArrayBuffers[TEXTURECOORD_VB])
+// Remplace les sommets d'un maillage dynamique, les indices pouvant être nil
+func (m *Mesh) Update(vertices []Vertex, indices []uint32) {
+	m.upload(vertices, indices)
+}
 
-	// Récupère la taille du vertex
-	vertexSize = int(unsafe.Sizeof(textureCoords[0]))
+func (m *Mesh) upload(vertices []Vertex, indices []uint32) {
+	numVertices := len(vertices)
 
-	// Transfère les données dans les buffers
-	gl.BufferData(gl.ARRAY_BUFFER, numVertices*vertexSize,
-		gl.Ptr(textureCoords), gl.STATIC_DRAW)
+	m.drawCount = int32(numVertices)
+	m.indexed = len(indices) > 0
 
-	// Active la lecture des attributs
-	gl.EnableVertexAttribArray(1)
+	if numVertices == 0 {
+		return
+	}
 
-	// Lis les attributs
-	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 0, nil)
+	// Récupération des positions, des coordonnées des textures, des
+	// normales, des tangentes & des couleurs
+	positions := make([]mgl32.Vec3, numVertices)
+	textureCoords := make([]mgl32.Vec2, numVertices)
+	normals := make([]mgl32.Vec3, numVertices)
+	tangents := make([]mgl32.Vec4, numVertices)
+	colors := make([]mgl32.Vec4, numVertices)
+
+	for i := 0; i < numVertices; i++ {
+		positions[i] = vertices[i].Position
+		textureCoords[i] = vertices[i].TextureCoord
+		normals[i] = vertices[i].Normal
+		tangents[i] = vertices[i].Tangent
+		colors[i] = vertices[i].Color
+	}
+
+	gl.BindVertexArray(m.vertexArrayObject)
+
+	// Transfère les données dans les buffers, les buffers d'un maillage
+	// dynamique étant réalloués pour ne pas attendre la fin de l'image
+	// précédente
+	m.bufferData(gl.ARRAY_BUFFER, POSITION_VB, numVertices*int(unsafe.Sizeof(positions[0])), gl.Ptr(positions))
+	m.bufferData(gl.ARRAY_BUFFER, TEXTURECOORD_VB, numVertices*int(unsafe.Sizeof(textureCoords[0])), gl.Ptr(textureCoords))
+	m.bufferData(gl.ARRAY_BUFFER, NORMAL_VB, numVertices*int(unsafe.Sizeof(normals[0])), gl.Ptr(normals))
+	m.bufferData(gl.ARRAY_BUFFER, TANGENT_VB, numVertices*int(unsafe.Sizeof(tangents[0])), gl.Ptr(tangents))
+	m.bufferData(gl.ARRAY_BUFFER, COLOR_VB, numVertices*int(unsafe.Sizeof(colors[0])), gl.Ptr(colors))
+
+	if m.indexed {
+		m.drawCount = int32(len(indices))
+		m.bufferData(gl.ELEMENT_ARRAY_BUFFER, INDEX_VB, len(indices)*int(unsafe.Sizeof(indices[0])), gl.Ptr(indices))
+	}
 
 	gl.BindVertexArray(0)
+}
 
-	return m
+func (m *Mesh) bufferData(target uint32, buffer int, size int, data unsafe.Pointer) {
+	gl.BindBuffer(target, m.vertexArrayBuffers[buffer])
+	gl.BufferData(target, size, data, m.usage)
 }
 
 func (m *Mesh) Destroy() {
+	gl.DeleteBuffers(NUM_BUFFERS, &m.vertexArrayBuffers[POSITION_VB])
 	gl.DeleteVertexArrays(1, &m.vertexArrayObject)
 }
 
 func (m *Mesh) Draw() {
+
+	if m.drawCount == 0 {
+		return
+	}
+
 	gl.BindVertexArray(m.vertexArrayObject)
 
 	// Paramètres pour afficher l'object
-	gl.DrawArrays(gl.TRIANGLES, 0, m.drawCount)
+	if m.indexed {
+		gl.DrawElements(m.mode, m.drawCount, gl.UNSIGNED_INT, nil)
+	} else {
+		gl.DrawArrays(m.mode, 0, m.drawCount)
+	}
 
 	gl.BindVertexArray(0)
 }
@@ -0,0 +1,169 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"strings"
+)
+
+// Modèle 3D chargé depuis un fichier, avant son transfert vers OpenGL
+type Model struct {
+	Primitives []*Primitive
+
+	// Point du modèle autour duquel il est tourné & placé
+	Pivot mgl32.Vec3
+
+	// Boîte englobante
+	Min mgl32.Vec3
+	Max mgl32.Vec3
+}
+
+// Triangles partageant un même matériau
+type Primitive struct {
+	Vertices []Vertex
+	Indices  []uint32
+	Material *Material
+}
+
+type Material struct {
+	Name string
+
+	// Couleur diffuse, multipliée par l'image si elle est présente
+	Color mgl32.Vec4
+	Image image.Image
+
+	// Modèle métal/rugosité : 0 pour un diélectrique comme le bois verni,
+	// 1 pour un métal. Une rugosité faible donne des reflets concentrés.
+	Metallic  float32
+	Roughness float32
+
+	// Normales dans le repère tangent, facultatives
+	NormalImage image.Image
+}
+
+const DEFAULT_ROUGHNESS = 0.6
+
+var DEFAULT_MATERIAL = &Material{
+	Name:      "default",
+	Color:     mgl32.Vec4{0.8, 0.8, 0.8, 1},
+	Roughness: DEFAULT_ROUGHNESS,
+}
+
+// Charge un modèle Wavefront (.obj) ou glTF 2.0 (.gltf, .glb)
+func LoadModel(filename string) (m *Model, err error) {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".obj":
+		m, err = loadObj(filename)
+	case ".gltf", ".glb":
+		m, err = loadGltf(filename)
+	default:
+		return nil, fmt.Errorf("%s: unsupported model format (expect .obj, .gltf or .glb)", filename)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	if len(m.Primitives) == 0 {
+		return nil, fmt.Errorf("%s: no triangles", filename)
+	}
+
+	m.bounds()
+
+	return
+}
+
+func (m *Model) bounds() {
+
+	first := true
+
+	for _, primitive := range m.Primitives {
+		for _, vertex := range primitive.Vertices {
+
+			if first {
+				m.Min, m.Max = vertex.Position, vertex.Position
+				first = false
+				continue
+			}
+
+			for i := 0; i < 3; i++ {
+				if vertex.Position[i] < m.Min[i] {
+					m.Min[i] = vertex.Position[i]
+				}
+				if vertex.Position[i] > m.Max[i] {
+					m.Max[i] = vertex.Position[i]
+				}
+			}
+		}
+	}
+}
+
+// Centre de la boîte englobante
+func (m *Model) Center() mgl32.Vec3 {
+	return m.Min.Add(m.Max).Mul(0.5)
+}
+
+// Plus grande dimension de la boîte englobante
+func (m *Model) Size() (size float32) {
+
+	dimensions := m.Max.Sub(m.Min)
+	for i := 0; i < 3; i++ {
+		if dimensions[i] > size {
+			size = dimensions[i]
+		}
+	}
+
+	return
+}
+
+// Image transférée vers OpenGL : l'image du matériau teintée par sa couleur,
+// ou un pixel de cette couleur
+func (m *Material) texture() image.Image {
+
+	tint := color.NRGBA{
+		R: colorByte(m.Color[0]),
+		G: colorByte(m.Color[1]),
+		B: colorByte(m.Color[2]),
+		A: colorByte(m.Color[3]),
+	}
+
+	if m.Image == nil {
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.SetNRGBA(0, 0, tint)
+		return img
+	}
+
+	if tint == (color.NRGBA{255, 255, 255, 255}) {
+		return m.Image
+	}
+
+	img := image.NewNRGBA(m.Image.Bounds())
+	draw.Draw(img, img.Bounds(), m.Image, m.Image.Bounds().Min, draw.Src)
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(uint16(img.Pix[i]) * uint16(tint.R) / 255)
+		img.Pix[i+1] = uint8(uint16(img.Pix[i+1]) * uint16(tint.G) / 255)
+		img.Pix[i+2] = uint8(uint16(img.Pix[i+2]) * uint16(tint.B) / 255)
+		img.Pix[i+3] = uint8(uint16(img.Pix[i+3]) * uint16(tint.A) / 255)
+	}
+
+	return img
+}
+
+func colorByte(value float32) uint8 {
+
+	if value <= 0 {
+		return 0
+	}
+
+	if value >= 1 {
+		return 255
+	}
+
+	return uint8(value*255 + 0.5)
+}
@@ -0,0 +1,98 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Repère nommé du graphe de la scène (épaule, main...), portant
+// éventuellement un objet
+type Node struct {
+	name      string
+	transform *Transform
+
+	// nil pour un simple repère, qui n'est pas affiché
+	object *Object
+}
+
+// Repère placé par rapport à son parent
+type NodeOptions struct {
+	Name string
+
+	// Nom du repère parent, vide pour la scène
+	Parent string
+
+	Position [3]float32
+
+	// Orientation initiale en degrés autour des axes X, Y puis Z du parent
+	Rotation [3]float32
+}
+
+func (n *Node) GetName() string {
+	return n.name
+}
+
+func (n *Node) GetTransform() *Transform {
+	return n.transform
+}
+
+// Objet affiché, nil pour un simple repère
+func (n *Node) GetObject() *Object {
+	return n.object
+}
+
+// Repère nommé, nil s'il n'existe pas
+func (s *Scene) Node(name string) *Node {
+
+	for _, node := range s.nodes {
+		if node.name == name {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// Repère sans objet auquel d'autres repères peuvent être attachés, le parent
+// devant avoir été ajouté avant
+func (s *Scene) AddNode(options NodeOptions) (node *Node, err error) {
+	return s.addNode(options, SetCenter(), nil)
+}
+
+func (s *Scene) addNode(options NodeOptions, transform *Transform, object *Object) (node *Node, err error) {
+
+	if options.Name != "" && s.Node(options.Name) != nil {
+		return nil, fmt.Errorf("node '%s' already exists", options.Name)
+	}
+
+	if options.Parent != "" {
+		parent := s.Node(options.Parent)
+		if parent == nil {
+			return nil, fmt.Errorf("node '%s': unknown parent '%s'", options.Name, options.Parent)
+		}
+
+		if err = transform.SetParent(parent.transform); err != nil {
+			return nil, fmt.Errorf("node '%s': %s", options.Name, err)
+		}
+	}
+
+	transform.SetPosition(options.Position[0], options.Position[1], options.Position[2])
+
+	rotation := eulerRotation(
+		mgl32.DegToRad(options.Rotation[0]),
+		mgl32.DegToRad(options.Rotation[1]),
+		mgl32.DegToRad(options.Rotation[2]))
+	transform.SetRotate(rotation.W, rotation.V.X(), rotation.V.Y(), rotation.V.Z())
+
+	transform.SetInitial()
+
+	node = &Node{
+		name:      options.Name,
+		transform: transform,
+		object:    object,
+	}
+
+	s.nodes = append(s.nodes, node)
+
+	return
+}
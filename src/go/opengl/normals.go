@@ -0,0 +1,140 @@
+package opengl
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Normales des sommets qui n'en ont pas : moyenne des normales des
+// triangles qui les partagent, pondérée par leur aire
+func computeNormals(vertices []Vertex, indices []uint32) {
+
+	missing := false
+	for _, vertex := range vertices {
+		if vertex.Normal.LenSqr() == 0 {
+			missing = true
+			break
+		}
+	}
+
+	if missing == false {
+		return
+	}
+
+	normals := make([]mgl32.Vec3, len(vertices))
+
+	forTriangles(vertices, indices, func(a, b, c uint32) {
+
+		// Le produit vectoriel a pour norme le double de l'aire
+		normal := vertices[b].Position.Sub(vertices[a].Position).Cross(
+			vertices[c].Position.Sub(vertices[a].Position))
+
+		normals[a] = normals[a].Add(normal)
+		normals[b] = normals[b].Add(normal)
+		normals[c] = normals[c].Add(normal)
+	})
+
+	for idx := range vertices {
+		if vertices[idx].Normal.LenSqr() == 0 && normals[idx].LenSqr() > 0 {
+			vertices[idx].Normal = normals[idx].Normalize()
+		}
+	}
+}
+
+// Tangentes orientées selon la coordonnée de texture u, utilisées par les
+// textures de normales. La composante w indique le sens de la bitangente.
+func computeTangents(vertices []Vertex, indices []uint32) {
+
+	missing := false
+	for _, vertex := range vertices {
+		if vertex.Tangent.Vec3().LenSqr() == 0 {
+			missing = true
+			break
+		}
+	}
+
+	if missing == false {
+		return
+	}
+
+	tangents := make([]mgl32.Vec3, len(vertices))
+	bitangents := make([]mgl32.Vec3, len(vertices))
+
+	forTriangles(vertices, indices, func(a, b, c uint32) {
+
+		edge1 := vertices[b].Position.Sub(vertices[a].Position)
+		edge2 := vertices[c].Position.Sub(vertices[a].Position)
+		delta1 := vertices[b].TextureCoord.Sub(vertices[a].TextureCoord)
+		delta2 := vertices[c].TextureCoord.Sub(vertices[a].TextureCoord)
+
+		determinant := delta1.X()*delta2.Y() - delta2.X()*delta1.Y()
+		if determinant == 0 {
+			return
+		}
+
+		r := 1 / determinant
+		tangent := edge1.Mul(delta2.Y()).Sub(edge2.Mul(delta1.Y())).Mul(r)
+		bitangent := edge2.Mul(delta1.X()).Sub(edge1.Mul(delta2.X())).Mul(r)
+
+		for _, idx := range []uint32{a, b, c} {
+			tangents[idx] = tangents[idx].Add(tangent)
+			bitangents[idx] = bitangents[idx].Add(bitangent)
+		}
+	})
+
+	for idx := range vertices {
+
+		if vertices[idx].Tangent.Vec3().LenSqr() > 0 {
+			continue
+		}
+
+		normal := vertices[idx].Normal
+
+		// Orthogonalisation par rapport à la normale
+		tangent := tangents[idx].Sub(normal.Mul(normal.Dot(tangents[idx])))
+
+		// Sans coordonnées de texture exploitables, une tangente quelconque
+		if tangent.LenSqr() == 0 {
+			tangent = perpendicular(normal)
+		}
+
+		if tangent.LenSqr() == 0 {
+			continue
+		}
+
+		var w float32 = 1
+		if normal.Cross(tangent).Dot(bitangents[idx]) < 0 {
+			w = -1
+		}
+
+		vertices[idx].Tangent = tangent.Normalize().Vec4(w)
+	}
+}
+
+// Vecteur perpendiculaire, nul pour un vecteur nul
+func perpendicular(v mgl32.Vec3) mgl32.Vec3 {
+
+	axis := mgl32.Vec3{1, 0, 0}
+	if v.X()*v.X() > 0.5*v.LenSqr() {
+		axis = mgl32.Vec3{0, 1, 0}
+	}
+
+	return v.Cross(axis)
+}
+
+// Triangles décrits par les indices, à défaut par les sommets successifs
+func forTriangles(vertices []Vertex, indices []uint32, f func(a, b, c uint32)) {
+
+	if len(indices) == 0 {
+		for idx := 0; idx+2 < len(vertices); idx += 3 {
+			f(uint32(idx), uint32(idx+1), uint32(idx+2))
+		}
+		return
+	}
+
+	for idx := 0; idx+2 < len(indices); idx += 3 {
+		a, b, c := indices[idx], indices[idx+1], indices[idx+2]
+		if int(a) < len(vertices) && int(b) < len(vertices) && int(c) < len(vertices) {
+			f(a, b, c)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package opengl
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sommet d'une face : indices de la position, de la coordonnée de texture &
+// de la normale, -1 si absents
+type objIndex [3]int
+
+type objLoader struct {
+	dir string
+
+	positions     []mgl32.Vec3
+	textureCoords []mgl32.Vec2
+	normals       []mgl32.Vec3
+
+	materials  map[string]*Material
+	primitives map[*Material]*Primitive
+	vertices   map[*Material]map[objIndex]uint32
+
+	model    *Model
+	material *Material
+}
+
+// Charge un fichier Wavefront .obj & les matériaux des fichiers .mtl
+// associés. Le format ne définissant pas de pivot, celui-ci est le centre
+// du modèle.
+func loadObj(filename string) (m *Model, err error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	l := &objLoader{
+		dir:        filepath.Dir(filename),
+		materials:  make(map[string]*Material),
+		primitives: make(map[*Material]*Primitive),
+		vertices:   make(map[*Material]map[objIndex]uint32),
+		model:      new(Model),
+		material:   DEFAULT_MATERIAL,
+	}
+
+	err = readLines(file, func(keyword string, args []string) (err error) {
+
+		switch keyword {
+		case "v":
+			var position mgl32.Vec3
+			if position, err = parseVec3(args); err == nil {
+				l.positions = append(l.positions, position)
+			}
+
+		case "vt":
+			if len(args) < 1 {
+				return fmt.Errorf("missing texture coordinates")
+			}
+
+			var textureCoord mgl32.Vec2
+			for i := 0; i < len(args) && i < 2; i++ {
+				if textureCoord[i], err = parseFloat(args[i]); err != nil {
+					return
+				}
+			}
+
+			// L'origine des coordonnées est en bas de l'image
+			textureCoord[1] = 1 - textureCoord[1]
+			l.textureCoords = append(l.textureCoords, textureCoord)
+
+		case "vn":
+			var normal mgl32.Vec3
+			if normal, err = parseVec3(args); err == nil {
+				l.normals = append(l.normals, normal)
+			}
+
+		case "f":
+			err = l.face(args)
+
+		case "mtllib":
+			for _, name := range args {
+				if err = l.loadMtl(filepath.Join(l.dir, name)); err != nil {
+					return
+				}
+			}
+
+		case "usemtl":
+			if len(args) < 1 {
+				return fmt.Errorf("missing material name")
+			}
+
+			material, ok := l.materials[args[0]]
+			if ok == false {
+				return fmt.Errorf("unknown material '%s'", args[0])
+			}
+
+			l.material = material
+		}
+
+		// Groupes, objets, lissage & courbes ignorés
+		return
+	})
+
+	if err != nil {
+		return
+	}
+
+	m = l.model
+	m.bounds()
+	m.Pivot = m.Center()
+
+	return
+}
+
+// Les polygones sont découpés en triangles autour de leur premier sommet
+func (l *objLoader) face(args []string) (err error) {
+
+	if len(args) < 3 {
+		return fmt.Errorf("face with %d vertices", len(args))
+	}
+
+	indices := make([]uint32, len(args))
+	for idx, arg := range args {
+		if indices[idx], err = l.vertex(arg); err != nil {
+			return
+		}
+	}
+
+	primitive := l.primitive()
+
+	for idx := 1; idx+1 < len(indices); idx++ {
+		primitive.Indices = append(primitive.Indices,
+			indices[0], indices[idx], indices[idx+1])
+	}
+
+	return
+}
+
+func (l *objLoader) primitive() *Primitive {
+
+	primitive, ok := l.primitives[l.material]
+	if ok == false {
+		primitive = &Primitive{
+			Material: l.material,
+		}
+
+		l.primitives[l.material] = primitive
+		l.vertices[l.material] = make(map[objIndex]uint32)
+		l.model.Primitives = append(l.model.Primitives, primitive)
+	}
+
+	return primitive
+}
+
+// Sommet "v", "v/vt", "v//vn" ou "v/vt/vn", ajouté à la primitive du
+// matériau courant s'il n'y est pas encore
+func (l *objLoader) vertex(arg string) (index uint32, err error) {
+
+	key := objIndex{-1, -1, -1}
+	counts := []int{len(l.positions), len(l.textureCoords), len(l.normals)}
+
+	for idx, value := range strings.Split(arg, "/") {
+
+		if idx > 2 {
+			return 0, fmt.Errorf("invalid face vertex '%s'", arg)
+		}
+
+		if value == "" && idx > 0 {
+			continue
+		}
+
+		if key[idx], err = objReference(value, counts[idx]); err != nil {
+			return
+		}
+	}
+
+	primitive := l.primitive()
+	vertices := l.vertices[l.material]
+
+	if index, ok := vertices[key]; ok {
+		return index, nil
+	}
+
+	vertex := Vertex{
+		Position: l.positions[key[0]],
+	}
+
+	if key[1] >= 0 {
+		vertex.TextureCoord = l.textureCoords[key[1]]
+	}
+
+	if key[2] >= 0 {
+		vertex.Normal = l.normals[key[2]]
+	}
+
+	index = uint32(len(primitive.Vertices))
+	primitive.Vertices = append(primitive.Vertices, vertex)
+	vertices[key] = index
+
+	return
+}
+
+// Les références commencent à 1, les valeurs négatives partant du dernier
+// élément défini
+func objReference(value string, count int) (index int, err error) {
+
+	index, err = strconv.Atoi(value)
+	if err != nil {
+		return -1, fmt.Errorf("invalid reference '%s'", value)
+	}
+
+	if index < 0 {
+		index += count
+	} else {
+		index--
+	}
+
+	if index < 0 || index >= count {
+		return -1, fmt.Errorf("reference %s out of range", value)
+	}
+
+	return
+}
+
+// Matériaux : couleur diffuse, transparence, rugosité, image diffuse & image
+// de normales
+func (l *objLoader) loadMtl(filename string) (err error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(filename)
+
+	var material *Material
+
+	err = readLines(file, func(keyword string, args []string) (err error) {
+
+		if keyword == "newmtl" {
+			if len(args) < 1 {
+				return fmt.Errorf("missing material name")
+			}
+
+			material = &Material{
+				Name:      args[0],
+				Color:     mgl32.Vec4{1, 1, 1, 1},
+				Roughness: DEFAULT_ROUGHNESS,
+			}
+
+			l.materials[material.Name] = material
+			return
+		}
+
+		if material == nil {
+			return
+		}
+
+		switch keyword {
+		case "Kd":
+			var diffuse mgl32.Vec3
+			if diffuse, err = parseVec3(args); err == nil {
+				material.Color = diffuse.Vec4(material.Color[3])
+			}
+
+		case "d":
+			if len(args) > 0 {
+				material.Color[3], err = parseFloat(args[0])
+			}
+
+		case "Tr":
+			if len(args) > 0 {
+				var transparency float32
+				transparency, err = parseFloat(args[0])
+				material.Color[3] = 1 - transparency
+			}
+
+		case "Ns":
+			// Exposant de Phong converti en rugosité
+			if len(args) > 0 {
+				var exponent float32
+				if exponent, err = parseFloat(args[0]); err == nil && exponent >= 0 {
+					material.Roughness = float32(math.Sqrt(2 / float64(exponent+2)))
+				}
+			}
+
+		case "Pr":
+			if len(args) > 0 {
+				material.Roughness, err = parseFloat(args[0])
+			}
+
+		case "Pm":
+			if len(args) > 0 {
+				material.Metallic, err = parseFloat(args[0])
+			}
+
+		case "map_Kd":
+			// Les options éventuelles précèdent le nom du fichier
+			if len(args) < 1 {
+				return fmt.Errorf("missing diffuse map")
+			}
+
+			material.Image, err = loadImage(filepath.Join(dir, args[len(args)-1]))
+
+		case "norm", "map_Bump", "bump":
+			if len(args) < 1 {
+				return fmt.Errorf("missing normal map")
+			}
+
+			material.NormalImage, err = loadImage(filepath.Join(dir, args[len(args)-1]))
+		}
+
+		return
+	})
+
+	if err != nil {
+		err = fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return
+}
+
+// Lignes "mot-clé arguments...", les commentaires & lignes vides étant
+// ignorés. Les erreurs indiquent le numéro de ligne.
+func readLines(r io.Reader, handle func(keyword string, args []string) error) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := scanner.Text()
+		if idx := strings.IndexByte(text, '#'); idx >= 0 {
+			text = text[:idx]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := handle(fields[0], fields[1:]); err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseVec3(args []string) (v mgl32.Vec3, err error) {
+
+	if len(args) < 3 {
+		return v, fmt.Errorf("expect 3 values, got %d", len(args))
+	}
+
+	for i := 0; i < 3; i++ {
+		if v[i], err = parseFloat(args[i]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func parseFloat(arg string) (float32, error) {
+
+	value, err := strconv.ParseFloat(arg, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", arg)
+	}
+
+	return float32(value), nil
+}
@@ -1,41 +1,146 @@
 package opengl
 
+import (
+	"fmt"
+)
+
 type Object struct {
+	name      string
 	transform *Transform
-	mesh      *Mesh
 	shader    *Shader
-	texture   *Texture
+	parts     []*part
 }
 
-func CreateObject(vertices []Vertex, shaderUrl string, textureUrl string) *Object {
+// Maillage & textures de l'un des matériaux de l'objet, la texture de
+// normales étant facultative
+type part struct {
+	mesh     *Mesh
+	material *Material
+	texture  *Texture
+	normals  *Texture
+}
 
-	mesh := CreateMesh(vertices)
+func CreateObject(vertices []Vertex, shaderUrl string, textureUrl string) (o *Object, err error) {
 
 	shader, err := CreateShader(shaderUrl)
 	if err != nil {
-		panic(err)
+		return
 	}
 
 	texture, err := CreateTexture(textureUrl)
 	if err != nil {
-		panic(err)
+		shader.Destroy()
+		return
 	}
 
-	return &Object{
-		mesh:      mesh,
+	o = &Object{
 		shader:    shader,
-		texture:   texture,
+		parts:     []*part{{mesh: CreateMesh(vertices), material: DEFAULT_MATERIAL, texture: texture}},
 		transform: SetCenter(),
 	}
+
+	return
+}
+
+// Transfère le modèle chargé vers OpenGL, l'objet tournant autour du pivot
+// du modèle
+func CreateModelObject(model *Model, shaderUrl string) (o *Object, err error) {
+
+	shader, err := CreateShader(shaderUrl)
+	if err != nil {
+		return
+	}
+
+	o = &Object{
+		shader:    shader,
+		transform: SetCenter(),
+	}
+
+	o.transform.SetPivot(model.Pivot.X(), model.Pivot.Y(), model.Pivot.Z())
+
+	// Les primitives d'un même matériau partagent leurs textures
+	textures := make(map[*Material]*part)
+
+	for _, primitive := range model.Primitives {
+
+		material := primitive.Material
+
+		shared, ok := textures[material]
+		if ok == false {
+			if shared, err = createMaterialTextures(material); err != nil {
+				o.Destroy()
+				return nil, fmt.Errorf("material '%s': %s", material.Name, err)
+			}
+			textures[material] = shared
+		}
+
+		o.parts = append(o.parts, &part{
+			mesh:     CreateIndexedMesh(primitive.Vertices, primitive.Indices),
+			material: material,
+			texture:  shared.texture,
+			normals:  shared.normals,
+		})
+	}
+
+	return
+}
+
+func createMaterialTextures(material *Material) (p *part, err error) {
+
+	p = new(part)
+
+	if p.texture, err = CreateTextureFromImage(material.texture()); err != nil {
+		return
+	}
+
+	if material.NormalImage != nil {
+		if p.normals, err = CreateTextureFromImage(material.NormalImage); err != nil {
+			p.texture.Destroy()
+			return
+		}
+	}
+
+	return
+}
+
+func (o *Object) GetName() string {
+	return o.name
 }
 
 func (o *Object) GetTransform() *Transform {
 	return o.transform
 }
 
-func (o *Object) Render() {
+func (o *Object) Render(camera *Camera, lighting *Lighting) {
 	o.shader.Bind()
-	o.shader.Update(o.transform)
-	o.texture.Bind(0)
-	o.mesh.Draw()
+	o.shader.Update(o.transform, camera)
+	o.shader.UpdateLighting(lighting)
+
+	for _, part := range o.parts {
+		part.texture.Bind(DIFFUSE_UNIT)
+		if part.normals != nil {
+			part.normals.Bind(NORMAL_MAP_UNIT)
+		}
+
+		o.shader.UpdateMaterial(part.material, part.normals != nil)
+		part.mesh.Draw()
+	}
+}
+
+func (o *Object) Destroy() {
+
+	destroyed := make(map[*Texture]bool)
+
+	for _, part := range o.parts {
+		part.mesh.Destroy()
+
+		for _, texture := range []*Texture{part.texture, part.normals} {
+			if texture != nil && destroyed[texture] == false {
+				texture.Destroy()
+				destroyed[texture] = true
+			}
+		}
+	}
+
+	o.shader.Destroy()
 }
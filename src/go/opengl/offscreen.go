@@ -0,0 +1,192 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"image"
+	"log"
+	"runtime"
+	"time"
+)
+
+// Rendu de la scène dans un framebuffer, sans fenêtre ni boucle temps réel,
+// par exemple sur une machine sans écran ni GPU
+type Offscreen struct {
+	*Scene
+
+	context *headlessContext
+
+	// Rendu anticrénelé puis résolu dans un framebuffer relu par le
+	// processeur
+	multisample   uint32
+	resolve       uint32
+	renderbuffers []uint32
+
+	image *image.RGBA
+}
+
+func CreateOffscreen(options Options) (o *Offscreen, err error) {
+
+	// Le contexte est lié au thread qui l'a créé
+	runtime.LockOSThread()
+
+	scene, err := createScene(options)
+	if err != nil {
+		return
+	}
+	options = scene.options
+
+	context, err := createHeadlessContext(options.Width, options.Height)
+	if err != nil {
+		return
+	}
+
+	if err = gl.InitWithProcAddrFunc(context.procAddress); err != nil {
+		context.destroy()
+		return nil, fmt.Errorf("Init OpenGl failed : %s", err)
+	}
+
+	log.Printf("offscreen: %s, %s (%s)", gl.GoStr(gl.GetString(gl.VERSION)),
+		gl.GoStr(gl.GetString(gl.RENDERER)), CONTEXT_API)
+
+	o = &Offscreen{
+		Scene:   scene,
+		context: context,
+		image:   image.NewRGBA(image.Rect(0, 0, options.Width, options.Height)),
+	}
+
+	if err = o.createFramebuffers(); err != nil {
+		o.Destroy()
+		return nil, err
+	}
+
+	o.initGl()
+
+	return
+}
+
+func (o *Offscreen) createFramebuffers() error {
+
+	width, height := int32(o.options.Width), int32(o.options.Height)
+
+	// Anticrénelage limité par le pilote, 0 échantillon pour le désactiver
+	var maxSamples int32
+	gl.GetIntegerv(gl.MAX_SAMPLES, &maxSamples)
+
+	samples := int32(o.options.Samples)
+	if samples > maxSamples {
+		samples = maxSamples
+	}
+
+	o.renderbuffers = make([]uint32, 3)
+	gl.GenRenderbuffers(int32(len(o.renderbuffers)), &o.renderbuffers[0])
+
+	for idx, format := range []uint32{gl.RGBA8, gl.DEPTH_COMPONENT24} {
+		gl.BindRenderbuffer(gl.RENDERBUFFER, o.renderbuffers[idx])
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, format, width, height)
+	}
+
+	gl.BindRenderbuffer(gl.RENDERBUFFER, o.renderbuffers[2])
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, width, height)
+
+	gl.GenFramebuffers(1, &o.resolve)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, o.resolve)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, o.renderbuffers[2])
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("offscreen: incomplete resolve framebuffer (0x%x)", status)
+	}
+
+	gl.GenFramebuffers(1, &o.multisample)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, o.multisample)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, o.renderbuffers[0])
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, o.renderbuffers[1])
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("offscreen: incomplete framebuffer (0x%x)", status)
+	}
+
+	return nil
+}
+
+// Rend la scène à l'instant now & retourne l'image, réutilisée par l'appel
+// suivant
+func (o *Offscreen) Frame(now time.Time) *image.RGBA {
+
+	width, height := int32(o.options.Width), int32(o.options.Height)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, o.multisample)
+	o.Render(now)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, o.multisample)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, o.resolve)
+	gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, o.resolve)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(o.image.Pix))
+
+	// La première ligne lue est celle du bas de l'image
+	stride := o.image.Stride
+	row := make([]byte, stride)
+
+	for top, bottom := 0, int(height)-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a := o.image.Pix[top*stride : (top+1)*stride]
+		b := o.image.Pix[bottom*stride : (bottom+1)*stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+
+	return o.image
+}
+
+// Rendu à pas fixe de duration, indépendant du temps réel : update place les
+// objets à l'instant de chaque image, écrite ensuite dans output
+func (o *Offscreen) Record(output *FrameOutput, fps float64, duration time.Duration,
+	update func(t time.Duration) error) (frames int, err error) {
+
+	if fps <= 0 {
+		return 0, fmt.Errorf("invalid frame rate %g", fps)
+	}
+
+	// Les images sont datées à partir d'une origine arbitraire
+	start := time.Unix(0, 0)
+
+	for {
+		// Calculé depuis le début pour ne pas accumuler d'erreur d'arrondi
+		t := time.Duration(float64(frames) * float64(time.Second) / fps)
+		if t > duration {
+			return
+		}
+
+		if err = update(t); err != nil {
+			return
+		}
+
+		if err = output.Write(o.Frame(start.Add(t))); err != nil {
+			return
+		}
+
+		frames++
+	}
+}
+
+func (o *Offscreen) Destroy() {
+
+	o.destroy()
+
+	if o.multisample != 0 {
+		gl.DeleteFramebuffers(1, &o.multisample)
+	}
+
+	if o.resolve != 0 {
+		gl.DeleteFramebuffers(1, &o.resolve)
+	}
+
+	if len(o.renderbuffers) > 0 {
+		gl.DeleteRenderbuffers(int32(len(o.renderbuffers)), &o.renderbuffers[0])
+	}
+
+	o.context.destroy()
+}
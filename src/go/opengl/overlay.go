@@ -0,0 +1,415 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"log"
+	"math"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Couleurs des éléments de l'affichage tête haute
+var (
+	HUD_TEXT       = mgl32.Vec4{0.95, 0.95, 0.95, 1}
+	HUD_LABEL      = mgl32.Vec4{0.65, 0.65, 0.7, 1}
+	HUD_BACKGROUND = mgl32.Vec4{0, 0, 0, 0.55}
+	HUD_TRACK      = mgl32.Vec4{1, 1, 1, 0.15}
+	HUD_GOOD       = mgl32.Vec4{0.3, 0.85, 0.4, 1}
+	HUD_WARNING    = mgl32.Vec4{1, 0.75, 0.2, 1}
+	HUD_ERROR      = mgl32.Vec4{1, 0.3, 0.25, 1}
+)
+
+// Segments d'un cercle complet des cadrans
+const DIAL_SEGMENTS = 48
+
+// Angle couvert par la graduation d'un cadran, ouverte vers le bas
+const DIAL_ANGLE = 1.5 * math.Pi
+
+type OverlayOptions struct {
+	// Police TrueType ou OpenType, vide pour la police bitmap intégrée
+	Font string
+	Size float64
+
+	// Préfixe des fichiers du programme, à défaut hudShader dans le
+	// répertoire du programme de la scène
+	Shader string
+}
+
+// Affichage en 2D dessiné par-dessus la scène, en pixels depuis le coin en
+// haut à gauche, agrandis sur les écrans à haute densité. Les éléments sont
+// redécrits à chaque image par la fonction de dessin.
+type Overlay struct {
+	options OverlayOptions
+	font    *Font
+	shader  *Shader
+	mesh    *Mesh
+
+	draw     func(o *Overlay, now time.Time)
+	vertices []Vertex
+
+	// Taille de la zone d'affichage de l'image en cours, en pixels divisés
+	// par l'agrandissement
+	width  float32
+	height float32
+	scale  float32
+
+	hidden int32
+
+	// Images comptées depuis le début de la seconde en cours, images par
+	// seconde de la précédente & durée de la dernière image
+	frames    int
+	second    time.Time
+	fps       int
+	previous  time.Time
+	frameTime time.Duration
+}
+
+func (s *Scene) SetOverlay(options OverlayOptions, draw func(o *Overlay, now time.Time)) (o *Overlay, err error) {
+
+	if s.overlay != nil {
+		return nil, fmt.Errorf("overlay already set")
+	}
+
+	if options.Shader == "" {
+		options.Shader = filepath.Join(filepath.Dir(s.options.Shader), "hudShader")
+	}
+
+	font, err := LoadFont(options.Font, options.Size, s.scale)
+	if err != nil {
+		return
+	}
+
+	shader, err := CreateShader(options.Shader)
+	if err != nil {
+		font.Destroy()
+		return
+	}
+
+	o = &Overlay{
+		options: options,
+		font:    font,
+		shader:  shader,
+		mesh:    CreateDynamicMesh(gl.TRIANGLES),
+		draw:    draw,
+		scale:   s.scale,
+	}
+
+	s.overlay = o
+
+	return
+}
+
+func (o *Overlay) Hidden() bool {
+	return atomic.LoadInt32(&o.hidden) == 1
+}
+
+func (o *Overlay) SetHidden(hidden bool) {
+
+	var value int32
+	if hidden {
+		value = 1
+	}
+
+	atomic.StoreInt32(&o.hidden, value)
+}
+
+// Images rendues pendant la dernière seconde
+func (o *Overlay) Fps() int {
+	return o.fps
+}
+
+// Durée entre les deux dernières images
+func (o *Overlay) FrameTime() time.Duration {
+	return o.frameTime
+}
+
+func (o *Overlay) Width() float32 {
+	return o.width
+}
+
+func (o *Overlay) Height() float32 {
+	return o.height
+}
+
+func (o *Overlay) LineHeight() float32 {
+	return o.font.LineHeight()
+}
+
+func (o *Overlay) TextWidth(format string, args ...interface{}) float32 {
+	return o.font.Width(fmt.Sprintf(format, args...))
+}
+
+func (o *Overlay) count(now time.Time) {
+
+	if o.previous.IsZero() == false {
+		o.frameTime = now.Sub(o.previous)
+	}
+	o.previous = now
+
+	// Retour en arrière (nouvelle lecture d'une session)
+	if now.Before(o.second) {
+		o.second = time.Time{}
+	}
+
+	if o.second.IsZero() {
+		o.second = now
+	}
+
+	if elapsed := now.Sub(o.second); elapsed >= time.Second {
+		o.fps = int(math.Round(float64(o.frames) / elapsed.Seconds()))
+		o.frames = 0
+		o.second = now
+	}
+
+	o.frames++
+}
+
+// La police est rendue à nouveau lorsque l'agrandissement change, par
+// exemple en passant sur un autre écran
+func (o *Overlay) setScale(scale float32) {
+
+	if scale == o.scale {
+		return
+	}
+
+	font, err := LoadFont(o.options.Font, o.options.Size, scale)
+	if err != nil {
+		log.Printf("overlay: %s", err)
+		return
+	}
+
+	o.font.Destroy()
+	o.font, o.scale = font, scale
+}
+
+// Dessine les éléments décrits par la fonction de dessin sur une zone de
+// width x height pixels agrandie scale fois
+func (o *Overlay) Render(width int, height int, scale float32, now time.Time) {
+
+	o.count(now)
+
+	if o.Hidden() {
+		return
+	}
+
+	o.setScale(scale)
+
+	o.width, o.height = float32(width)/o.scale, float32(height)/o.scale
+	o.vertices = o.vertices[:0]
+
+	o.draw(o, now)
+
+	o.mesh.Update(o.vertices, nil)
+
+	o.shader.Bind()
+	o.shader.SetMat4("projection", mgl32.Ortho2D(0, o.width, o.height, 0))
+	o.shader.SetSampler("atlas", DIFFUSE_UNIT)
+	o.font.texture.Bind(DIFFUSE_UNIT)
+
+	depthTest := gl.IsEnabled(gl.DEPTH_TEST)
+
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	o.mesh.Draw()
+
+	gl.Disable(gl.BLEND)
+	if depthTest {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+}
+
+// Quadrilatère a, b, c, d dont les coins correspondent à uv0 & uv1 de
+// l'atlas
+func (o *Overlay) quad(a, b, c, d mgl32.Vec2, uv0, uv1 mgl32.Vec2, color mgl32.Vec4) {
+
+	vertex := func(p mgl32.Vec2, u float32, v float32) Vertex {
+		return Vertex{
+			Position:     mgl32.Vec3{p[0], p[1], 0},
+			TextureCoord: mgl32.Vec2{u, v},
+			Color:        color,
+		}
+	}
+
+	va := vertex(a, uv0[0], uv0[1])
+	vb := vertex(b, uv1[0], uv0[1])
+	vc := vertex(c, uv1[0], uv1[1])
+	vd := vertex(d, uv0[0], uv1[1])
+
+	o.vertices = append(o.vertices, va, vb, vc, va, vc, vd)
+}
+
+func (o *Overlay) triangle(a, b, c mgl32.Vec2, color mgl32.Vec4) {
+
+	solid := o.font.solid
+
+	for _, p := range []mgl32.Vec2{a, b, c} {
+		o.vertices = append(o.vertices, Vertex{
+			Position:     mgl32.Vec3{p[0], p[1], 0},
+			TextureCoord: solid,
+			Color:        color,
+		})
+	}
+}
+
+// Texte dont le haut de la première ligne est en x, y, retourne la largeur
+// de la plus longue ligne
+func (o *Overlay) Text(x float32, y float32, color mgl32.Vec4, format string, args ...interface{}) (width float32) {
+
+	// Positions sur des pixels de l'atlas pour ne pas flouter les
+	// caractères
+	scale := o.font.scale
+	left := float32(math.Round(float64(x*scale))) / scale
+	x, y = left, float32(math.Round(float64(y*scale)))/scale
+
+	for _, r := range fmt.Sprintf(format, args...) {
+
+		if r == '\n' {
+			x, y = left, y+o.font.height
+			continue
+		}
+
+		g := o.font.glyph(r)
+		x0, y0 := x+g.offset[0], y+o.font.ascent+g.offset[1]
+		x1, y1 := x0+g.size[0], y0+g.size[1]
+
+		if g.size[0] > 0 && g.size[1] > 0 {
+			o.quad(mgl32.Vec2{x0, y0}, mgl32.Vec2{x1, y0}, mgl32.Vec2{x1, y1}, mgl32.Vec2{x0, y1},
+				g.uv[0], g.uv[1], color)
+		}
+
+		x += g.advance
+		if x-left > width {
+			width = x - left
+		}
+	}
+
+	return
+}
+
+func (o *Overlay) Rect(x float32, y float32, width float32, height float32, color mgl32.Vec4) {
+
+	solid := o.font.solid
+
+	o.quad(mgl32.Vec2{x, y}, mgl32.Vec2{x + width, y},
+		mgl32.Vec2{x + width, y + height}, mgl32.Vec2{x, y + height},
+		solid, solid, color)
+}
+
+// Segment de l'épaisseur spécifiée
+func (o *Overlay) Line(x0 float32, y0 float32, x1 float32, y1 float32, thickness float32, color mgl32.Vec4) {
+
+	direction := mgl32.Vec2{x1 - x0, y1 - y0}
+	if direction.Len() == 0 {
+		return
+	}
+
+	normal := mgl32.Vec2{-direction[1], direction[0]}.Normalize().Mul(thickness / 2)
+	a, b := mgl32.Vec2{x0, y0}, mgl32.Vec2{x1, y1}
+	solid := o.font.solid
+
+	o.quad(a.Add(normal), b.Add(normal), b.Sub(normal), a.Sub(normal), solid, solid, color)
+}
+
+// Position de value entre min & max, bornée à [0, 1]
+func ratio(value float32, min float32, max float32) float32 {
+
+	if max <= min {
+		return 0
+	}
+
+	return mgl32.Clamp((value-min)/(max-min), 0, 1)
+}
+
+// Barre horizontale remplie de min jusqu'à value, ou depuis 0 si min est
+// négatif & max positif
+func (o *Overlay) Bar(x float32, y float32, width float32, height float32,
+	value float32, min float32, max float32, color mgl32.Vec4) {
+
+	o.Rect(x, y, width, height, HUD_TRACK)
+
+	origin := float32(0)
+	if min < 0 && max > 0 {
+		origin = ratio(0, min, max)
+	}
+
+	from, to := origin, ratio(value, min, max)
+	if to < from {
+		from, to = to, from
+	}
+
+	o.Rect(x+from*width, y, (to-from)*width, height, color)
+
+	// Repère du zéro
+	if origin > 0 {
+		o.Rect(x+origin*width-0.5, y-1, 1, height+2, HUD_TEXT)
+	}
+}
+
+// Cadran centré en x, y dont l'aiguille indique value entre min & max
+func (o *Overlay) Dial(x float32, y float32, radius float32,
+	value float32, min float32, max float32, color mgl32.Vec4) {
+
+	center := mgl32.Vec2{x, y}
+
+	// Angle depuis le bas à gauche, dans le sens des aiguilles d'une montre
+	// à l'écran
+	point := func(r float32, t float32) mgl32.Vec2 {
+		angle := float64(0.75*math.Pi + t*DIAL_ANGLE)
+		return center.Add(mgl32.Vec2{float32(math.Cos(angle)), float32(math.Sin(angle))}.Mul(r))
+	}
+
+	segments := int(DIAL_SEGMENTS * DIAL_ANGLE / (2 * math.Pi))
+	inner := radius * 0.8
+	position := ratio(value, min, max)
+
+	for i := 0; i < segments; i++ {
+
+		t0, t1 := float32(i)/float32(segments), float32(i+1)/float32(segments)
+
+		segment := HUD_TRACK
+		if t1 <= position {
+			segment = color
+		}
+
+		o.quad(point(inner, t0), point(radius, t0), point(radius, t1), point(inner, t1),
+			o.font.solid, o.font.solid, segment)
+	}
+
+	needle := point(radius*0.95, position)
+	o.Line(x, y, needle[0], needle[1], 2, HUD_TEXT)
+	o.triangle(point(radius*0.12, position+0.25), point(radius*0.12, position-0.25), needle, HUD_TEXT)
+}
+
+// Courbe des valeurs, de la plus ancienne à gauche à la plus récente à
+// droite, bornées à [min, max]
+func (o *Overlay) Sparkline(x float32, y float32, width float32, height float32,
+	values []float32, min float32, max float32, color mgl32.Vec4) {
+
+	o.Rect(x, y, width, height, HUD_TRACK)
+
+	if len(values) < 2 {
+		return
+	}
+
+	step := width / float32(len(values)-1)
+	point := func(i int) (float32, float32) {
+		return x + float32(i)*step, y + (1-ratio(values[i], min, max))*height
+	}
+
+	for i := 1; i < len(values); i++ {
+		x0, y0 := point(i - 1)
+		x1, y1 := point(i)
+		o.Line(x0, y0, x1, y1, 1.5, color)
+	}
+}
+
+func (o *Overlay) Destroy() {
+	o.mesh.Destroy()
+	o.shader.Destroy()
+	o.font.Destroy()
+}
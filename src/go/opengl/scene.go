@@ -0,0 +1,220 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"time"
+)
+
+const windowWidth = 800
+const windowHeight = 600
+
+// Objets, caméra & lumières, affichés dans une fenêtre ou rendus hors écran
+type Scene struct {
+	options Options
+	objects []*Object
+	nodes   []*Node
+	trails  []*Trail
+	overlay *Overlay
+	camera  *Camera
+
+	// Taille du framebuffer en pixels & agrandissement de l'affichage tête
+	// haute des écrans à haute densité
+	width  int
+	height int
+	scale  float32
+}
+
+func createScene(options Options) (s *Scene, err error) {
+
+	if options.Width <= 0 || options.Height <= 0 {
+		options.Width, options.Height = windowWidth, windowHeight
+	}
+
+	if options.Shader == "" {
+		options.Shader = "./basicShader"
+	}
+
+	if options.Texture == "" {
+		options.Texture = "./bricks.jpg"
+	}
+
+	if options.Camera == "" {
+		options.Camera = AUDIENCE
+	}
+
+	if options.Lighting == nil {
+		options.Lighting = &DEFAULT_LIGHTING
+	}
+
+	if err = options.Lighting.check(); err != nil {
+		return
+	}
+
+	camera, err := CreateCamera(options.Width, options.Height, options.Camera, options.Cameras)
+	if err != nil {
+		return
+	}
+
+	scale := float32(options.Scale)
+	if scale <= 0 {
+		scale = 1
+	}
+
+	return &Scene{
+		options: options,
+		camera:  camera,
+		width:   options.Width,
+		height:  options.Height,
+		scale:   scale,
+	}, nil
+}
+
+// État OpenGL commun à la fenêtre & au rendu hors écran
+func (s *Scene) initGl() {
+
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Viewport(0, 0, int32(s.width), int32(s.height))
+
+	// Les faces cachées des modèles ne sont pas affichées
+	if s.options.DisableDepthTest == false {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+
+	if s.options.Samples > 0 {
+		gl.Enable(gl.MULTISAMPLE)
+	}
+}
+
+// Nouvelle taille du framebuffer, ignorée lorsque la fenêtre est réduite
+func (s *Scene) resize(width int, height int) {
+
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	s.width, s.height = width, height
+
+	gl.Viewport(0, 0, int32(width), int32(height))
+	s.camera.SetAspect(width, height)
+}
+
+// Dessine les objets dans le framebuffer courant, now datant l'image pour
+// les trajectoires & l'affichage tête haute
+func (s *Scene) Render(now time.Time) {
+
+	// Nettoyage de l'écran
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// Affichage des objects
+	for _, object := range s.objects {
+		object.Render(s.camera, s.options.Lighting)
+	}
+
+	// Les trajectoires transparentes sont dessinées en dernier
+	for _, trail := range s.trails {
+		trail.Render(s.camera, now)
+	}
+
+	// Affichage tête haute par-dessus la scène
+	if s.overlay != nil {
+		s.overlay.Render(s.width, s.height, s.scale, now)
+	}
+}
+
+func (s *Scene) destroy() {
+
+	for _, trail := range s.trails {
+		trail.Destroy()
+	}
+	s.trails = nil
+
+	if s.overlay != nil {
+		s.overlay.Destroy()
+		s.overlay = nil
+	}
+
+	for _, object := range s.objects {
+		object.Destroy()
+	}
+	s.objects = nil
+	s.nodes = nil
+}
+
+// Objet affiché, chargé depuis un modèle & placé par rapport à son parent
+type ObjectOptions struct {
+	NodeOptions
+
+	// Fichier .obj, .gltf ou .glb, à défaut un triangle recouvert de la
+	// texture de la fenêtre
+	Model string
+
+	// Point du modèle placé à la position, à défaut le pivot du fichier
+	Pivot *[3]float32
+
+	// Plus grande dimension de l'objet, 0 pour garder celle du modèle
+	Size float32
+}
+
+func (s *Scene) AddObject(options ObjectOptions) (object *Object, err error) {
+
+	if options.Model == "" {
+		if object, err = s.addTriangle(); err != nil {
+			return
+		}
+	} else {
+		var model *Model
+		if model, err = LoadModel(options.Model); err != nil {
+			return
+		}
+
+		if object, err = CreateModelObject(model, s.options.Shader); err != nil {
+			return nil, fmt.Errorf("%s: %s", options.Model, err)
+		}
+
+		if options.Size > 0 && model.Size() > 0 {
+			scale := options.Size / model.Size()
+			object.transform.SetScale(scale, scale, scale)
+		}
+	}
+
+	transform := object.GetTransform()
+
+	if options.Pivot != nil {
+		transform.SetPivot(options.Pivot[0], options.Pivot[1], options.Pivot[2])
+	}
+
+	if _, err = s.addNode(options.NodeOptions, transform, object); err != nil {
+		object.Destroy()
+		return nil, err
+	}
+
+	object.name = options.Name
+
+	s.objects = append(s.objects, object)
+
+	return
+}
+
+func (s *Scene) addTriangle() (*Object, error) {
+
+	return CreateObject([]Vertex{
+		{
+			Position:     mgl32.Vec3{-0.5, -0.5, 0.0},
+			TextureCoord: mgl32.Vec2{0.0, 0.0},
+		},
+		{
+			Position:     mgl32.Vec3{0.0, 0.5, 0.0},
+			TextureCoord: mgl32.Vec2{0.4, 1.0},
+		},
+		{
+			Position:     mgl32.Vec3{0.5, -0.5, 0.0},
+			TextureCoord: mgl32.Vec2{0.8, 0.0},
+		},
+	}, s.options.Shader, s.options.Texture)
+}
+
+func (s *Scene) GetCamera() *Camera {
+	return s.camera
+}
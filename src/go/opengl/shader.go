@@ -3,87 +3,199 @@ package opengl
 import (
 	"fmt"
 	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 	"io/ioutil"
-	"unsafe"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// Unités de texture des samplers du programme
 const (
-	TRANSFORM_U = iota
-	NUM_UNIFORMS
-
-	NUM_SHADERS = 2
+	DIFFUSE_UNIT    = 0
+	NORMAL_MAP_UNIT = 1
 )
 
+// Attributs fournis par les maillages, l'indice étant leur emplacement
+var ATTRIBUTES = []string{"position", "textureCoord", "normal", "tangent", "color"}
+
+// Étapes du programme par extension, le geometry shader étant facultatif
+var STAGES = []struct {
+	extension  string
+	shaderType uint32
+	optional   bool
+}{
+	{".vs", gl.VERTEX_SHADER, false},
+	{".gs", gl.GEOMETRY_SHADER, true},
+	{".fs", gl.FRAGMENT_SHADER, false},
+}
+
+// Uniform ou attribut actif du programme
+type Variable struct {
+	Location int32
+
+	// Type GL (gl.FLOAT_VEC3, gl.SAMPLER_2D...) & nombre d'éléments des
+	// tableaux
+	Type uint32
+	Size int32
+}
+
 type Shader struct {
-	program  uint32
-	shaders  []uint32
-	uniforms []int32
+	filename string
+	refs     int
+
+	program    uint32
+	shaders    []uint32
+	uniforms   map[string]Variable
+	attributes map[string]Variable
+
+	// Emplacements des éléments de tableaux, recherchés à leur première
+	// utilisation
+	locations map[string]int32
 }
 
+// Programmes partagés par les objets utilisant les mêmes fichiers
+var programs = make(map[string]*Shader)
+
+// Charge les fichiers filename.vs, filename.gs s'il existe & filename.fs.
+// Un programme déjà chargé est partagé & n'est détruit qu'avec son dernier
+// utilisateur.
 func CreateShader(filename string) (s *Shader, err error) {
 
-	// Création du programme
-	program := gl.CreateProgram()
+	if shared, ok := programs[filename]; ok {
+		shared.refs++
+		return shared, nil
+	}
 
-	// Initialisation du tableau de shaders & des uniforms
 	s = &Shader{
-		program:  program,
-		shaders:  make([]uint32, NUM_SHADERS),
-		uniforms: make([]int32, NUM_UNIFORMS),
+		filename:   filename,
+		refs:       1,
+		program:    gl.CreateProgram(),
+		uniforms:   make(map[string]Variable),
+		attributes: make(map[string]Variable),
+		locations:  make(map[string]int32),
 	}
 
-	// Charge le fichier contenant le VertexShader
-	vertexShader, err := loadShader(filename + ".vs")
-	if err != nil {
-		return
+	if err = s.link(); err != nil {
+		s.release()
+		return nil, err
 	}
 
-	// Charge le fichier contenant le FragmentShader
-	fragmentShader, err := loadShader(filename + ".fs")
-	if err != nil {
-		return
-	}
+	programs[filename] = s
 
-	s.shaders[0], err = createShader(vertexShader, gl.VERTEX_SHADER)
-	if err != nil {
-		return
+	return
+}
+
+func (s *Shader) link() (err error) {
+
+	for _, stage := range STAGES {
+
+		path := s.filename + stage.extension
+
+		if stage.optional {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				continue
+			}
+		}
+
+		var shader uint32
+		if shader, err = createShader(path, stage.shaderType); err != nil {
+			return
+		}
+
+		s.shaders = append(s.shaders, shader)
+		gl.AttachShader(s.program, shader)
 	}
 
-	s.shaders[1], err = createShader(fragmentShader, gl.FRAGMENT_SHADER)
-	if err != nil {
-		return
+	// Les attributs sont placés là où les maillages les fournissent
+	for location, name := range ATTRIBUTES {
+		gl.BindAttribLocation(s.program, uint32(location), gl.Str(name+"\x00"))
 	}
 
-	for i := 0; i < NUM_SHADERS; i++ {
-		gl.AttachShader(program, s.shaders[i])
+	// Vérification du programme
+	gl.LinkProgram(s.program)
+	if err = checkShader(s.program, gl.LINK_STATUS, true, "Program linking failed"); err != nil {
+		return fmt.Errorf("%s: %s", s.filename, err)
 	}
 
-	// Allocation des noms utilisés par le programme basicShader.vs
-	gl.BindAttribLocation(program, 0, gl.Str("position\x00"))
-	gl.BindAttribLocation(program, 1, gl.Str("textureCoord\x00"))
+	s.introspect()
 
-	// Vérification du programme
-	gl.LinkProgram(program)
-	if err = checkShader(program, gl.LINK_STATUS, true, "Program linking failed"); err != nil {
-		return
+	for name := range s.attributes {
+		if strings.HasPrefix(name, "gl_") == false && indexOf(ATTRIBUTES, name) < 0 {
+			return fmt.Errorf("%s: attribute '%s' not provided by meshes (expect %s)",
+				s.filename, name, strings.Join(ATTRIBUTES, ", "))
+		}
 	}
 
-	gl.ValidateProgram(program)
-	if err = checkShader(program, gl.VALIDATE_STATUS, true, "Program is invalid"); err != nil {
-		return
+	return
+}
+
+// Uniforms & attributs actifs, ceux inutilisés par les shaders étant
+// supprimés par le compilateur
+func (s *Shader) introspect() {
+
+	var count, maxLength int32
+
+	gl.GetProgramiv(s.program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(s.program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+
+	name := make([]uint8, maxLength+1)
+
+	for idx := int32(0); idx < count; idx++ {
+
+		var length int32
+		var v Variable
+
+		gl.GetActiveUniform(s.program, uint32(idx), int32(len(name)),
+			&length, &v.Size, &v.Type, &name[0])
+
+		uniform := string(name[:length])
+		v.Location = gl.GetUniformLocation(s.program, gl.Str(uniform+"\x00"))
+		s.uniforms[uniform] = v
+
+		// Un tableau est aussi désigné par son nom seul
+		if strings.HasSuffix(uniform, "[0]") {
+			s.uniforms[strings.TrimSuffix(uniform, "[0]")] = v
+		}
 	}
 
-	s.uniforms[TRANSFORM_U] = gl.GetUniformLocation(program, gl.Str("transform\x00"))
+	gl.GetProgramiv(s.program, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(s.program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &maxLength)
 
-	return
+	name = make([]uint8, maxLength+1)
+
+	for idx := int32(0); idx < count; idx++ {
+
+		var length int32
+		var v Variable
+
+		gl.GetActiveAttrib(s.program, uint32(idx), int32(len(name)),
+			&length, &v.Size, &v.Type, &name[0])
+
+		attribute := string(name[:length])
+		v.Location = gl.GetAttribLocation(s.program, gl.Str(attribute+"\x00"))
+		s.attributes[attribute] = v
+	}
 }
 
 func (s *Shader) Destroy() {
 
-	for i := 0; i < NUM_SHADERS; i++ {
-		gl.DetachShader(s.program, s.shaders[i])
-		gl.DeleteShader(s.shaders[i])
+	if s.refs--; s.refs > 0 {
+		return
+	}
+
+	delete(programs, s.filename)
+	s.release()
+}
+
+func (s *Shader) release() {
+
+	for _, shader := range s.shaders {
+		gl.DetachShader(s.program, shader)
+		gl.DeleteShader(shader)
 	}
+	s.shaders = nil
 
 	gl.DeleteProgram(s.program)
 }
@@ -92,13 +204,156 @@ func (s *Shader) Bind() {
 	gl.UseProgram(s.program)
 }
 
-func (s *Shader) Update(transform *Transform) {
+// Noms des uniforms actifs, les éléments des tableaux & structures étant
+// énumérés séparément ("lights[0].position")
+func (s *Shader) Uniforms() []string {
+	return sortedNames(s.uniforms)
+}
+
+func (s *Shader) Attributes() []string {
+	return sortedNames(s.attributes)
+}
+
+func (s *Shader) Uniform(name string) (v Variable, ok bool) {
+	v, ok = s.uniforms[name]
+	return
+}
+
+func (s *Shader) Attribute(name string) (v Variable, ok bool) {
+	v, ok = s.attributes[name]
+	return
+}
+
+// Emplacement de l'uniform, -1 s'il n'est pas actif
+func (s *Shader) location(name string) int32 {
+
+	if v, ok := s.uniforms[name]; ok {
+		return v.Location
+	}
+
+	location, ok := s.locations[name]
+	if ok == false {
+		location = gl.GetUniformLocation(s.program, gl.Str(name+"\x00"))
+		s.locations[name] = location
+	}
+
+	return location
+}
+
+// Les setters n'ont pas besoin que le programme soit utilisé. Comme avec
+// OpenGL, les uniforms inactifs sont ignorés.
+
+func (s *Shader) SetInt(name string, value int32) {
+	gl.ProgramUniform1i(s.program, s.location(name), value)
+}
+
+func (s *Shader) SetBool(name string, value bool) {
+
+	var i int32
+	if value {
+		i = 1
+	}
+
+	gl.ProgramUniform1i(s.program, s.location(name), i)
+}
+
+func (s *Shader) SetFloat(name string, value float32) {
+	gl.ProgramUniform1f(s.program, s.location(name), value)
+}
+
+func (s *Shader) SetFloats(name string, values []float32) {
+	if len(values) > 0 {
+		gl.ProgramUniform1fv(s.program, s.location(name), int32(len(values)), &values[0])
+	}
+}
+
+func (s *Shader) SetVec2(name string, value mgl32.Vec2) {
+	gl.ProgramUniform2fv(s.program, s.location(name), 1, &value[0])
+}
+
+func (s *Shader) SetVec3(name string, value mgl32.Vec3) {
+	gl.ProgramUniform3fv(s.program, s.location(name), 1, &value[0])
+}
+
+func (s *Shader) SetVec4(name string, value mgl32.Vec4) {
+	gl.ProgramUniform4fv(s.program, s.location(name), 1, &value[0])
+}
+
+func (s *Shader) SetMat3(name string, value mgl32.Mat3) {
+	gl.ProgramUniformMatrix3fv(s.program, s.location(name), 1, false, &value[0])
+}
+
+func (s *Shader) SetMat4(name string, value mgl32.Mat4) {
+	gl.ProgramUniformMatrix4fv(s.program, s.location(name), 1, false, &value[0])
+}
+
+// Unité de texture lue par le sampler
+func (s *Shader) SetSampler(name string, unit uint32) {
+	gl.ProgramUniform1i(s.program, s.location(name), int32(unit))
+}
+
+func (s *Shader) Update(transform *Transform, camera *Camera) {
 
 	model := transform.GetModel()
-	gl.UniformMatrix4fv(s.uniforms[TRANSFORM_U], 1, false, &model[0])
+	s.SetMat4("transform", model)
+	s.SetMat4("view", camera.GetView())
+	s.SetMat4("projection", camera.GetProjection())
+
+	// Les normales suivent l'inverse de la transposée, qui conserve leur
+	// orthogonalité malgré une mise à l'échelle non uniforme
+	s.SetMat3("normalMatrix", model.Mat3().Inv().Transpose())
+	s.SetVec3("cameraPosition", camera.GetPosition())
+}
+
+// Les lumières au-delà de MAX_LIGHTS sont ignorées
+func (s *Shader) UpdateLighting(lighting *Lighting) {
+
+	count := len(lighting.Lights)
+	if count > MAX_LIGHTS {
+		count = MAX_LIGHTS
+	}
+
+	s.SetVec3("ambient", lighting.Ambient)
+	s.SetInt("lightCount", int32(count))
+
+	for idx, light := range lighting.Lights[:count] {
+
+		prefix := fmt.Sprintf("lights[%d].", idx)
+
+		// Direction vers laquelle la lumière éclaire
+		direction := light.Direction
+		if direction.LenSqr() > 0 {
+			direction = direction.Normalize()
+		}
+
+		s.SetBool(prefix+"point", light.Type == POINT)
+		s.SetVec3(prefix+"direction", direction)
+		s.SetVec3(prefix+"position", light.Position)
+		s.SetVec3(prefix+"radiance", light.Color.Mul(light.Intensity))
+	}
+}
+
+// La couleur du matériau est déjà appliquée à sa texture
+func (s *Shader) UpdateMaterial(material *Material, normalMap bool) {
+
+	s.SetSampler("diffuse", DIFFUSE_UNIT)
+	s.SetSampler("normalMap", NORMAL_MAP_UNIT)
+
+	s.SetFloat("metallic", material.Metallic)
+	s.SetFloat("roughness", material.Roughness)
+	s.SetBool("hasNormalMap", normalMap)
 }
 
-func createShader(data []byte, shaderType uint32) (shader uint32, err error) {
+func createShader(filename string, shaderType uint32) (shader uint32, err error) {
+
+	p := &preprocessor{
+		loading: make(map[string]bool),
+	}
+
+	data, err := p.load(filename)
+	if err != nil {
+		return
+	}
 
 	shader = gl.CreateShader(shaderType)
 	if shader == 0 {
@@ -106,7 +361,7 @@ func createShader(data []byte, shaderType uint32) (shader uint32, err error) {
 		return
 	}
 
-	cstrs, freeCb := gl.Strs(string(data))
+	cstrs, freeCb := gl.Strs(data)
 	length := int32(len(data))
 	gl.ShaderSource(shader, 1, cstrs, &length)
 	freeCb()
@@ -114,21 +369,96 @@ func createShader(data []byte, shaderType uint32) (shader uint32, err error) {
 	gl.CompileShader(shader)
 
 	if err = checkShader(shader, gl.COMPILE_STATUS, false, "Shader compilation failed"); err != nil {
-		return
+		gl.DeleteShader(shader)
+
+		// Les erreurs indiquent le numéro de source des fichiers inclus
+		if len(p.files) > 1 {
+			sources := make([]string, len(p.files))
+			for idx, file := range p.files {
+				sources[idx] = fmt.Sprintf("%d=%s", idx, file)
+			}
+			err = fmt.Errorf("%s (sources %s)", err, strings.Join(sources, ", "))
+		}
+
+		return 0, fmt.Errorf("%s: %s", filename, err)
 	}
 
 	return
 }
 
-func loadShader(filename string) (data []byte, err error) {
+// Remplace les lignes #include "fichier" par le contenu du fichier, cherché
+// à partir du répertoire du fichier qui l'inclut. Les directives #line
+// conservent les numéros de ligne des messages d'erreur, la source étant
+// l'indice du fichier dans files.
+type preprocessor struct {
+	files   []string
+	loading map[string]bool
+}
+
+func (p *preprocessor) load(filename string) (text string, err error) {
 
-	data, err = ioutil.ReadFile(filename)
+	path := filepath.Clean(filename)
+
+	if p.loading[path] {
+		return "", fmt.Errorf("recursive include of %s", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		err = fmt.Errorf("loadShader: %s", err.Error())
+		return "", fmt.Errorf("loadShader: %s", err.Error())
+	}
+
+	p.loading[path] = true
+	defer delete(p.loading, path)
+
+	source := len(p.files)
+	p.files = append(p.files, path)
+
+	var b strings.Builder
+
+	for idx, line := range strings.Split(string(data), "\n") {
+
+		name, ok, err := includeName(line)
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: %s", path, idx+1, err)
+		}
+
+		if ok == false {
+			b.WriteString(line)
+			b.WriteByte('\n')
+			continue
+		}
+
+		included := len(p.files)
+
+		content, err := p.load(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			return "", fmt.Errorf("%s:%d: %s", path, idx+1, err)
+		}
+
+		fmt.Fprintf(&b, "#line 1 %d\n%s#line %d %d\n", included, content, idx+2, source)
+	}
+
+	return b.String(), nil
+}
+
+func includeName(line string) (name string, ok bool, err error) {
+
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#include") == false {
 		return
 	}
 
-	return
+	arg := strings.TrimSpace(strings.TrimPrefix(line, "#include"))
+
+	quoted := len(arg) >= 3 && (arg[0] == '"' && arg[len(arg)-1] == '"' ||
+		arg[0] == '<' && arg[len(arg)-1] == '>')
+
+	if quoted == false {
+		return "", false, fmt.Errorf("invalid include '%s'", arg)
+	}
+
+	return arg[1 : len(arg)-1], true, nil
 }
 
 func checkShader(shader uint32, flag uint32, isProgram bool, msg string) error {
@@ -145,13 +475,42 @@ func checkShader(shader uint32, flag uint32, isProgram bool, msg string) error {
 		return nil
 	}
 
-	errMsg := make([]byte, 1024)
+	var length int32
+
+	if isProgram {
+		gl.GetProgramiv(shader, gl.INFO_LOG_LENGTH, &length)
+	} else {
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &length)
+	}
+
+	errMsg := make([]byte, length+1)
 
 	if isProgram {
-		gl.GetProgramInfoLog(shader, int32(unsafe.Sizeof(errMsg)), nil, &errMsg[0])
+		gl.GetProgramInfoLog(shader, int32(len(errMsg)), nil, &errMsg[0])
 	} else {
-		gl.GetShaderInfoLog(shader, int32(unsafe.Sizeof(errMsg)), nil, &errMsg[0])
+		gl.GetShaderInfoLog(shader, int32(len(errMsg)), nil, &errMsg[0])
+	}
+
+	return fmt.Errorf("checkShader: %s [%s]", msg, strings.TrimSpace(gl.GoStr(&errMsg[0])))
+}
+
+func sortedNames(variables map[string]Variable) (names []string) {
+
+	for name := range variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return
+}
+
+func indexOf(values []string, value string) int {
+
+	for idx, v := range values {
+		if v == value {
+			return idx
+		}
 	}
 
-	return fmt.Errorf("checkShader: %s [%s]", msg, errMsg)
+	return -1
 }
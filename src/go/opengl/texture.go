@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"image"
+	"image/color"
 	"image/draw"
 	_ "image/gif"
 	_ "image/jpeg"
@@ -17,19 +18,44 @@ type Texture struct {
 
 func CreateTexture(filename string) (t *Texture, err error) {
 
-	// Récupération du fichier png, jpeg ou gif
+	img, err := loadImage(filename)
+	if err != nil {
+		return
+	}
+
+	return CreateTextureFromImage(img)
+}
+
+// Texture d'une seule couleur, pour les matériaux sans image
+func CreateColorTexture(c color.Color) (*Texture, error) {
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, c)
+
+	return CreateTextureFromImage(img)
+}
+
+// Récupération d'un fichier png, jpeg ou gif
+func loadImage(filename string) (img image.Image, err error) {
+
 	file, err := os.Open(filename)
-	defer file.Close()
 	if err != nil {
 		return
 	}
+	defer file.Close()
 
 	// Récupération de l'image à décoder
-	img, _, err := image.Decode(file)
+	img, _, err = image.Decode(file)
 	if err != nil {
-		return
+		err = fmt.Errorf("%s: %s", filename, err)
 	}
 
+	return
+}
+
+// La première ligne de l'image correspond à la coordonnée de texture 0
+func CreateTextureFromImage(img image.Image) (t *Texture, err error) {
+
 	// Conversion de l'image au format RGBA
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
@@ -0,0 +1,260 @@
+package opengl
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Primitives de la trajectoire : ligne passant par la pointe de l'archet, ou
+// ruban balayé par l'extrémité de l'archet, plus lisible car OpenGL 4.1 core
+// ne dessine que des lignes d'un pixel
+const (
+	TRAIL_LINE   = "line"
+	TRAIL_RIBBON = "ribbon"
+)
+
+// Couleur de la trajectoire, unique à défaut
+const (
+	COLOR_BY_SPEED  = "speed"
+	COLOR_BY_STROKE = "stroke"
+)
+
+// Points conservés au plus, quelle que soit la cadence d'affichage
+const MAX_TRAIL_POINTS = 2048
+
+var (
+	TRAIL_COLOR = mgl32.Vec3{1, 0.85, 0.4}
+
+	// Du lent au rapide
+	SLOW_COLOR = mgl32.Vec3{0.2, 0.5, 1}
+	FAST_COLOR = mgl32.Vec3{1, 0.25, 0.1}
+
+	// Par sens du coup d'archet (input.STROKE_DOWN & STROKE_UP)
+	STROKE_COLORS = map[string]mgl32.Vec3{
+		"down": {0.3, 0.9, 0.4},
+		"up":   {0.9, 0.3, 0.8},
+	}
+)
+
+type TrailOptions struct {
+	// TRAIL_LINE ou TRAIL_RIBBON
+	Style string
+
+	// COLOR_BY_SPEED, COLOR_BY_STROKE ou vide pour TRAIL_COLOR
+	ColorBy string
+
+	// Direction de l'archet dans le repère de l'objet & distance de la
+	// position de l'objet à la pointe
+	Axis   [3]float32
+	Length float32
+
+	// Largeur du ruban le long de l'archet
+	Width float32
+
+	// Durée pendant laquelle un point s'estompe avant de disparaître
+	Duration time.Duration
+
+	// Vitesse de la pointe, en unités de la scène par seconde, colorée en
+	// FAST_COLOR
+	MaxSpeed float32
+
+	// Préfixe des fichiers du programme, à défaut trailShader dans le
+	// répertoire du programme de la scène
+	Shader string
+}
+
+type trailPoint struct {
+	// Pointe & point du ruban le plus proche de la hausse
+	tip  mgl32.Vec3
+	base mgl32.Vec3
+
+	time   time.Time
+	speed  float32
+	stroke string
+}
+
+// Trajectoire récente de la pointe de l'archet, calculée à chaque image
+// depuis l'orientation de l'objet suivi
+type Trail struct {
+	options TrailOptions
+	object  *Object
+	shader  *Shader
+	mesh    *Mesh
+	points  []trailPoint
+
+	// Sens du coup d'archet en cours, modifié par les capteurs
+	mutex  sync.Mutex
+	stroke string
+}
+
+func (s *Scene) AddTrail(object *Object, options TrailOptions) (t *Trail, err error) {
+
+	mode := uint32(gl.LINE_STRIP)
+
+	switch options.Style {
+	case TRAIL_LINE:
+	case TRAIL_RIBBON:
+		mode = gl.TRIANGLE_STRIP
+	default:
+		return nil, fmt.Errorf("unknown trail style '%s' (expect %s or %s)",
+			options.Style, TRAIL_LINE, TRAIL_RIBBON)
+	}
+
+	switch options.ColorBy {
+	case "", COLOR_BY_SPEED, COLOR_BY_STROKE:
+	default:
+		return nil, fmt.Errorf("unknown trail color '%s' (expect %s or %s)",
+			options.ColorBy, COLOR_BY_SPEED, COLOR_BY_STROKE)
+	}
+
+	axis := mgl32.Vec3(options.Axis)
+	if axis.LenSqr() == 0 || options.Length <= 0 || options.Duration <= 0 {
+		return nil, fmt.Errorf("trail: invalid axis, length or duration")
+	}
+
+	if options.Shader == "" {
+		options.Shader = filepath.Join(filepath.Dir(s.options.Shader), "trailShader")
+	}
+
+	shader, err := CreateShader(options.Shader)
+	if err != nil {
+		return
+	}
+
+	t = &Trail{
+		options: options,
+		object:  object,
+		shader:  shader,
+		mesh:    CreateDynamicMesh(mode),
+	}
+
+	s.trails = append(s.trails, t)
+
+	return
+}
+
+// Sens du coup d'archet, utilisé par COLOR_BY_STROKE
+func (t *Trail) SetStroke(direction string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.stroke = direction
+}
+
+func (t *Trail) Clear() {
+	t.points = t.points[:0]
+}
+
+// Ajoute la position courante de la pointe & oublie les points trop anciens
+func (t *Trail) update(now time.Time) {
+
+	// Retour en arrière (nouvelle lecture d'une session)
+	if len(t.points) > 0 && now.Before(t.points[len(t.points)-1].time) {
+		t.Clear()
+	}
+
+	for len(t.points) > 0 && now.Sub(t.points[0].time) > t.options.Duration {
+		t.points = t.points[1:]
+	}
+
+	// Repère de l'archet dans la scène, celui de son parent compris
+	frame := t.object.GetTransform().GetFrame()
+	axis := mgl32.Vec3(t.options.Axis).Normalize()
+
+	base := t.options.Length - t.options.Width
+	if base < 0 {
+		base = 0
+	}
+
+	point := trailPoint{
+		tip:  frame.Mul4x1(axis.Mul(t.options.Length).Vec4(1)).Vec3(),
+		base: frame.Mul4x1(axis.Mul(base).Vec4(1)).Vec3(),
+		time: now,
+	}
+
+	t.mutex.Lock()
+	point.stroke = t.stroke
+	t.mutex.Unlock()
+
+	if count := len(t.points); count > 0 {
+
+		previous := t.points[count-1]
+
+		dt := float32(now.Sub(previous.time).Seconds())
+		if dt <= 0 {
+			return
+		}
+
+		point.speed = point.tip.Sub(previous.tip).Len() / dt
+	}
+
+	if len(t.points) >= MAX_TRAIL_POINTS {
+		t.points = t.points[1:]
+	}
+
+	t.points = append(t.points, point)
+}
+
+func (t *Trail) color(point trailPoint) mgl32.Vec3 {
+
+	switch t.options.ColorBy {
+	case COLOR_BY_SPEED:
+		ratio := float32(1)
+		if t.options.MaxSpeed > 0 {
+			ratio = mgl32.Clamp(point.speed/t.options.MaxSpeed, 0, 1)
+		}
+		return SLOW_COLOR.Mul(1 - ratio).Add(FAST_COLOR.Mul(ratio))
+
+	case COLOR_BY_STROKE:
+		if color, ok := STROKE_COLORS[point.stroke]; ok {
+			return color
+		}
+	}
+
+	return TRAIL_COLOR
+}
+
+// Les points s'estompent avec leur âge, la trajectoire étant dessinée après
+// les objets sans masquer ce qui se trouve derrière elle
+func (t *Trail) Render(camera *Camera, now time.Time) {
+
+	t.update(now)
+
+	vertices := make([]Vertex, 0, 2*len(t.points))
+
+	for _, point := range t.points {
+
+		alpha := 1 - float32(now.Sub(point.time))/float32(t.options.Duration)
+		color := t.color(point).Vec4(mgl32.Clamp(alpha, 0, 1))
+
+		vertices = append(vertices, Vertex{Position: point.tip, Color: color})
+
+		if t.options.Style == TRAIL_RIBBON {
+			vertices = append(vertices, Vertex{Position: point.base, Color: color})
+		}
+	}
+
+	t.mesh.Update(vertices, nil)
+
+	t.shader.Bind()
+	t.shader.SetMat4("view", camera.GetView())
+	t.shader.SetMat4("projection", camera.GetProjection())
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.DepthMask(false)
+
+	t.mesh.Draw()
+
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
+
+func (t *Trail) Destroy() {
+	t.mesh.Destroy()
+	t.shader.Destroy()
+}
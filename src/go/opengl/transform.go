@@ -1,13 +1,32 @@
 package opengl
 
 import (
+	"fmt"
 	"github.com/go-gl/mathgl/mgl32"
+	"sync"
 )
 
+// Echelle minimale, une échelle nulle ou négative retournant l'objet
+const MIN_SCALE = 0.01
+
 type Transform struct {
+	// Modifiée par les capteurs & par le clavier pendant l'affichage
+	mutex sync.Mutex
+
 	position mgl32.Vec3
 	rotation mgl32.Quat
 	scale    mgl32.Vec3
+
+	// Point de l'objet placé à la position & autour duquel il tourne
+	pivot mgl32.Vec3
+
+	// Repère dans lequel la position & l'orientation sont exprimées, nil
+	// pour la scène. L'échelle & le pivot du parent ne s'appliquent pas à
+	// ses enfants.
+	parent *Transform
+
+	// Etat restauré par Reset
+	initial *Transform
 }
 
 func SetCenter() *Transform {
@@ -19,32 +38,227 @@ func SetCenter() *Transform {
 }
 
 func (t *Transform) GetModel() mgl32.Mat4 {
-	positionMatrix := mgl32.Translate3D(t.position.X(), t.position.Y(), t.position.Z())
-	rotateMatrix := t.rotation.Mat4()
+
+	frame := t.GetFrame()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	scaleMatrix := mgl32.Scale3D(t.scale.X(), t.scale.Y(), t.scale.Z())
+	pivotMatrix := mgl32.Translate3D(-t.pivot.X(), -t.pivot.Y(), -t.pivot.Z())
+
+	return frame.Mul4(scaleMatrix.Mul4(pivotMatrix))
+}
 
-	return positionMatrix.Mul4(rotateMatrix.Mul4(scaleMatrix))
+// Position & orientation dans la scène, composées avec celles des parents
+func (t *Transform) GetFrame() mgl32.Mat4 {
+
+	parent := mgl32.Ident4()
+	if t.parent != nil {
+		parent = t.parent.GetFrame()
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	positionMatrix := mgl32.Translate3D(t.position.X(), t.position.Y(), t.position.Z())
+
+	return parent.Mul4(positionMatrix.Mul4(t.rotation.Mat4()))
 }
 
+// Orientation dans la scène, composée avec celles des parents
+func (t *Transform) GetWorldRotation() mgl32.Quat {
+
+	parent := mgl32.QuatIdent()
+	if t.parent != nil {
+		parent = t.parent.GetWorldRotation()
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return parent.Mul(t.rotation)
+}
+
+func (t *Transform) GetParent() *Transform {
+	return t.parent
+}
+
+// Le transform suit ensuite son parent, sa position & son orientation
+// restant relatives à celui-ci
+func (t *Transform) SetParent(parent *Transform) error {
+
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.parent {
+		if ancestor == t {
+			return fmt.Errorf("transform would be its own parent")
+		}
+	}
+
+	t.parent = parent
+	return nil
+}
+
+// Rotation de la scène exprimée dans le repère du parent
+func (t *Transform) toParent(rotation mgl32.Quat) mgl32.Quat {
+
+	if t.parent == nil {
+		return rotation
+	}
+
+	parent := t.parent.GetWorldRotation()
+	return parent.Inverse().Mul(rotation.Mul(parent))
+}
+
+func (t *Transform) GetPosition() mgl32.Vec3 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.position
+}
+
+func (t *Transform) GetRotation() mgl32.Quat {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.rotation
+}
+
+// Déplacement le long des axes de la scène
 func (t *Transform) Move(x float32, y float32, z float32) {
-	t.position = t.position.Add(mgl32.Vec3{x, y, z})
+
+	offset := mgl32.Vec3{x, y, z}
+	if t.parent != nil {
+		offset = t.parent.GetWorldRotation().Inverse().Rotate(offset)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.position = t.position.Add(offset)
+}
+
+func (t *Transform) SetPosition(x float32, y float32, z float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.position = mgl32.Vec3{x, y, z}
 }
 
+func (t *Transform) SetPivot(x float32, y float32, z float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pivot = mgl32.Vec3{x, y, z}
+}
+
+// Rotation incrémentale en radians autour des axes X, Y puis Z de la scène,
+// composée avec l'orientation courante
 func (t *Transform) Rotate(x float32, y float32, z float32) {
-	// rotateX := mgl32.QuatRotate(x, mgl32.Vec3{0, 0, 1})
-	// rotateY := mgl32.QuatRotate(y, mgl32.Vec3{0, 1, 0})
-	// rotateZ := mgl32.QuatRotate(z, mgl32.Vec3{0, 0, 1})
+	t.compose(eulerRotation(x, y, z))
+}
+
+// Rotations en radians autour des axes X, Y puis Z
+func eulerRotation(x float32, y float32, z float32) mgl32.Quat {
+
+	rotateX := mgl32.QuatRotate(x, mgl32.Vec3{1, 0, 0})
+	rotateY := mgl32.QuatRotate(y, mgl32.Vec3{0, 1, 0})
+	rotateZ := mgl32.QuatRotate(z, mgl32.Vec3{0, 0, 1})
 
-	//t.rotation = t.rotation.Add(rotateX)
+	return rotateZ.Mul(rotateY.Mul(rotateX))
+}
+
+// Rotation incrémentale en radians autour d'un axe de la scène
+func (t *Transform) RotateAxis(angle float32, axis mgl32.Vec3) {
+
+	if axis.Len() == 0 {
+		return
+	}
+
+	t.compose(mgl32.QuatRotate(angle, axis.Normalize()))
+}
+
+func (t *Transform) compose(rotation mgl32.Quat) {
+
+	rotation = t.toParent(rotation)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Normalisé pour que les erreurs d'arrondi ne s'accumulent pas
+	t.rotation = rotation.Mul(t.rotation).Normalize()
 }
 
 func (t *Transform) SetRotate(w float32, x float32, y float32, z float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	t.rotation = mgl32.Quat{
 		W: w,
 		V: mgl32.Vec3{x, y, z},
 	}
 }
 
+// Orientation dans la scène, celle d'un capteur fixé sur l'objet quelle que
+// soit l'orientation de son parent
+func (t *Transform) SetWorldRotate(w float32, x float32, y float32, z float32) {
+
+	rotation := mgl32.Quat{W: w, V: mgl32.Vec3{x, y, z}}
+	if t.parent != nil {
+		rotation = t.parent.GetWorldRotation().Inverse().Mul(rotation)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.rotation = rotation.Normalize()
+}
+
 func (t *Transform) Scale(x float32, y float32, z float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	t.scale = t.scale.Add(mgl32.Vec3{x, y, z})
+
+	for i := 0; i < 3; i++ {
+		if t.scale[i] < MIN_SCALE {
+			t.scale[i] = MIN_SCALE
+		}
+	}
+}
+
+func (t *Transform) SetScale(x float32, y float32, z float32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.scale = mgl32.Vec3{x, y, z}
+}
+
+// Mémorise l'état courant, restauré par Reset
+func (t *Transform) SetInitial() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.initial = &Transform{
+		position: t.position,
+		rotation: t.rotation,
+		scale:    t.scale,
+		pivot:    t.pivot,
+	}
+}
+
+func (t *Transform) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.initial == nil {
+		t.position = mgl32.Vec3{0.0, 0.0, 0.0}
+		t.rotation = mgl32.QuatIdent()
+		t.scale = mgl32.Vec3{1.0, 1.0, 1.0}
+		return
+	}
+
+	t.position = t.initial.position
+	t.rotation = t.initial.rotation
+	t.scale = t.initial.scale
+	t.pivot = t.initial.pivot
 }
@@ -4,22 +4,80 @@ import (
 	"fmt"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
-	"github.com/go-gl/mathgl/mgl32"
 	"runtime"
 	"time"
 )
 
-const windowWidth = 800
-const windowHeight = 600
+type Options struct {
+	Width  int
+	Height int
+
+	// Préfixe des fichiers .vs & .fs du programme & image appliqués aux objets
+	Shader  string
+	Texture string
+
+	// Vue initiale & fichier où sont sauvegardées les vues
+	Camera  string
+	Cameras string
+
+	// Raccourcis remplaçant ceux par défaut
+	Bindings map[string]string
+
+	// Lumières de la scène, à défaut DEFAULT_LIGHTING
+	Lighting *Lighting
+
+	// Plein écran sur l'écran désigné par son nom ou son numéro, à défaut
+	// l'écran principal
+	Fullscreen bool
+	Monitor    string
+
+	// Agrandissement de l'affichage tête haute, 0 pour le déduire de l'écran
+	Scale float64
+
+	// Synchronisation avec le rafraîchissement de l'écran, échantillons par
+	// pixel de l'anticrénelage (0 pour le désactiver) & test de profondeur
+	Vsync            bool
+	Samples          int
+	DisableDepthTest bool
+}
 
 type Window struct {
-	window       *glfw.Window
-	previousTime time.Time
-	fps          int
-	objects      []*Object
+	*Scene
+
+	window   *glfw.Window
+	bindings *Bindings
+
+	// Écran du plein écran & position et taille de la fenêtre à restaurer
+	// en le quittant
+	monitor  *glfw.Monitor
+	windowed [4]int
+
+	// Objet manipulé au clavier & à la souris
+	selected int
+
+	// Orientation par les capteurs suspendue
+	frozen int32
+
+	// Action de la souris en cours, bouton maintenu & dernière position du
+	// curseur
+	dragging   string
+	dragButton glfw.MouseButton
+	cursorX    float64
+	cursorY    float64
 }
 
-func CreateWindow() (w *Window, err error) {
+func CreateWindow(options Options) (w *Window, err error) {
+
+	scene, err := createScene(options)
+	if err != nil {
+		return
+	}
+	options = scene.options
+
+	bindings, err := ParseBindings(options.Bindings)
+	if err != nil {
+		return
+	}
 
 	// Initialisation de la fenêtre & OpenGL
 	if err = glfw.Init(); err != nil {
@@ -27,23 +85,46 @@ func CreateWindow() (w *Window, err error) {
 		return
 	}
 
-	glfw.WindowHint(glfw.Resizable, glfw.False)
+	monitor, err := findMonitor(options.Monitor)
+	if err != nil {
+		glfw.Terminate()
+		return
+	}
+
+	glfw.WindowHint(glfw.Resizable, glfw.True)
+	glfw.WindowHint(glfw.Samples, options.Samples)
 	glfw.WindowHint(glfw.ContextVersionMajor, 4)
 	glfw.WindowHint(glfw.ContextVersionMinor, 1)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 
-	window, err := glfw.CreateWindow(windowWidth, windowHeight, "Violin", nil, nil)
+	// Le plein écran prend la résolution de l'écran
+	width, height, fullscreen := options.Width, options.Height, (*glfw.Monitor)(nil)
+	if options.Fullscreen {
+		mode := monitor.GetVideoMode()
+		width, height, fullscreen = mode.Width, mode.Height, monitor
+	}
+
+	window, err := glfw.CreateWindow(width, height, "Violin", fullscreen, nil)
 	if err != nil {
 		return
 	}
 
 	w = &Window{
-		window: window,
+		Scene:    scene,
+		window:   window,
+		bindings: bindings,
+		monitor:  monitor,
+		windowed: [4]int{0, 0, options.Width, options.Height},
 	}
 
 	window.MakeContextCurrent()
 	window.SetKeyCallback(w.keyboard)
+	window.SetMouseButtonCallback(w.mouseButton)
+	window.SetCursorPosCallback(w.cursorPosition)
+	window.SetScrollCallback(w.scroll)
+	window.SetSizeCallback(w.windowSize)
+	window.SetFramebufferSizeCallback(w.framebufferSize)
 
 	// Initialisation de OpenGl
 	if err = gl.Init(); err != nil {
@@ -56,6 +137,12 @@ func CreateWindow() (w *Window, err error) {
 	fmt.Println(gl.GoStr(gl.GetString(gl.VENDOR)))
 	fmt.Println(gl.GoStr(gl.GetString(gl.RENDERER)))
 
+	w.setVsync()
+
+	w.resize(window.GetFramebufferSize())
+	w.updateScale()
+	w.initGl()
+
 	return
 }
 
@@ -63,103 +150,24 @@ func (w *Window) Start() {
 
 	runtime.LockOSThread()
 
-	fps := 0
-	counter := 0.0
-	w.previousTime = time.Now()
-
 	for !w.window.ShouldClose() {
 
-		// Affichage du fps
-		if time.Now().Unix() > w.previousTime.Unix() {
-			w.previousTime = time.Now()
-			fmt.Printf("\x1b[2K\x1b[G%d fps", fps)
-			w.fps = fps
-			fps = 0
-		}
-
-		// Nettoyage de l'écran
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-
-		// Affichage des objects
-		for _, object := range w.objects {
-			object.Render()
-		}
+		w.Render(time.Now())
 
 		// Rafraîchit la fenêtre
 		w.window.SwapBuffers()
 		glfw.PollEvents()
-
-		counter += 0.01
-		fps++
 	}
 }
 
 func (w *Window) Stop() {
-	glfw.Terminate()
-}
-
-func (w *Window) AddObject() *Object {
-
-	object := CreateObject([]Vertex{
-		{
-			Position:     mgl32.Vec3{-0.5, -0.5, 0.0},
-			TextureCoord: mgl32.Vec2{0.0, 0.0},
-		},
-		{
-			Position:     mgl32.Vec3{0.0, 0.5, 0.0},
-			TextureCoord: mgl32.Vec2{0.4, 1.0},
-		},
-		{
-			Position:     mgl32.Vec3{0.5, -0.5, 0.0},
-			TextureCoord: mgl32.Vec2{0.8, 0.0},
-		},
-	}, "./basicShader", "./bricks.jpg")
-
-	w.objects = append(w.objects, object)
-
-	return object
-}
 
-func (w *Window) keyboard(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, modifiers glfw.ModifierKey) {
+	w.destroy()
 
-	if len(w.objects) < 1 {
-		return
-	}
+	glfw.Terminate()
+}
 
-	transform := w.objects[0].GetTransform()
-
-	switch key {
-	case glfw.KeyUp:
-		// if modifiers&glfw.ModShift > 0 {
-		// 	transform.Scale(0.05, 0.05, 0.05)
-		// } else if modifiers&glfw.ModAlt > 0
-		// {
-		transform.Rotate(0.05, 0.0, 0.0)
-		// } else {
-		// 	transform.Move(0.0, 0.05, 0.0)
-		// }
-	case glfw.KeyDown:
-		// if modifiers&glfw.ModShift > 0 {
-		// 	transform.Scale(-0.05, -0.05, -0.05)
-		// } else if modifiers&glfw.ModAlt > 0 {
-		transform.Rotate(-0.05, 0.0, 0.0)
-		// } else {
-		// 	transform.Move(0.0, -0.05, 0.0)
-		// }
-		// case glfw.KeyLeft:
-		//    if modifiers&glfw.ModControl > 0 {
-		// 		transform.Rotate(0.0, -0.05, 0.0)
-		// 	} else if modifiers&glfw.ModAlt > 0 {
-		// 		transform.Rotate(0.0, 0.0, -0.05)
-		// 	} else {
-		// 		transform.Move(-0.05, 0.0, 0.0)
-		// 	}
-		// case glfw.KeyRight:
-		// 	if modifiers&glfw.ModControl > 0 {
-		// 		transform.Rotate(0.0, 0.05, 0.0)
-		// 	} else if modifiers&glfw.ModAlt > 0 {
-		// 		transform.Rotate(0.0, 0.0, 0.05)
-		// 	} else {
-		// 		transform.Move(0.05, 0.0, 0.0)
-	}
+// Demande la fermeture de la fenêtre, peut être appelée depuis une autre goroutine
+func (w *Window) Close() {
+	w.window.SetShouldClose(true)
 }
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/violin/config"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/osc"
+	"github.com/ohohleo/violin/sessions"
+)
+
+// Configuration de l'installation, chargée depuis un fichier puis modifiée
+// par les options de la ligne de commande, & options propres à une exécution
+type options struct {
+	*config.Config
+
+	ConfigPath string
+	Verbose    bool
+
+	// Sessions
+	Session  string
+	Format   string
+	Output   string
+	Speed    float64
+	Duration time.Duration
+
+	// Rendu hors écran
+	Fps     float64
+	Encoder string
+
+	// Appliqués à tous les capteurs
+	baudrate int
+	protocol string
+	bow      string
+}
+
+// Les valeurs par défaut des options sont celles du fichier de configuration
+func newOptions(flags *flag.FlagSet, path string, c *config.Config) *options {
+
+	o := &options{
+		Config:     c,
+		ConfigPath: path,
+	}
+
+	flags.StringVar(&o.ConfigPath, "config", path, "configuration file (default "+config.DEFAULT_PATH+" if present)")
+
+	flags.Var(&devicesValue{&c.Devices}, "device", "comma separated list of serial devices to open (empty for none)")
+	flags.IntVar(&o.baudrate, "baudrate", 0, "serial devices baud rate (default from the configuration)")
+	flags.StringVar(&o.protocol, "protocol", "", "serial devices protocol (default from the configuration)")
+	flags.StringVar(&o.bow, "bow", "", "identifier of the device fixed on the bow, e.g. ttyACM1 (default from the configuration)")
+	flags.StringVar(&c.Calibration, "calibration", c.Calibration, "orientation reference file written by calibrate")
+	flags.BoolVar(&o.Verbose, "verbose", false, "print the received sensor values (shown by the view3d HUD instead)")
+
+	flags.StringVar(&c.Audio.Client, "jack-name", c.Audio.Client, "JACK client name")
+	flags.Var(&linksValue{&c.Audio.Links}, "links", "comma separated list of JACK links source=destination")
+	flags.BoolVar(&c.Display.Graphs.Enabled, "display", c.Display.Graphs.Enabled, "display the audio graphs")
+	flags.BoolVar(&c.Display.View3D.Fullscreen, "fullscreen", c.Display.View3D.Fullscreen, "show view3d fullscreen")
+	flags.StringVar(&c.Display.View3D.Monitor, "monitor", c.Display.View3D.Monitor, "view3d fullscreen monitor, by name or number (default the primary one)")
+	flags.BoolVar(&c.Display.View3D.Hud.Enabled, "hud", c.Display.View3D.Hud.Enabled, "show the view3d HUD (FPS, sensors, detected pitch)")
+	flags.StringVar(&c.Display.View3D.Camera, "camera", c.Display.View3D.Camera, "initial view3d camera view (player, audience, top or a saved one)")
+	flags.Float64Var(&c.Audio.Reference, "reference", c.Audio.Reference, "tuning reference frequency")
+
+	flags.StringVar(&c.Server.Listen, "listen", c.Server.Listen, "API server listen address")
+	flags.StringVar(&c.Server.Web, "web", c.Server.Web, "serve web pages from this directory instead of the embedded ones")
+	flags.StringVar(&c.Server.Token, "token", c.Server.Token, "bearer token required to access the API (empty for none)")
+	flags.Var(&listValue{&c.Server.Origins}, "origins", "comma separated list of origins allowed to access the API")
+
+	flags.StringVar(&c.Sessions.Dir, "sessions", c.Sessions.Dir, "sessions directory")
+	flags.StringVar(&o.Session, "session", "", "session to replay or export (default the most recent)")
+	flags.StringVar(&c.Sessions.Player, "player", c.Sessions.Player, "player of the recorded session")
+	flags.StringVar(&c.Sessions.Piece, "piece", c.Sessions.Piece, "piece of the recorded session")
+	flags.StringVar(&o.Format, "format", sessions.FORMAT_JSONL, "export format (jsonl, json, csv, mid)")
+	flags.StringVar(&o.Output, "o", "", "export output file (default standard output) or render directory (default "+DEFAULT_RENDER_DIR+")")
+	flags.Float64Var(&o.Speed, "speed", 1, "replay speed factor")
+	flags.DurationVar(&o.Duration, "duration", 3*time.Second, "calibration duration")
+	flags.Float64Var(&o.Fps, "fps", DEFAULT_FPS, "render frame rate")
+	flags.StringVar(&o.Encoder, "encoder", "", "shell command receiving the rendered RGBA frames on its standard input, {width}, {height} and {fps} being replaced")
+
+	flags.Var(&listValue{&c.Osc.Targets}, "osc", "comma separated list of host:port receiving OSC messages")
+	flags.Float64Var(&c.Osc.Rate, "osc-rate", c.Osc.Rate, "maximum OSC messages per second and per address (0 for unlimited)")
+	flags.BoolVar(&c.Osc.Bundle, "osc-bundle", c.Osc.Bundle, "send OSC messages in timestamped bundles")
+	flags.StringVar(&c.Osc.Prefix, "osc-prefix", c.Osc.Prefix, "prefix added to all OSC addresses")
+	flags.Var(&mappingValue{&c.Osc.Mapping}, "osc-map", "OSC address mapping (e.g. /pitch=/freq,/level=)")
+	flags.StringVar(&c.Osc.Listen, "osc-listen", c.Osc.Listen, "receive OSC commands on this UDP address (e.g. 127.0.0.1:9001)")
+	flags.IntVar(&c.Osc.ReplyPort, "osc-reply-port", c.Osc.ReplyPort, "send OSC replies to this port instead of the sender's one")
+	flags.Var(&listValue{&c.Osc.Allow}, "osc-allow", "comma separated list of addresses or networks allowed to send OSC commands (all if empty)")
+	flags.StringVar(&c.Osc.Token, "osc-token", c.Osc.Token, "token expected as first argument of OSC commands (empty for none)")
+	flags.StringVar(&c.Mqtt.Broker, "mqtt", c.Mqtt.Broker, "publish the events on this MQTT broker (e.g. tcp://localhost:1883)")
+	flags.StringVar(&c.Mqtt.Prefix, "mqtt-prefix", c.Mqtt.Prefix, "prefix of the MQTT topics")
+	flags.IntVar(&c.Mqtt.QoS, "mqtt-qos", c.Mqtt.QoS, "MQTT quality of service of the events (0, 1 or 2)")
+	flags.StringVar(&c.Midi, "midi", c.Midi, "transcribe the audio and write it to this MIDI file on exit")
+
+	return o
+}
+
+// Applique les options communes à tous les capteurs puis valide le résultat
+func (o *options) finish() error {
+
+	bow := false
+
+	for idx := range o.Devices {
+		device := &o.Devices[idx]
+
+		if o.baudrate != 0 {
+			device.Baudrate = o.baudrate
+		}
+
+		if o.protocol != "" {
+			device.Protocol = o.protocol
+		}
+
+		if o.bow != "" {
+			device.Bow = device.Port == o.bow || filepath.Base(device.Port) == o.bow
+			bow = bow || device.Bow
+		}
+	}
+
+	if o.bow != "" && bow == false {
+		return fmt.Errorf("bow device '%s' is not in the devices list", o.bow)
+	}
+
+	return o.Validate()
+}
+
+// Le fichier de configuration doit être chargé avant la définition des
+// options, dont il fournit les valeurs par défaut
+func configPath(args []string) string {
+
+	for idx, arg := range args {
+
+		if arg == "--" {
+			break
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+
+		if name == "config" && idx+1 < len(args) {
+			return args[idx+1]
+		}
+
+		if strings.HasPrefix(name, "config=") {
+			return strings.TrimPrefix(name, "config=")
+		}
+	}
+
+	return ""
+}
+
+func loadConfig(path string) (*config.Config, error) {
+
+	if path == "" {
+		// Fichier facultatif dans le répertoire courant
+		if _, err := os.Stat(config.DEFAULT_PATH); err != nil {
+			return config.Default(), nil
+		}
+
+		path = config.DEFAULT_PATH
+	}
+
+	return config.Load(path)
+}
+
+// Liste "a,b,c"
+type listValue struct {
+	list *[]string
+}
+
+func (v *listValue) String() string {
+	if v.list == nil {
+		return ""
+	}
+	return strings.Join(*v.list, ",")
+}
+
+func (v *listValue) Set(value string) error {
+	*v.list = splitList(value)
+	return nil
+}
+
+// Ports série, ouverts avec les paramètres par défaut
+type devicesValue struct {
+	devices *[]config.Device
+}
+
+func (v *devicesValue) String() string {
+
+	if v.devices == nil {
+		return ""
+	}
+
+	ports := make([]string, len(*v.devices))
+	for idx, device := range *v.devices {
+		ports[idx] = device.Port
+	}
+
+	return strings.Join(ports, ",")
+}
+
+func (v *devicesValue) Set(value string) error {
+
+	*v.devices = nil
+
+	for _, port := range splitList(value) {
+		*v.devices = append(*v.devices, config.Device{
+			Port:     port,
+			Baudrate: input.DEFAULT_BAUDRATE,
+			Protocol: input.DEFAULT_PROTOCOL,
+		})
+	}
+
+	return nil
+}
+
+// Liaisons JACK "source=destination"
+type linksValue struct {
+	links *[]config.Link
+}
+
+func (v *linksValue) String() string {
+	if v.links == nil {
+		return ""
+	}
+	return config.FormatLinks(*v.links)
+}
+
+func (v *linksValue) Set(value string) (err error) {
+	*v.links, err = config.ParseLinks(value)
+	return
+}
+
+// Renommages d'adresses OSC "/adresse=/nouvelle"
+type mappingValue struct {
+	mapping *map[string]string
+}
+
+func (v *mappingValue) String() string {
+
+	if v.mapping == nil {
+		return ""
+	}
+
+	entries := make([]string, 0, len(*v.mapping))
+	for address, mapped := range *v.mapping {
+		entries = append(entries, address+"="+mapped)
+	}
+	sort.Strings(entries)
+
+	return strings.Join(entries, ",")
+}
+
+func (v *mappingValue) Set(value string) (err error) {
+	*v.mapping, err = osc.ParseMapping(value)
+	return
+}
+
+func splitList(list string) (result []string) {
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return
+}
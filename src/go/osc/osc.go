@@ -0,0 +1,357 @@
+package osc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Open Sound Control 1.0 : encodage & décodage des messages et des bundles
+
+const BUNDLE_TAG = "#bundle"
+
+// Nombre de secondes entre 1900 (référence NTP) et 1970
+const NTP_EPOCH_OFFSET = 2208988800
+
+// Timetag spécial signifiant "immédiatement"
+const IMMEDIATELY Timetag = 1
+
+var ErrInvalidPacket = errors.New("invalid OSC packet")
+
+// Date au format NTP : secondes depuis 1900 sur 32 bits & fraction sur 32 bits
+type Timetag uint64
+
+type Packet interface {
+	MarshalBinary() ([]byte, error)
+}
+
+type Message struct {
+	Address   string
+	Arguments []interface{}
+}
+
+type Bundle struct {
+	Timetag  Timetag
+	Elements []Packet
+}
+
+func NewMessage(address string, arguments ...interface{}) *Message {
+	return &Message{
+		Address:   address,
+		Arguments: arguments,
+	}
+}
+
+func NewBundle(date time.Time, elements ...Packet) *Bundle {
+	return &Bundle{
+		Timetag:  NewTimetag(date),
+		Elements: elements,
+	}
+}
+
+func NewTimetag(date time.Time) Timetag {
+
+	if date.IsZero() {
+		return IMMEDIATELY
+	}
+
+	seconds := uint64(date.Unix() + NTP_EPOCH_OFFSET)
+	fraction := uint64(date.Nanosecond()) << 32 / uint64(time.Second)
+
+	return Timetag(seconds<<32 | fraction)
+}
+
+func (t Timetag) Time() time.Time {
+
+	if t == IMMEDIATELY {
+		return time.Time{}
+	}
+
+	seconds := int64(t>>32) - NTP_EPOCH_OFFSET
+	nanoseconds := int64((uint64(t) & 0xffffffff) * uint64(time.Second) >> 32)
+
+	return time.Unix(seconds, nanoseconds)
+}
+
+func (m *Message) String() string {
+
+	arguments := make([]string, len(m.Arguments))
+	for idx, argument := range m.Arguments {
+		arguments[idx] = fmt.Sprint(argument)
+	}
+
+	return strings.TrimSpace(m.Address + " " + strings.Join(arguments, " "))
+}
+
+func (m *Message) MarshalBinary() ([]byte, error) {
+
+	if strings.HasPrefix(m.Address, "/") == false {
+		return nil, fmt.Errorf("invalid OSC address '%s'", m.Address)
+	}
+
+	var data bytes.Buffer
+	types := []byte{','}
+
+	for _, argument := range m.Arguments {
+
+		switch value := argument.(type) {
+
+		case int32:
+			types = append(types, 'i')
+			binary.Write(&data, binary.BigEndian, value)
+
+		case int:
+			types = append(types, 'i')
+			binary.Write(&data, binary.BigEndian, int32(value))
+
+		case int64:
+			types = append(types, 'h')
+			binary.Write(&data, binary.BigEndian, value)
+
+		case float32:
+			types = append(types, 'f')
+			binary.Write(&data, binary.BigEndian, value)
+
+		case float64:
+			types = append(types, 'd')
+			binary.Write(&data, binary.BigEndian, value)
+
+		case string:
+			types = append(types, 's')
+			writeString(&data, value)
+
+		case []byte:
+			types = append(types, 'b')
+			binary.Write(&data, binary.BigEndian, int32(len(value)))
+			data.Write(value)
+			data.Write(make([]byte, padding(len(value))))
+
+		case bool:
+			if value {
+				types = append(types, 'T')
+			} else {
+				types = append(types, 'F')
+			}
+
+		case nil:
+			types = append(types, 'N')
+
+		case Timetag:
+			types = append(types, 't')
+			binary.Write(&data, binary.BigEndian, uint64(value))
+
+		default:
+			return nil, fmt.Errorf("unsupported OSC argument type %T", argument)
+		}
+	}
+
+	var packet bytes.Buffer
+	writeString(&packet, m.Address)
+	writeString(&packet, string(types))
+	packet.Write(data.Bytes())
+
+	return packet.Bytes(), nil
+}
+
+func (b *Bundle) MarshalBinary() ([]byte, error) {
+
+	var packet bytes.Buffer
+
+	writeString(&packet, BUNDLE_TAG)
+	binary.Write(&packet, binary.BigEndian, uint64(b.Timetag))
+
+	for _, element := range b.Elements {
+
+		data, err := element.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		binary.Write(&packet, binary.BigEndian, int32(len(data)))
+		packet.Write(data)
+	}
+
+	return packet.Bytes(), nil
+}
+
+// Décode un paquet OSC : retourne un *Message ou un *Bundle
+func Parse(data []byte) (Packet, error) {
+
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, ErrInvalidPacket
+	}
+
+	if data[0] == '#' {
+		return parseBundle(data)
+	}
+
+	return parseMessage(data)
+}
+
+func parseBundle(data []byte) (*Bundle, error) {
+
+	tag, data, err := readString(data)
+	if err != nil || tag != BUNDLE_TAG || len(data) < 8 {
+		return nil, ErrInvalidPacket
+	}
+
+	bundle := &Bundle{
+		Timetag: Timetag(binary.BigEndian.Uint64(data)),
+	}
+
+	data = data[8:]
+
+	for len(data) > 0 {
+
+		if len(data) < 4 {
+			return nil, ErrInvalidPacket
+		}
+
+		size := int(int32(binary.BigEndian.Uint32(data)))
+		data = data[4:]
+
+		if size < 0 || size > len(data) {
+			return nil, ErrInvalidPacket
+		}
+
+		element, err := Parse(data[:size])
+		if err != nil {
+			return nil, err
+		}
+
+		bundle.Elements = append(bundle.Elements, element)
+		data = data[size:]
+	}
+
+	return bundle, nil
+}
+
+func parseMessage(data []byte) (*Message, error) {
+
+	address, data, err := readString(data)
+	if err != nil || strings.HasPrefix(address, "/") == false {
+		return nil, ErrInvalidPacket
+	}
+
+	message := &Message{Address: address}
+
+	// Les anciennes implémentations peuvent omettre les types
+	if len(data) == 0 {
+		return message, nil
+	}
+
+	types, data, err := readString(data)
+	if err != nil || strings.HasPrefix(types, ",") == false {
+		return nil, ErrInvalidPacket
+	}
+
+	for _, kind := range types[1:] {
+
+		var argument interface{}
+
+		switch kind {
+
+		case 'i', 'f', 'r', 'c', 'm':
+			if len(data) < 4 {
+				return nil, ErrInvalidPacket
+			}
+
+			bits := binary.BigEndian.Uint32(data)
+			data = data[4:]
+
+			switch kind {
+			case 'i':
+				argument = int32(bits)
+			case 'f':
+				argument = math.Float32frombits(bits)
+			case 'c':
+				argument = string(rune(bits))
+			default:
+				argument = bits
+			}
+
+		case 'h', 'd', 't':
+			if len(data) < 8 {
+				return nil, ErrInvalidPacket
+			}
+
+			bits := binary.BigEndian.Uint64(data)
+			data = data[8:]
+
+			switch kind {
+			case 'h':
+				argument = int64(bits)
+			case 'd':
+				argument = math.Float64frombits(bits)
+			default:
+				argument = Timetag(bits)
+			}
+
+		case 's', 'S':
+			argument, data, err = readString(data)
+			if err != nil {
+				return nil, err
+			}
+
+		case 'b':
+			if len(data) < 4 {
+				return nil, ErrInvalidPacket
+			}
+
+			size := int(int32(binary.BigEndian.Uint32(data)))
+			data = data[4:]
+
+			if size < 0 || size+padding(size) > len(data) {
+				return nil, ErrInvalidPacket
+			}
+
+			argument = append([]byte(nil), data[:size]...)
+			data = data[size+padding(size):]
+
+		case 'T':
+			argument = true
+
+		case 'F':
+			argument = false
+
+		case 'N', 'I':
+			argument = nil
+
+		default:
+			return nil, fmt.Errorf("unsupported OSC type tag '%c'", kind)
+		}
+
+		message.Arguments = append(message.Arguments, argument)
+	}
+
+	return message, nil
+}
+
+// Chaîne terminée par un zéro & complétée à un multiple de 4 octets
+func writeString(buffer *bytes.Buffer, value string) {
+	buffer.WriteString(value)
+	buffer.Write(make([]byte, 4-len(value)%4))
+}
+
+func readString(data []byte) (string, []byte, error) {
+
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, ErrInvalidPacket
+	}
+
+	size := end + 4 - end%4
+	if size > len(data) {
+		return "", nil, ErrInvalidPacket
+	}
+
+	return string(data[:end]), data[size:], nil
+}
+
+func padding(size int) int {
+	return (4 - size%4) % 4
+}
@@ -0,0 +1,191 @@
+package osc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+
+	for _, test := range []struct {
+		name    string
+		message *Message
+		types   string
+		size    int
+	}{
+		{"no argument", NewMessage("/tare"), ",", 12},
+		{"address multiple of 4", NewMessage("/abc"), ",", 12},
+		{"int32", NewMessage("/i", int32(-7)), ",i", 12},
+		{"int64", NewMessage("/h", int64(1)<<40), ",h", 16},
+		{"float32", NewMessage("/f", float32(0.25)), ",f", 12},
+		{"float64", NewMessage("/d", 440.5), ",d", 16},
+		{"string", NewMessage("/s", "abc"), ",s", 12},
+		{"string multiple of 4", NewMessage("/s", "abcd"), ",s", 16},
+		{"blob", NewMessage("/b", []byte{1, 2, 3}), ",b", 16},
+		{"booleans & nil", NewMessage("/t", true, false, nil), ",TFN", 12},
+		{"timetag", NewMessage("/t", Timetag(42)), ",t", 16},
+		{"mixed", NewMessage("/violin/orientation", float32(1), float32(0), "x", int32(3)), ",ffsi", 44},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+
+			data, err := test.message.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) != test.size || len(data)%4 != 0 {
+				t.Errorf("size %d, expect %d", len(data), test.size)
+			}
+
+			// Type tags après l'adresse complétée
+			_, rest, err := readString(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if types, _, _ := readString(rest); types != test.types {
+				t.Errorf("types '%s', expect '%s'", types, test.types)
+			}
+
+			packet, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reflect.DeepEqual(packet, test.message) == false {
+				t.Errorf("parsed %#v, expect %#v", packet, test.message)
+			}
+		})
+	}
+}
+
+func TestMessagePadding(t *testing.T) {
+
+	data, err := NewMessage("/ab", "x", []byte{9}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{
+		'/', 'a', 'b', 0,
+		',', 's', 'b', 0,
+		'x', 0, 0, 0,
+		0, 0, 0, 1,
+		9, 0, 0, 0,
+	}
+
+	if bytes.Equal(data, expected) == false {
+		t.Errorf("got % x, expect % x", data, expected)
+	}
+}
+
+func TestIntArgument(t *testing.T) {
+
+	data, err := NewMessage("/i", 5).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if arguments := packet.(*Message).Arguments; len(arguments) != 1 || arguments[0] != int32(5) {
+		t.Errorf("arguments %v, expect [5] as int32", arguments)
+	}
+}
+
+func TestInvalidMessage(t *testing.T) {
+
+	if _, err := NewMessage("tare").MarshalBinary(); err == nil {
+		t.Error("address without / accepted")
+	}
+
+	if _, err := NewMessage("/x", struct{}{}).MarshalBinary(); err == nil {
+		t.Error("unsupported argument accepted")
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+
+	date := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	bundle := NewBundle(date,
+		NewMessage("/violin/ypr", float32(1), float32(2), float32(3)),
+		NewBundle(date.Add(time.Second),
+			NewMessage("/bow/speed", float32(0.5)),
+			NewMessage("/bow/change", int32(1), float32(2))),
+		NewMessage("/tare"))
+
+	data, err := bundle.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data)%4 != 0 || bytes.HasPrefix(data, []byte("#bundle\x00")) == false {
+		t.Fatalf("invalid bundle % x", data)
+	}
+
+	packet, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(packet, bundle) == false {
+		t.Errorf("parsed %#v, expect %#v", packet, bundle)
+	}
+
+	if parsed := packet.(*Bundle); parsed.Timetag.Time().Equal(date) == false {
+		t.Errorf("date %s, expect %s", parsed.Timetag.Time(), date)
+	}
+}
+
+func TestTimetag(t *testing.T) {
+
+	for _, test := range []struct {
+		date    time.Time
+		timetag Timetag
+	}{
+		{time.Unix(0, 0), Timetag(NTP_EPOCH_OFFSET << 32)},
+		{time.Unix(1, int64(time.Second/2)), Timetag((NTP_EPOCH_OFFSET+1)<<32 | 0x80000000)},
+		{time.Time{}, IMMEDIATELY},
+	} {
+		if timetag := NewTimetag(test.date); timetag != test.timetag {
+			t.Errorf("%s: timetag %#x, expect %#x", test.date, uint64(timetag), uint64(test.timetag))
+		}
+
+		if date := test.timetag.Time(); date.Equal(test.date) == false {
+			t.Errorf("%#x: date %s, expect %s", uint64(test.timetag), date, test.date)
+		}
+	}
+
+	// La fraction sur 32 bits arrondit à moins d'une nanoseconde près
+	date := time.Unix(1700000000, 123456789)
+	if delta := NewTimetag(date).Time().Sub(date); delta < -time.Nanosecond || delta > time.Nanosecond {
+		t.Errorf("round trip error %s", delta)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+
+	valid, _ := NewMessage("/x", int32(1)).MarshalBinary()
+
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not a multiple of 4", []byte("/x\x00")},
+		{"missing address", []byte("x\x00\x00\x00")},
+		{"truncated argument", valid[:len(valid)-4]},
+		{"unknown type", []byte("/x\x00\x00,z\x00\x00")},
+		{"bundle without timetag", []byte("#bundle\x00")},
+		{"bundle element too large", append([]byte("#bundle\x00\x00\x00\x00\x00\x00\x00\x00\x01"), 0, 0, 0, 64)},
+	} {
+		if _, err := Parse(test.data); err == nil {
+			t.Errorf("%s: accepted", test.name)
+		}
+	}
+}
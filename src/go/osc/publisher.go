@@ -0,0 +1,233 @@
+package osc
+
+import (
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/metrics"
+)
+
+// Adresses publiées par défaut, renommables avec Options.Mapping
+const (
+	// Quaternion w x y z
+	VIOLIN_ORIENTATION = "/violin/orientation"
+	BOW_ORIENTATION    = "/bow/orientation"
+
+	// Lacet, tangage & roulis en radians
+	VIOLIN_YPR = "/violin/ypr"
+	BOW_YPR    = "/bow/ypr"
+
+	// Accélération dans le repère terrestre x y z
+	VIOLIN_ACCELERATION = "/violin/acceleration"
+	BOW_ACCELERATION    = "/bow/acceleration"
+
+	// Vitesse angulaire de l'archet en rad/s
+	BOW_SPEED = "/bow/speed"
+
+	// Changement de sens : 1 tiré, -1 poussé, puis la vitesse en rad/s
+	BOW_CHANGE = "/bow/change"
+
+	// Fréquence en Hz & clarté entre 0 et 1
+	PITCH = "/pitch"
+
+	// Note MIDI, écart en cents & nom de la note
+	NOTE = "/note"
+
+	// Niveau en dB, valeurs efficace & crête
+	LEVEL = "/level"
+)
+
+// Evènements ponctuels qui ne doivent jamais être perdus par la limitation
+var EVENT_ADDRESSES = map[string]bool{
+	BOW_CHANGE: true,
+}
+
+const PUBLISHER_BUFFER = 256
+
+// Publie en OSC les évènements du hub : orientation des capteurs, gestes de
+// l'archet & analyse audio
+type Publisher struct {
+	// Identifiant du capteur fixé sur l'archet, les autres capteurs sont
+	// considérés comme fixés sur le violon
+	Bow string
+
+	sender     *Sender
+	subscriber *events.Subscriber
+	done       chan struct{}
+
+	previous     *input.AccelGyro
+	previousTime time.Time
+}
+
+func NewPublisher(hub *events.Hub, sender *Sender, bow string) *Publisher {
+
+	name := "osc:" + strings.Join(sender.options.Targets, ",")
+
+	p := &Publisher{
+		Bow:    bow,
+		sender: sender,
+		subscriber: hub.SubscribeAs(name, PUBLISHER_BUFFER, hub.LastId(),
+			events.SAMPLE, events.STROKE, events.PITCH, events.LEVEL),
+		done: make(chan struct{}),
+	}
+
+	go p.publish()
+
+	return p
+}
+
+func (p *Publisher) publish() {
+
+	defer close(p.done)
+
+	var lastErr string
+
+	for event := range p.subscriber.C {
+
+		messages := p.messages(event)
+		if len(messages) == 0 {
+			continue
+		}
+
+		// Evite d'inonder les logs lorsqu'un destinataire est absent
+		if err := p.sender.Send(event.Time, messages...); err != nil {
+			if err.Error() != lastErr {
+				log.Println("osc:", err)
+				lastErr = err.Error()
+			}
+		} else {
+			lastErr = ""
+		}
+	}
+}
+
+func (p *Publisher) messages(event *events.Event) []*Message {
+
+	switch data := event.Data.(type) {
+
+	case *input.AccelGyro:
+		return p.sample(data, event.Time)
+
+	case *input.Stroke:
+		if p.Bow != "" && data.Device != p.Bow {
+			return nil
+		}
+
+		direction := int32(1)
+		if data.Direction == input.STROKE_UP {
+			direction = -1
+		}
+
+		return []*Message{NewMessage(BOW_CHANGE, direction, float32(data.Speed))}
+
+	case *audio.Pitch:
+		messages := []*Message{
+			NewMessage(PITCH, float32(data.Frequency), float32(data.Clarity)),
+		}
+
+		if data.Note != nil {
+			messages = append(messages, NewMessage(NOTE,
+				int32(data.Note.Midi), float32(data.Note.Cents), data.Note.Name))
+		}
+
+		return messages
+
+	case audio.Level:
+		return []*Message{
+			NewMessage(LEVEL, float32(data.Db), float32(data.Rms), float32(data.Peak)),
+		}
+	}
+
+	return nil
+}
+
+func (p *Publisher) sample(values *input.AccelGyro, now time.Time) (messages []*Message) {
+
+	bow := p.Bow != "" && values.Device == p.Bow
+
+	orientation, ypr, acceleration := VIOLIN_ORIENTATION, VIOLIN_YPR, VIOLIN_ACCELERATION
+	if bow {
+		orientation, ypr, acceleration = BOW_ORIENTATION, BOW_YPR, BOW_ACCELERATION
+	}
+
+	if values.Status&(input.QUATERNION|input.BUFFER) > 0 {
+
+		yaw, pitch, roll := values.YawPitchRoll()
+
+		messages = append(messages,
+			NewMessage(orientation,
+				values.QuaternionW, values.QuaternionX,
+				values.QuaternionY, values.QuaternionZ),
+			NewMessage(ypr, float32(yaw), float32(pitch), float32(roll)))
+
+		if bow {
+			if speed, ok := p.speed(values, now); ok {
+				messages = append(messages, NewMessage(BOW_SPEED, float32(speed)))
+			}
+		}
+	}
+
+	if values.Status&input.WORLDACCEL > 0 {
+		messages = append(messages,
+			NewMessage(acceleration, values.WorldX, values.WorldY, values.WorldZ))
+	}
+
+	return
+}
+
+// Vitesse angulaire de l'archet entre deux échantillons successifs
+func (p *Publisher) speed(values *input.AccelGyro, now time.Time) (speed float64, ok bool) {
+
+	previous, previousTime := p.previous, p.previousTime
+	p.previous, p.previousTime = values, now
+
+	if previous == nil {
+		return
+	}
+
+	elapsed := now.Sub(previousTime).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	dot := float64(previous.QuaternionW*values.QuaternionW +
+		previous.QuaternionX*values.QuaternionX +
+		previous.QuaternionY*values.QuaternionY +
+		previous.QuaternionZ*values.QuaternionZ)
+
+	angle := 2 * math.Acos(math.Min(1, math.Abs(dot)))
+
+	return angle / elapsed, true
+}
+
+// Statistiques d'envoi
+func (p *Publisher) Metrics() metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		stats := p.sender.Stats()
+
+		return []*metrics.Metric{
+			metrics.NewCounter("violin_osc_packets_sent_total",
+				"OSC packets sent").Add(float64(stats.Sent)),
+			metrics.NewCounter("violin_osc_rate_limited_total",
+				"OSC messages skipped by the rate limiting").Add(float64(stats.RateLimited)),
+			metrics.NewCounter("violin_osc_errors_total",
+				"OSC packets that could not be sent").Add(float64(stats.Errors)),
+		}
+	}
+}
+
+// Arrête la publication & ferme les connexions
+func (p *Publisher) Close() error {
+
+	p.subscriber.Close()
+	<-p.done
+
+	return p.sender.Close()
+}
@@ -0,0 +1,218 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Taille maximale d'un datagramme envoyé sans fragmentation sur un réseau local
+const MAX_PACKET_SIZE = 1472
+
+type Options struct {
+	// Destinataires "hôte:port"
+	Targets []string
+
+	// Regroupe les messages d'un même évènement dans un bundle horodaté
+	Bundle bool
+
+	// Nombre maximum de messages par seconde & par adresse (0 : illimité),
+	// les évènements ponctuels ne sont jamais limités
+	Rate float64
+
+	// Préfixe ajouté à toutes les adresses (ex. "/violin1")
+	Prefix string
+
+	// Renomme les adresses par défaut, une adresse vide désactive l'envoi
+	Mapping map[string]string
+}
+
+type Stats struct {
+	Sent        uint64
+	RateLimited uint64
+	Errors      uint64
+}
+
+// Envoie des messages OSC en UDP vers un ou plusieurs destinataires
+type Sender struct {
+	options Options
+	conns   []net.Conn
+
+	mutex sync.Mutex
+	last  map[string]time.Time
+	stats Stats
+}
+
+func NewSender(options Options) (s *Sender, err error) {
+
+	if len(options.Targets) == 0 {
+		return nil, errors.New("no OSC target")
+	}
+
+	if options.Prefix != "" && strings.HasPrefix(options.Prefix, "/") == false {
+		return nil, fmt.Errorf("invalid OSC prefix '%s'", options.Prefix)
+	}
+
+	s = &Sender{
+		options: options,
+		last:    make(map[string]time.Time),
+	}
+
+	for _, target := range options.Targets {
+
+		conn, err := net.Dial("udp", target)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("OSC target %s: %s", target, err)
+		}
+
+		s.conns = append(s.conns, conn)
+	}
+
+	return
+}
+
+// Analyse une liste de renommages "/adresse=/nouvelle,/desactivee="
+func ParseMapping(list string) (mapping map[string]string, err error) {
+
+	mapping = make(map[string]string)
+
+	for _, entry := range strings.Split(list, ",") {
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.HasPrefix(parts[0], "/") == false ||
+			(parts[1] != "" && strings.HasPrefix(parts[1], "/") == false) {
+			return nil, fmt.Errorf("invalid OSC mapping '%s'", entry)
+		}
+
+		mapping[parts[0]] = parts[1]
+	}
+
+	return
+}
+
+// Envoie les messages produits à la date spécifiée après renommage &
+// limitation du débit
+func (s *Sender) Send(date time.Time, messages ...*Message) error {
+
+	packets := make([]Packet, 0, len(messages))
+
+	s.mutex.Lock()
+	for _, message := range messages {
+
+		address, ok := s.address(message.Address)
+		if ok == false {
+			continue
+		}
+
+		if s.limited(address, message.Address, date) {
+			s.stats.RateLimited++
+			continue
+		}
+
+		packets = append(packets, &Message{
+			Address:   address,
+			Arguments: message.Arguments,
+		})
+	}
+	s.mutex.Unlock()
+
+	if len(packets) == 0 {
+		return nil
+	}
+
+	if s.options.Bundle {
+		packets = []Packet{NewBundle(date, packets...)}
+	}
+
+	var result error
+
+	for _, packet := range packets {
+
+		data, err := packet.MarshalBinary()
+		if err == nil && len(data) > MAX_PACKET_SIZE {
+			err = fmt.Errorf("OSC packet too large (%d bytes)", len(data))
+		}
+
+		if err == nil {
+			err = s.write(data)
+		}
+
+		s.mutex.Lock()
+		if err != nil {
+			s.stats.Errors++
+			result = err
+		} else {
+			s.stats.Sent++
+		}
+		s.mutex.Unlock()
+	}
+
+	return result
+}
+
+func (s *Sender) write(data []byte) (result error) {
+
+	// Un destinataire absent ne doit pas empêcher l'envoi aux autres
+	for _, conn := range s.conns {
+		if _, err := conn.Write(data); err != nil {
+			result = err
+		}
+	}
+
+	return
+}
+
+// Adresse après renommage, false si l'envoi est désactivé
+func (s *Sender) address(address string) (string, bool) {
+
+	if mapped, ok := s.options.Mapping[address]; ok {
+		if mapped == "" {
+			return "", false
+		}
+		address = mapped
+	}
+
+	return s.options.Prefix + address, true
+}
+
+func (s *Sender) limited(address string, original string, date time.Time) bool {
+
+	if s.options.Rate <= 0 || EVENT_ADDRESSES[original] {
+		return false
+	}
+
+	last, ok := s.last[address]
+	if ok && date.Sub(last).Seconds() < 1/s.options.Rate {
+		return true
+	}
+
+	s.last[address] = date
+	return false
+}
+
+func (s *Sender) Stats() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.stats
+}
+
+func (s *Sender) Close() (result error) {
+
+	for _, conn := range s.conns {
+		if err := conn.Close(); err != nil {
+			result = err
+		}
+	}
+
+	return
+}
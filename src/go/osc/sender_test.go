@@ -0,0 +1,196 @@
+package osc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Destinataire local des paquets envoyés
+func listen(t *testing.T) net.PacketConn {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func newTestSender(t *testing.T, conn net.PacketConn, options Options) *Sender {
+
+	options.Targets = []string{conn.LocalAddr().String()}
+
+	sender, err := NewSender(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { sender.Close() })
+
+	return sender
+}
+
+// Paquet suivant, nil si aucun n'arrive dans le délai
+func receive(t *testing.T, conn net.PacketConn, timeout time.Duration) Packet {
+
+	buffer := make([]byte, 65536)
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	size, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil
+		}
+		t.Fatal(err)
+	}
+
+	packet, err := Parse(buffer[:size])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packet
+}
+
+func receiveAddresses(t *testing.T, conn net.PacketConn) (addresses []string) {
+
+	for {
+		packet := receive(t, conn, 100*time.Millisecond)
+		if packet == nil {
+			return
+		}
+
+		message, ok := packet.(*Message)
+		if ok == false {
+			t.Fatalf("unexpected packet %#v", packet)
+		}
+
+		addresses = append(addresses, message.Address)
+	}
+}
+
+func TestSenderMapping(t *testing.T) {
+
+	conn := listen(t)
+	sender := newTestSender(t, conn, Options{
+		Prefix: "/violin1",
+		Mapping: map[string]string{
+			VIOLIN_YPR:          "/ypr",
+			VIOLIN_ACCELERATION: "",
+		},
+	})
+
+	err := sender.Send(time.Now(),
+		NewMessage(VIOLIN_YPR, float32(1), float32(2), float32(3)),
+		NewMessage(VIOLIN_ACCELERATION, float32(0), float32(0), float32(1)),
+		NewMessage(VIOLIN_ORIENTATION, float32(1), float32(0), float32(0), float32(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses := receiveAddresses(t, conn)
+	expected := []string{"/violin1/ypr", "/violin1" + VIOLIN_ORIENTATION}
+
+	if reflect.DeepEqual(addresses, expected) == false {
+		t.Errorf("received %v, expect %v", addresses, expected)
+	}
+
+	if stats := sender.Stats(); stats.Sent != 2 || stats.Errors != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestSenderRateLimit(t *testing.T) {
+
+	conn := listen(t)
+	sender := newTestSender(t, conn, Options{Rate: 10})
+
+	start := time.Now()
+
+	for _, offset := range []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond} {
+		err := sender.Send(start.Add(offset),
+			NewMessage(BOW_SPEED, float32(1)),
+			NewMessage(BOW_CHANGE, int32(1), float32(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Les changements de sens ne sont jamais limités
+	addresses := receiveAddresses(t, conn)
+	expected := []string{BOW_SPEED, BOW_CHANGE, BOW_CHANGE, BOW_SPEED, BOW_CHANGE}
+
+	if reflect.DeepEqual(addresses, expected) == false {
+		t.Errorf("received %v, expect %v", addresses, expected)
+	}
+
+	if stats := sender.Stats(); stats.Sent != 5 || stats.RateLimited != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestSenderBundle(t *testing.T) {
+
+	conn := listen(t)
+	sender := newTestSender(t, conn, Options{Bundle: true, Prefix: "/v"})
+
+	date := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	err := sender.Send(date,
+		NewMessage(BOW_SPEED, float32(1)),
+		NewMessage(BOW_CHANGE, int32(-1), float32(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, ok := receive(t, conn, time.Second).(*Bundle)
+	if ok == false {
+		t.Fatal("no bundle received")
+	}
+
+	if bundle.Timetag != NewTimetag(date) {
+		t.Errorf("timetag %s, expect %s", bundle.Timetag.Time(), date)
+	}
+
+	expected := []Packet{
+		NewMessage("/v"+BOW_SPEED, float32(1)),
+		NewMessage("/v"+BOW_CHANGE, int32(-1), float32(2)),
+	}
+
+	if reflect.DeepEqual(bundle.Elements, expected) == false {
+		t.Errorf("elements %#v, expect %#v", bundle.Elements, expected)
+	}
+
+	if packet := receive(t, conn, 100*time.Millisecond); packet != nil {
+		t.Errorf("unexpected packet %#v", packet)
+	}
+}
+
+func TestSenderOptions(t *testing.T) {
+
+	if _, err := NewSender(Options{}); err == nil {
+		t.Error("sender without target accepted")
+	}
+
+	if _, err := NewSender(Options{Targets: []string{"127.0.0.1:9000"}, Prefix: "violin"}); err == nil {
+		t.Error("prefix without / accepted")
+	}
+
+	mapping, err := ParseMapping("/pitch=/freq, /level=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"/pitch": "/freq", "/level": ""}; reflect.DeepEqual(mapping, expected) == false {
+		t.Errorf("mapping %v, expect %v", mapping, expected)
+	}
+
+	if _, err := ParseMapping("pitch=/freq"); err == nil {
+		t.Error("invalid mapping accepted")
+	}
+}
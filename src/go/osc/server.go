@@ -0,0 +1,405 @@
+package osc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ohohleo/violin/control"
+	"github.com/ohohleo/violin/metrics"
+)
+
+// Espace d'adressage : chaque commande du registre est accessible à
+// l'adresse obtenue en remplaçant les points par des barres obliques
+// ("record.start" devient "/record/start"), avec les mêmes arguments.
+//
+// Avec un jeton, chaque message le porte en premier argument, retiré avant
+// l'exécution. Les paquets d'un expéditeur hors de la liste autorisée ou
+// sans le jeton sont ignorés sans réponse.
+//
+// Le serveur répond à l'expéditeur :
+//
+//	/reply <adresse> [résultat]   la commande a été exécutée
+//	/error <adresse> <message>    adresse inconnue ou commande en échec
+//	/error #bundle <message>      bundle daté au-delà de MAX_BUNDLE_DELAY
+//	/command <adresse> <aide>     un message par commande, regroupés dans un
+//	                              seul bundle en réponse à /commands
+const (
+	COMMANDS = "/commands"
+	REPLY    = "/reply"
+	ERROR    = "/error"
+	COMMAND  = "/command"
+)
+
+const (
+	// Délai maximum d'un bundle daté dans le futur, au-delà il est rejeté
+	MAX_BUNDLE_DELAY = 5 * time.Second
+
+	// Bundles en attente de leur date au plus
+	MAX_PENDING_BUNDLES = 64
+)
+
+type ServerStats struct {
+	Received uint64
+	Unknown  uint64
+	Errors   uint64
+
+	// Bundles trop lointains ou au-delà de MAX_PENDING_BUNDLES
+	Rejected uint64
+
+	// Paquets d'un expéditeur non autorisé ou messages sans le jeton
+	Unauthorized uint64
+}
+
+// Serveur UDP exécutant les messages OSC reçus comme des commandes
+type Server struct {
+	// Port auquel sont envoyées les réponses, 0 pour le port de l'expéditeur
+	ReplyPort int
+
+	// Expéditeurs autorisés, tous si la liste est vide
+	Allow []*net.IPNet
+
+	// Jeton attendu en premier argument de chaque message, aucun si vide
+	Token string
+
+	conn     net.PacketConn
+	commands *control.Commands
+
+	mutex   sync.Mutex
+	stats   ServerStats
+	pending int
+}
+
+func NewServer(addr string, commands *control.Commands) (s *Server, err error) {
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return
+	}
+
+	s = &Server{
+		conn:     conn,
+		commands: commands,
+	}
+
+	return
+}
+
+// Analyse une liste d'adresses IP ou de réseaux "192.168.1.0/24"
+func ParseAllow(list []string) (networks []*net.IPNet, err error) {
+
+	for _, entry := range list {
+
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network '%s'", entry)
+			}
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address '%s'", entry)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	return
+}
+
+// Adresse OSC d'une commande
+func CommandAddress(name string) string {
+	return "/" + strings.Replace(name, ".", "/", -1)
+}
+
+// Commande associée à une adresse OSC
+func CommandName(address string) string {
+	return strings.Replace(strings.TrimPrefix(address, "/"), "/", ".", -1)
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Traite les paquets reçus jusqu'à la fermeture du serveur
+func (s *Server) Serve() error {
+
+	buffer := make([]byte, 65536)
+
+	for {
+		size, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if s.allowed(addr) == false {
+			s.count(func(stats *ServerStats) { stats.Unauthorized++ })
+			continue
+		}
+
+		packet, err := Parse(buffer[:size])
+		if err != nil {
+			log.Printf("osc: invalid packet from %s: %s", addr, err)
+			s.count(func(stats *ServerStats) { stats.Errors++ })
+			continue
+		}
+
+		s.handle(packet, s.replyAddr(addr))
+	}
+}
+
+func (s *Server) allowed(addr net.Addr) bool {
+
+	if len(s.Allow) == 0 {
+		return true
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if ok == false {
+		return false
+	}
+
+	for _, network := range s.Allow {
+		if network.Contains(udpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Message privé du jeton, nil si celui-ci est absent ou invalide
+func (s *Server) authenticate(message *Message) *Message {
+
+	if s.Token == "" {
+		return message
+	}
+
+	if len(message.Arguments) == 0 {
+		return nil
+	}
+
+	token, ok := message.Arguments[0].(string)
+	if ok == false || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		return nil
+	}
+
+	return &Message{Address: message.Address, Arguments: message.Arguments[1:]}
+}
+
+// Tous les messages du paquet portent le jeton
+func (s *Server) authorized(packet Packet) bool {
+
+	switch packet := packet.(type) {
+	case *Message:
+		return s.authenticate(packet) != nil
+	case *Bundle:
+		for _, element := range packet.Elements {
+			if s.authorized(element) == false {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (s *Server) replyAddr(addr net.Addr) net.Addr {
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if ok == false || s.ReplyPort == 0 {
+		return addr
+	}
+
+	return &net.UDPAddr{IP: udpAddr.IP, Port: s.ReplyPort, Zone: udpAddr.Zone}
+}
+
+func (s *Server) handle(packet Packet, addr net.Addr) {
+
+	switch packet := packet.(type) {
+
+	case *Bundle:
+		// Les bundles datés dans le futur sont exécutés à la date prévue
+		if delay := time.Until(packet.Timetag.Time()); packet.Timetag != IMMEDIATELY && delay > 0 {
+			// Seuls les bundles authentifiés sont mis en attente ou rejetés
+			if s.authorized(packet) == false {
+				s.count(func(stats *ServerStats) { stats.Unauthorized++ })
+				return
+			}
+			s.schedule(packet, addr, delay)
+			return
+		}
+
+		for _, element := range packet.Elements {
+			s.handle(element, addr)
+		}
+
+	case *Message:
+		s.execute(packet, addr)
+	}
+}
+
+// Un expéditeur du réseau ne doit pas pouvoir accumuler des commandes
+// lointaines : le délai & le nombre de bundles en attente sont bornés
+func (s *Server) schedule(bundle *Bundle, addr net.Addr, delay time.Duration) {
+
+	s.mutex.Lock()
+	rejected := delay > MAX_BUNDLE_DELAY || s.pending >= MAX_PENDING_BUNDLES
+	if rejected {
+		s.stats.Rejected++
+	} else {
+		s.pending++
+	}
+	s.mutex.Unlock()
+
+	if rejected {
+		log.Printf("osc: bundle from %s rejected (in %s, at most %s & %d pending)",
+			addr, delay.Round(time.Millisecond), MAX_BUNDLE_DELAY, MAX_PENDING_BUNDLES)
+		s.reply(addr, NewMessage(ERROR, BUNDLE_TAG, "bundle rejected"))
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+
+		s.mutex.Lock()
+		s.pending--
+		s.mutex.Unlock()
+
+		for _, element := range bundle.Elements {
+			s.handle(element, addr)
+		}
+	})
+}
+
+func (s *Server) execute(message *Message, addr net.Addr) {
+
+	if message = s.authenticate(message); message == nil {
+		s.count(func(stats *ServerStats) { stats.Unauthorized++ })
+		return
+	}
+
+	s.count(func(stats *ServerStats) { stats.Received++ })
+
+	// Une seule réponse quel que soit le nombre de commandes
+	if message.Address == COMMANDS {
+		var commands []Packet
+		for _, command := range s.commands.List() {
+			commands = append(commands, NewMessage(COMMAND,
+				CommandAddress(command.Name), command.Description))
+		}
+		s.reply(addr, NewBundle(time.Time{}, commands...))
+		return
+	}
+
+	result, err := s.commands.Execute(CommandName(message.Address), message.Arguments)
+	if err != nil {
+
+		if errors.Is(err, control.ErrUnknownCommand) {
+			log.Printf("osc: unknown address %s from %s", message.Address, addr)
+			s.count(func(stats *ServerStats) { stats.Unknown++ })
+		} else {
+			log.Printf("osc: %s: %s", message, err)
+			s.count(func(stats *ServerStats) { stats.Errors++ })
+		}
+
+		s.reply(addr, NewMessage(ERROR, message.Address, err.Error()))
+		return
+	}
+
+	reply := NewMessage(REPLY, message.Address)
+	if argument, ok := resultArgument(result); ok {
+		reply.Arguments = append(reply.Arguments, argument)
+	}
+
+	s.reply(addr, reply)
+}
+
+// Les résultats complexes sont retournés au format JSON
+func resultArgument(result interface{}) (interface{}, bool) {
+
+	switch value := result.(type) {
+	case nil:
+		return nil, false
+	case int:
+		return int32(value), true
+	case float64:
+		return float32(value), true
+	case int32, float32, string, bool:
+		return value, true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, false
+	}
+
+	return string(data), true
+}
+
+func (s *Server) reply(addr net.Addr, packet Packet) {
+
+	data, err := packet.MarshalBinary()
+	if err == nil {
+		_, err = s.conn.WriteTo(data, addr)
+	}
+
+	if err != nil {
+		log.Printf("osc: reply to %s: %s", addr, err)
+	}
+}
+
+func (s *Server) count(update func(stats *ServerStats)) {
+	s.mutex.Lock()
+	update(&s.stats)
+	s.mutex.Unlock()
+}
+
+func (s *Server) Stats() ServerStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.stats
+}
+
+// Statistiques de réception
+func (s *Server) Metrics() metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		stats := s.Stats()
+
+		return []*metrics.Metric{
+			metrics.NewCounter("violin_osc_messages_received_total",
+				"OSC messages received").Add(float64(stats.Received)),
+			metrics.NewCounter("violin_osc_unknown_addresses_total",
+				"OSC messages received with an unknown address").Add(float64(stats.Unknown)),
+			metrics.NewCounter("violin_osc_receive_errors_total",
+				"Invalid OSC packets or failed commands").Add(float64(stats.Errors)),
+			metrics.NewCounter("violin_osc_bundles_rejected_total",
+				"OSC bundles dated too far ahead or beyond the pending limit").Add(float64(stats.Rejected)),
+			metrics.NewCounter("violin_osc_unauthorized_total",
+				"OSC packets from a sender not allowed or messages without the token").Add(float64(stats.Unauthorized)),
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,336 @@
+package osc
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/violin/control"
+)
+
+// Commandes exécutées par le serveur, depuis sa goroutine ou ses timers
+type executedCommands struct {
+	mutex sync.Mutex
+	names []string
+}
+
+func (e *executedCommands) add(name string) {
+	e.mutex.Lock()
+	e.names = append(e.names, name)
+	e.mutex.Unlock()
+}
+
+func (e *executedCommands) list() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return append([]string(nil), e.names...)
+}
+
+// Serveur local exécutant quelques commandes de test & client UDP recevant
+// ses réponses, les options étant appliquées avant le démarrage
+func newTestServer(t *testing.T, options ...func(s *Server)) (*Server, net.PacketConn, *executedCommands) {
+
+	executed := new(executedCommands)
+
+	commands := control.NewCommands()
+
+	commands.Register("tare", "use the current orientation as reference",
+		func(args []interface{}) (interface{}, error) {
+			executed.add("tare")
+			return nil, nil
+		})
+
+	commands.Register("tuner.reference", "set the tuning reference [frequency]",
+		func(args []interface{}) (interface{}, error) {
+			reference, err := control.Float(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			executed.add("tuner.reference")
+			return reference, nil
+		})
+
+	commands.Register("record.stop", "stop the current practice session",
+		func(args []interface{}) (interface{}, error) {
+			return nil, errors.New("no active session")
+		})
+
+	server, err := NewServer("127.0.0.1:0", commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	return server, listen(t), executed
+}
+
+func send(t *testing.T, client net.PacketConn, server *Server, packet Packet) {
+
+	data, err := packet.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.WriteTo(data, server.Addr()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receiveMessage(t *testing.T, client net.PacketConn) *Message {
+
+	message, ok := receive(t, client, time.Second).(*Message)
+	if ok == false {
+		t.Fatal("no reply received")
+	}
+
+	return message
+}
+
+func TestServerAddresses(t *testing.T) {
+
+	for _, test := range []struct {
+		name    string
+		address string
+	}{
+		{"tare", "/tare"},
+		{"tuner.reference", "/tuner/reference"},
+		{"record.start", "/record/start"},
+	} {
+		if address := CommandAddress(test.name); address != test.address {
+			t.Errorf("%s: address %s, expect %s", test.name, address, test.address)
+		}
+
+		if name := CommandName(test.address); name != test.name {
+			t.Errorf("%s: command %s, expect %s", test.address, name, test.name)
+		}
+	}
+}
+
+func TestServerReply(t *testing.T) {
+
+	server, client, executed := newTestServer(t)
+
+	send(t, client, server, NewMessage("/tare"))
+	if reply := receiveMessage(t, client); reflect.DeepEqual(reply, NewMessage(REPLY, "/tare")) == false {
+		t.Errorf("reply %s, expect %s /tare", reply, REPLY)
+	}
+
+	send(t, client, server, NewMessage("/tuner/reference", float32(442)))
+	reply := receiveMessage(t, client)
+	if expected := NewMessage(REPLY, "/tuner/reference", float32(442)); reflect.DeepEqual(reply, expected) == false {
+		t.Errorf("reply %s, expect %s", reply, expected)
+	}
+
+	if expected := []string{"tare", "tuner.reference"}; reflect.DeepEqual(executed.list(), expected) == false {
+		t.Errorf("executed %v, expect %v", executed.list(), expected)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+
+	server, client, _ := newTestServer(t)
+
+	// Commande en échec
+	send(t, client, server, NewMessage("/record/stop"))
+	reply := receiveMessage(t, client)
+	if expected := NewMessage(ERROR, "/record/stop", "no active session"); reflect.DeepEqual(reply, expected) == false {
+		t.Errorf("reply %s, expect %s", reply, expected)
+	}
+
+	// Argument manquant
+	send(t, client, server, NewMessage("/tuner/reference"))
+	if reply := receiveMessage(t, client); reply.Address != ERROR || len(reply.Arguments) != 2 {
+		t.Errorf("reply %s, expect %s /tuner/reference message", reply, ERROR)
+	}
+
+	// Adresse inconnue
+	send(t, client, server, NewMessage("/unknown/address", int32(1)))
+	reply = receiveMessage(t, client)
+	if reply.Address != ERROR || len(reply.Arguments) != 2 || reply.Arguments[0] != "/unknown/address" {
+		t.Errorf("reply %s, expect %s /unknown/address message", reply, ERROR)
+	}
+
+	if stats := server.Stats(); stats.Received != 3 || stats.Unknown != 1 || stats.Errors != 2 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestServerCommands(t *testing.T) {
+
+	server, client, _ := newTestServer(t)
+
+	send(t, client, server, NewMessage(COMMANDS))
+
+	// Toutes les commandes dans un seul paquet
+	bundle, ok := receive(t, client, time.Second).(*Bundle)
+	if ok == false || len(bundle.Elements) != 3 {
+		t.Fatalf("reply %v, expect a bundle of 3 commands", bundle)
+	}
+
+	if packet := receive(t, client, 100*time.Millisecond); packet != nil {
+		t.Errorf("unexpected reply %v", packet)
+	}
+
+	expected := map[string]string{
+		"/tare":            "use the current orientation as reference",
+		"/tuner/reference": "set the tuning reference [frequency]",
+		"/record/stop":     "stop the current practice session",
+	}
+
+	for _, element := range bundle.Elements {
+		reply, ok := element.(*Message)
+		if ok == false || reply.Address != COMMAND || len(reply.Arguments) != 2 {
+			t.Fatalf("reply %s, expect %s address help", reply, COMMAND)
+		}
+
+		address, _ := reply.Arguments[0].(string)
+		if help, ok := expected[address]; ok == false || reply.Arguments[1] != help {
+			t.Errorf("unexpected command %s", reply)
+		}
+		delete(expected, address)
+	}
+
+	if len(expected) > 0 {
+		t.Errorf("missing commands %v", expected)
+	}
+}
+
+func TestServerBundles(t *testing.T) {
+
+	server, client, executed := newTestServer(t)
+
+	// Exécuté immédiatement
+	send(t, client, server, NewBundle(time.Time{}, NewMessage("/tare")))
+	receiveMessage(t, client)
+
+	// Exécuté à sa date
+	date := time.Now().Add(200 * time.Millisecond)
+	send(t, client, server, NewBundle(date, NewMessage("/tare")))
+
+	reply := receiveMessage(t, client)
+	if reply.Address != REPLY || time.Now().Before(date) {
+		t.Errorf("reply %s before its date", reply)
+	}
+
+	// Trop lointain
+	send(t, client, server, NewBundle(time.Now().Add(time.Hour), NewMessage("/tare")))
+
+	reply = receiveMessage(t, client)
+	if reply.Address != ERROR || len(reply.Arguments) == 0 || reply.Arguments[0] != BUNDLE_TAG {
+		t.Errorf("reply %s, expect %s %s", reply, ERROR, BUNDLE_TAG)
+	}
+
+	if len(executed.list()) != 2 {
+		t.Errorf("executed %v, expect 2 tare", executed.list())
+	}
+
+	if stats := server.Stats(); stats.Rejected != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestServerPendingBundles(t *testing.T) {
+
+	server, client, _ := newTestServer(t)
+
+	date := time.Now().Add(MAX_BUNDLE_DELAY / 2)
+	for idx := 0; idx <= MAX_PENDING_BUNDLES; idx++ {
+		send(t, client, server, NewBundle(date, NewMessage("/tare")))
+	}
+
+	// Seul le dernier est rejeté
+	reply := receiveMessage(t, client)
+	if reply.Address != ERROR || reply.Arguments[0] != BUNDLE_TAG {
+		t.Errorf("reply %s, expect %s %s", reply, ERROR, BUNDLE_TAG)
+	}
+
+	if stats := server.Stats(); stats.Rejected != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestServerAllow(t *testing.T) {
+
+	networks, err := ParseAllow([]string{"10.0.0.0/8", "192.168.1.20", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.20", true},
+		{"192.168.1.21", false},
+		{"::1", true},
+		{"127.0.0.1", false},
+	} {
+		server := &Server{Allow: networks}
+		if allowed := server.allowed(&net.UDPAddr{IP: net.ParseIP(test.ip)}); allowed != test.allowed {
+			t.Errorf("%s: allowed %t, expect %t", test.ip, allowed, test.allowed)
+		}
+	}
+
+	for _, entry := range []string{"10.0.0.0/33", "localhost", ""} {
+		if _, err := ParseAllow([]string{entry}); err == nil {
+			t.Errorf("'%s' accepted", entry)
+		}
+	}
+
+	// Client local hors de la liste : ignoré sans réponse
+	server, client, executed := newTestServer(t, func(s *Server) { s.Allow = networks[:1] })
+
+	send(t, client, server, NewMessage("/tare"))
+	if packet := receive(t, client, 100*time.Millisecond); packet != nil {
+		t.Errorf("unexpected reply %v", packet)
+	}
+
+	if stats := server.Stats(); len(executed.list()) > 0 || stats.Unauthorized != 1 || stats.Received != 0 {
+		t.Errorf("executed %v stats %+v, expect nothing executed", executed.list(), stats)
+	}
+}
+
+func TestServerToken(t *testing.T) {
+
+	server, client, executed := newTestServer(t, func(s *Server) { s.Token = "secret" })
+
+	// Jeton absent ou invalide, y compris dans un bundle daté
+	for _, packet := range []Packet{
+		NewMessage("/tare"),
+		NewMessage("/tare", "wrong"),
+		NewMessage("/tare", int32(1)),
+		NewBundle(time.Now().Add(time.Hour), NewMessage("/tare")),
+	} {
+		send(t, client, server, packet)
+	}
+
+	if packet := receive(t, client, 100*time.Millisecond); packet != nil {
+		t.Errorf("unexpected reply %v", packet)
+	}
+
+	// Jeton retiré avant l'exécution
+	send(t, client, server, NewMessage("/tuner/reference", "secret", float32(442)))
+	reply := receiveMessage(t, client)
+	if expected := NewMessage(REPLY, "/tuner/reference", float32(442)); reflect.DeepEqual(reply, expected) == false {
+		t.Errorf("reply %s, expect %s", reply, expected)
+	}
+
+	if stats := server.Stats(); stats.Unauthorized != 4 || stats.Received != 1 || stats.Rejected != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	if expected := []string{"tuner.reference"}; reflect.DeepEqual(executed.list(), expected) == false {
+		t.Errorf("executed %v, expect %v", executed.list(), expected)
+	}
+}
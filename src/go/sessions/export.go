@@ -0,0 +1,292 @@
+package sessions
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/input"
+	"github.com/ohohleo/violin/midi"
+)
+
+const (
+	FORMAT_JSONL = "jsonl"
+	FORMAT_JSON  = "json"
+	FORMAT_CSV   = "csv"
+	FORMAT_MIDI  = "mid"
+)
+
+var FORMATS = map[string]string{
+	FORMAT_JSONL: "application/x-ndjson",
+	FORMAT_JSON:  "application/json",
+	FORMAT_CSV:   "text/csv",
+	FORMAT_MIDI:  "audio/midi",
+}
+
+var CSV_HEADER = []string{
+	"id", "time", "type", "status",
+	"quaternion_w", "quaternion_x", "quaternion_y", "quaternion_z",
+	"euler_x", "euler_y", "euler_z",
+	"yaw", "pitch", "roll",
+	"real_x", "real_y", "real_z",
+	"world_x", "world_y", "world_z",
+	"data",
+}
+
+// Evènement relu depuis le fichier d'enregistrement
+type record struct {
+	Id   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Time string          `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Exporte la session au format spécifié
+func (s *Store) Export(id string, format string, w io.Writer) error {
+
+	session, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := FORMATS[format]; ok == false {
+		return fmt.Errorf("unsupported export format '%s'", format)
+	}
+
+	file, err := os.Open(s.path(id, EVENTS_FILE))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format {
+
+	case FORMAT_JSONL:
+		_, err = io.Copy(w, file)
+		return err
+
+	case FORMAT_JSON:
+		return exportJSON(session, file, w)
+
+	case FORMAT_MIDI:
+		return exportMIDI(session, file, w)
+	}
+
+	return exportCSV(file, w)
+}
+
+// Evènements au format JSON précédés des informations de la session
+func exportJSON(session *Session, file io.Reader, w io.Writer) error {
+
+	header, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	// Ajout du tableau des évènements dans l'objet session
+	if _, err = fmt.Fprintf(w, "%s,\"data\":[", header[:len(header)-1]); err != nil {
+		return err
+	}
+
+	first := true
+	err = readRecords(file, func(line []byte, r *record) error {
+
+		if first == false {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		first = false
+
+		_, err := w.Write(line)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+// Une ligne par évènement, les valeurs des capteurs étant réparties en colonnes
+func exportCSV(file io.Reader, w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(CSV_HEADER); err != nil {
+		return err
+	}
+
+	err := readRecords(file, func(line []byte, r *record) error {
+
+		row := make([]string, len(CSV_HEADER))
+		row[0] = strconv.FormatUint(r.Id, 10)
+		row[1] = r.Time
+		row[2] = r.Type
+
+		var values input.AccelGyro
+		if r.Type == events.SAMPLE && json.Unmarshal(r.Data, &values) == nil {
+
+			row[3] = strconv.Itoa(values.Status)
+
+			for idx, value := range []float32{
+				values.QuaternionW, values.QuaternionX,
+				values.QuaternionY, values.QuaternionZ,
+				values.EulerX, values.EulerY, values.EulerZ,
+				values.Yaw, values.Pitch, values.Roll,
+				values.RealX, values.RealY, values.RealZ,
+				values.WorldX, values.WorldY, values.WorldZ,
+			} {
+				row[4+idx] = strconv.FormatFloat(float64(value), 'f', -1, 32)
+			}
+		} else {
+			row[len(row)-1] = string(r.Data)
+		}
+
+		return writer.Write(row)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Notes transcrites depuis l'analyse audio, annotées par les coups d'archet
+func exportMIDI(session *Session, file io.Reader, w io.Writer) error {
+
+	transcriber := midi.NewTranscriber("")
+
+	var last time.Time
+
+	err := readRecords(file, func(line []byte, r *record) error {
+
+		event, err := r.event()
+		if err != nil {
+			return err
+		}
+
+		transcriber.Process(event)
+		last = event.Time
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	transcriber.Finish(last)
+
+	name := strings.TrimSpace(session.Piece + " " + session.Player)
+	if name == "" {
+		name = session.Id
+	}
+
+	_, err = transcriber.File(name).WriteTo(w)
+	return err
+}
+
+// Relit les évènements enregistrés de la session dans l'ordre de capture
+func (s *Store) Events(id string, onEvent func(*events.Event) error) error {
+
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	file, err := os.Open(s.path(id, EVENTS_FILE))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return readRecords(file, func(line []byte, r *record) error {
+
+		event, err := r.event()
+		if err != nil {
+			return err
+		}
+
+		return onEvent(event)
+	})
+}
+
+// Evènement dont les données sont décodées selon leur type
+func (r *record) event() (*events.Event, error) {
+
+	date, err := time.Parse(time.RFC3339Nano, r.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+
+	switch r.Type {
+	case events.SAMPLE:
+		values := new(input.AccelGyro)
+		err = json.Unmarshal(r.Data, values)
+		data = values
+	case events.STROKE:
+		stroke := new(input.Stroke)
+		err = json.Unmarshal(r.Data, stroke)
+		data = stroke
+	case events.PITCH:
+		pitch := new(audio.Pitch)
+		err = json.Unmarshal(r.Data, pitch)
+		data = pitch
+	case events.LEVEL:
+		var level audio.Level
+		err = json.Unmarshal(r.Data, &level)
+		data = level
+	case events.SPECTRUM:
+		spectrum := new(audio.Spectrum)
+		err = json.Unmarshal(r.Data, spectrum)
+		data = spectrum
+	default:
+		err = json.Unmarshal(r.Data, &data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &events.Event{Id: r.Id, Type: r.Type, Time: date, Data: data}, nil
+}
+
+func readRecords(file io.Reader, onRecord func([]byte, *record) error) error {
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+
+		if err := onRecord(line, &r); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,403 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+)
+
+const (
+	METADATA_FILE = "session.json"
+	EVENTS_FILE   = "events.jsonl"
+)
+
+var (
+	ErrNotFound     = errors.New("session not found")
+	ErrNotRecording = errors.New("session is not recording")
+
+	validId = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+)
+
+// Informations saisies par l'utilisateur
+type Metadata struct {
+	Player string  `json:"player"`
+	Piece  string  `json:"piece"`
+	Tuning float64 `json:"tuning"`
+	Notes  string  `json:"notes"`
+}
+
+type Session struct {
+	Metadata
+
+	Id        string     `json:"id"`
+	Start     time.Time  `json:"start"`
+	Stop      *time.Time `json:"stop,omitempty"`
+	Events    uint64     `json:"events"`
+	Dropped   uint64     `json:"dropped"`
+	Recording bool       `json:"recording"`
+
+	// Jeton du lien de partage, vide si la session n'est pas partagée
+	Share string `json:"share,omitempty"`
+}
+
+// Bibliothèque des sessions de travail : chaque session est stockée dans
+// son propre répertoire contenant ses informations & les évènements capturés
+type Store struct {
+	mutex     sync.Mutex
+	dir       string
+	hub       *events.Hub
+	tuner     *audio.Tuner
+	recorders map[string]*events.Recorder
+
+	// Identifiant des sessions partagées par jeton, pour ne pas relire
+	// toutes les sessions à chaque requête portant un jeton
+	shares map[string]string
+}
+
+func NewStore(dir string, hub *events.Hub, tuner *audio.Tuner) (s *Store, err error) {
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	s = &Store{
+		dir:       dir,
+		hub:       hub,
+		tuner:     tuner,
+		recorders: make(map[string]*events.Recorder),
+		shares:    make(map[string]string),
+	}
+
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		if session.Share != "" {
+			s.shares[session.Share] = session.Id
+		}
+	}
+
+	return
+}
+
+// Démarre une nouvelle session capturant tous les évènements du hub
+func (s *Store) Start(metadata Metadata) (session *Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if metadata.Tuning == 0 && s.tuner != nil {
+		metadata.Tuning = s.tuner.Reference()
+	}
+
+	now := time.Now()
+
+	session = &Session{
+		Metadata:  metadata,
+		Start:     now,
+		Recording: true,
+	}
+
+	// Création d'un répertoire unique
+	base := now.Format("20060102-150405")
+	for idx := 0; ; idx++ {
+
+		session.Id = base
+		if idx > 0 {
+			session.Id = fmt.Sprintf("%s-%d", base, idx)
+		}
+
+		err = os.Mkdir(s.path(session.Id), 0755)
+		if err == nil {
+			break
+		}
+
+		if os.IsExist(err) == false {
+			return nil, err
+		}
+	}
+
+	recorder, err := events.NewRecorder(s.hub, s.path(session.Id, EVENTS_FILE))
+	if err != nil {
+		os.RemoveAll(s.path(session.Id))
+		return nil, err
+	}
+
+	if err = s.save(session); err != nil {
+		recorder.Stop()
+		os.RemoveAll(s.path(session.Id))
+		return nil, err
+	}
+
+	s.recorders[session.Id] = recorder
+
+	return
+}
+
+// Arrête l'enregistrement de la session
+func (s *Store) Stop(id string) (session *Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, err = s.load(id)
+	if err != nil {
+		return
+	}
+
+	recorder, ok := s.recorders[id]
+	if ok == false {
+		return session, ErrNotRecording
+	}
+
+	delete(s.recorders, id)
+
+	err = recorder.Stop()
+
+	now := time.Now()
+	session.Stop = &now
+	session.Events = recorder.Count()
+	session.Dropped = recorder.Dropped()
+	session.Recording = false
+
+	if saveErr := s.save(session); err == nil {
+		err = saveErr
+	}
+
+	return
+}
+
+// Session en cours d'enregistrement la plus récente
+func (s *Store) Active() (session *Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var last string
+	for id := range s.recorders {
+		if id > last {
+			last = id
+		}
+	}
+
+	if last == "" {
+		return nil, ErrNotRecording
+	}
+
+	return s.get(last)
+}
+
+func (s *Store) Get(id string) (*Session, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.get(id)
+}
+
+// Liste des sessions, de la plus récente à la plus ancienne
+func (s *Store) List() (sessions []*Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	sessions = make([]*Session, 0, len(entries))
+
+	for _, entry := range entries {
+
+		if entry.IsDir() == false {
+			continue
+		}
+
+		session, err := s.get(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Start.After(sessions[j].Start)
+	})
+
+	return
+}
+
+// Supprime la session & ses données, en arrêtant l'enregistrement si besoin
+func (s *Store) Delete(id string) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, err := s.load(id)
+	if err != nil {
+		return err
+	}
+
+	if recorder, ok := s.recorders[id]; ok {
+		delete(s.recorders, id)
+		recorder.Stop()
+	}
+
+	if err = os.RemoveAll(s.path(id)); err != nil {
+		return err
+	}
+
+	delete(s.shares, session.Share)
+	return nil
+}
+
+// Génère le lien de partage de la session, ou retourne celui existant
+func (s *Store) Share(id string) (session *Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, err = s.get(id)
+	if err != nil || session.Share != "" {
+		return
+	}
+
+	token := make([]byte, 16)
+	if _, err = rand.Read(token); err != nil {
+		return
+	}
+
+	session.Share = hex.EncodeToString(token)
+
+	if err = s.save(session); err != nil {
+		return
+	}
+
+	s.shares[session.Share] = id
+	return
+}
+
+// Révoque le lien de partage de la session
+func (s *Store) Unshare(id string) (session *Session, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, err = s.get(id)
+	if err != nil {
+		return
+	}
+
+	token := session.Share
+	session.Share = ""
+
+	if err = s.save(session); err != nil {
+		return
+	}
+
+	delete(s.shares, token)
+	return
+}
+
+// Retourne la session partagée avec le jeton spécifié
+func (s *Store) Shared(token string) (*Session, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id, ok := s.shares[token]
+	if ok == false {
+		return nil, ErrNotFound
+	}
+
+	session, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lien révoqué en modifiant directement le fichier de la session
+	if session.Share != token {
+		return nil, ErrNotFound
+	}
+
+	return session, nil
+}
+
+func (s *Store) get(id string) (session *Session, err error) {
+
+	session, err = s.load(id)
+	if err != nil {
+		return
+	}
+
+	// Mise à jour des compteurs d'une session en cours
+	if recorder, ok := s.recorders[id]; ok {
+		session.Events = recorder.Count()
+		session.Dropped = recorder.Dropped()
+	}
+
+	return
+}
+
+func (s *Store) load(id string) (session *Session, err error) {
+
+	if validId.MatchString(id) == false {
+		return nil, ErrNotFound
+	}
+
+	data, err := ioutil.ReadFile(s.path(id, METADATA_FILE))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrNotFound
+		}
+		return
+	}
+
+	session = new(Session)
+	if err = json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("session %s: %s", id, err)
+	}
+
+	// Session interrompue sans avoir été arrêtée
+	if _, ok := s.recorders[id]; ok == false {
+		session.Recording = false
+	}
+
+	return
+}
+
+func (s *Store) save(session *Session) error {
+
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Ecriture atomique des informations
+	path := s.path(session.Id, METADATA_FILE)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *Store) path(id string, names ...string) string {
+	return filepath.Join(append([]string{s.dir, id}, names...)...)
+}
@@ -0,0 +1,443 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ohohleo/violin/metrics"
+)
+
+const (
+	// Délai avant redémarrage d'un composant, doublé à chaque échec
+	MIN_BACKOFF = 500 * time.Millisecond
+	MAX_BACKOFF = 30 * time.Second
+
+	// Durée de fonctionnement au delà de laquelle le délai est réinitialisé
+	STABLE_DURATION = time.Minute
+
+	// Durée accordée à chaque composant pour s'arrêter
+	STOP_TIMEOUT = 5 * time.Second
+)
+
+// Etats d'un composant
+const (
+	PENDING  = "pending"
+	STARTING = "starting"
+	RUNNING  = "running"
+	BACKOFF  = "backoff"
+	DONE     = "done"
+	FAILED   = "failed"
+	STOPPED  = "stopped"
+)
+
+type Component struct {
+	Name string
+
+	// Composants à démarrer avant celui-ci & à arrêter après
+	Requires []string
+
+	// Initialisation, les composants dépendants ne démarrent qu'une fois
+	// celle-ci terminée. Une erreur interrompt le démarrage.
+	Start func(ctx context.Context) error
+
+	// Fonctionnement jusqu'à l'annulation du contexte
+	Run func(ctx context.Context) error
+
+	// Libération des ressources, une fois Run terminé
+	Stop func() error
+
+	// Run est relancé après une erreur avec un délai croissant, sinon
+	// l'erreur arrête l'ensemble des composants
+	Restart bool
+
+	// Run est exécuté par la goroutine appelant Supervisor.Run, pour les
+	// affichages devant rester sur le thread principal. Sa fin arrête
+	// l'ensemble des composants.
+	Main bool
+
+	// Run se termine de lui-même : la fin de tous les composants finis
+	// arrête l'ensemble des composants
+	Finite bool
+}
+
+type component struct {
+	Component
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	state    string
+	restarts uint64
+}
+
+// Démarre les composants dans l'ordre de leurs dépendances, relance ceux
+// qui échouent & les arrête dans l'ordre inverse à l'annulation du contexte
+type Supervisor struct {
+	mutex      sync.Mutex
+	components []*component
+	names      map[string]*component
+
+	stopping chan struct{}
+	stopOnce sync.Once
+	err      error
+	finite   int
+}
+
+func New() *Supervisor {
+	return &Supervisor{
+		names:    make(map[string]*component),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (s *Supervisor) Add(c Component) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c.Name == "" {
+		return errors.New("component name required")
+	}
+
+	if _, ok := s.names[c.Name]; ok {
+		return fmt.Errorf("component '%s' already added", c.Name)
+	}
+
+	if c.Main {
+		for _, other := range s.components {
+			if other.Main {
+				return fmt.Errorf("component '%s' is already the main one", other.Name)
+			}
+		}
+	}
+
+	added := &component{
+		Component: c,
+		done:      make(chan struct{}),
+		state:     PENDING,
+	}
+
+	s.components = append(s.components, added)
+	s.names[c.Name] = added
+
+	return nil
+}
+
+// Ordre de démarrage respectant les dépendances, à défaut l'ordre d'ajout
+func (s *Supervisor) order() (order []*component, err error) {
+
+	visiting := make(map[*component]bool)
+	visited := make(map[*component]bool)
+
+	var visit func(c *component) error
+	visit = func(c *component) error {
+
+		if visited[c] {
+			return nil
+		}
+
+		if visiting[c] {
+			return fmt.Errorf("dependency cycle on component '%s'", c.Name)
+		}
+
+		visiting[c] = true
+
+		for _, name := range c.Requires {
+			required, ok := s.names[name]
+			if ok == false {
+				return fmt.Errorf("component '%s' requires unknown component '%s'", c.Name, name)
+			}
+
+			if err := visit(required); err != nil {
+				return err
+			}
+		}
+
+		visited[c] = true
+		order = append(order, c)
+
+		return nil
+	}
+
+	for _, c := range s.components {
+		if err = visit(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// Exécute les composants jusqu'à l'annulation du contexte, l'échec d'un
+// composant non relancé ou la fin des composants finis
+func (s *Supervisor) Run(ctx context.Context) error {
+
+	s.mutex.Lock()
+	order, err := s.order()
+	s.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// Comptés avant le démarrage pour qu'un composant fini rapidement
+	// n'arrête pas ceux qui ne sont pas encore démarrés
+	s.mutex.Lock()
+	for _, c := range order {
+		if c.Finite && c.Run != nil && c.Main == false {
+			s.finite++
+		}
+	}
+	s.mutex.Unlock()
+
+	var main *component
+	started := make([]*component, 0, len(order))
+
+	for _, c := range order {
+
+		// Chaque composant a son propre contexte pour être arrêté après
+		// ceux qui en dépendent
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+
+		s.setState(c, STARTING)
+
+		if c.Start != nil {
+			if err = protect(func() error { return c.Start(c.ctx) }); err != nil {
+				s.setState(c, FAILED)
+				c.cancel()
+				close(c.done)
+
+				// Le composant principal n'a pas encore été exécuté
+				if main != nil {
+					close(main.done)
+				}
+
+				s.shutdown(started)
+				return fmt.Errorf("%s: %w", c.Name, err)
+			}
+		}
+
+		started = append(started, c)
+
+		switch {
+		case c.Run == nil:
+			s.setState(c, RUNNING)
+			close(c.done)
+
+		case c.Main:
+			main = c
+
+		default:
+			go s.run(c)
+		}
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+		case <-s.stopping:
+		}
+
+		s.shutdown(started)
+	}()
+
+	if main != nil {
+		s.run(main)
+	}
+
+	<-stopped
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+// Demande l'arrêt de tous les composants
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() { close(s.stopping) })
+}
+
+func (s *Supervisor) run(c *component) {
+
+	defer close(c.done)
+
+	backoff := MIN_BACKOFF
+
+	for {
+		s.setState(c, RUNNING)
+
+		start := time.Now()
+		err := protect(func() error { return c.Run(c.ctx) })
+
+		if c.ctx.Err() != nil {
+			s.setState(c, STOPPED)
+			return
+		}
+
+		if err == nil {
+			s.setState(c, DONE)
+			s.finished(c)
+			return
+		}
+
+		log.Printf("%s: %s", c.Name, err)
+
+		if c.Restart == false {
+			s.setState(c, FAILED)
+			s.fail(fmt.Errorf("%s: %w", c.Name, err))
+			return
+		}
+
+		if time.Since(start) > STABLE_DURATION {
+			backoff = MIN_BACKOFF
+		}
+
+		s.mutex.Lock()
+		c.state = BACKOFF
+		c.restarts++
+		s.mutex.Unlock()
+
+		log.Printf("%s: restarting in %s", c.Name, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			s.setState(c, STOPPED)
+			return
+		}
+
+		if backoff *= 2; backoff > MAX_BACKOFF {
+			backoff = MAX_BACKOFF
+		}
+	}
+}
+
+// Une panique est traitée comme une erreur du composant
+func protect(f func() error) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return f()
+}
+
+func (s *Supervisor) finished(c *component) {
+
+	if c.Main {
+		s.Stop()
+		return
+	}
+
+	if c.Finite == false {
+		return
+	}
+
+	s.mutex.Lock()
+	s.finite--
+	last := s.finite == 0
+	s.mutex.Unlock()
+
+	if last {
+		s.Stop()
+	}
+}
+
+func (s *Supervisor) fail(err error) {
+
+	s.mutex.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mutex.Unlock()
+
+	s.Stop()
+}
+
+// Arrêt dans l'ordre inverse du démarrage : les composants dépendants sont
+// arrêtés avant ceux dont ils dépendent
+func (s *Supervisor) shutdown(started []*component) {
+
+	for idx := len(started) - 1; idx >= 0; idx-- {
+
+		c := started[idx]
+		c.cancel()
+
+		select {
+		case <-c.done:
+		case <-time.After(STOP_TIMEOUT):
+			log.Printf("%s: not stopped after %s", c.Name, STOP_TIMEOUT)
+		}
+
+		if c.Stop != nil {
+			if err := protect(c.Stop); err != nil {
+				log.Printf("%s: %s", c.Name, err)
+			}
+		}
+
+		s.mutex.Lock()
+		if c.state == RUNNING {
+			c.state = STOPPED
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Supervisor) setState(c *component, state string) {
+	s.mutex.Lock()
+	c.state = state
+	s.mutex.Unlock()
+}
+
+// Etat de chaque composant
+func (s *Supervisor) States() map[string]string {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	states := make(map[string]string, len(s.components))
+	for _, c := range s.components {
+		states[c.Name] = c.state
+	}
+
+	return states
+}
+
+// Etat & nombre de redémarrages des composants
+func (s *Supervisor) Metrics() metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		up := metrics.NewGauge("violin_component_up",
+			"Whether the component is running")
+		restarts := metrics.NewCounter("violin_component_restarts_total",
+			"Restarts of the component after a failure")
+
+		s.mutex.Lock()
+		for _, c := range s.components {
+
+			value := 0.0
+			if c.state == RUNNING {
+				value = 1
+			}
+
+			up.Add(value, "component", c.Name)
+			restarts.Add(float64(c.restarts), "component", c.Name)
+		}
+		s.mutex.Unlock()
+
+		return []*metrics.Metric{up, restarts}
+	}
+}
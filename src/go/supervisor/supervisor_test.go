@@ -0,0 +1,346 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const TIMEOUT = 5 * time.Second
+
+// Etapes des composants dans l'ordre où elles se produisent
+type journal struct {
+	mutex sync.Mutex
+	steps []string
+}
+
+func (j *journal) add(step string) {
+	j.mutex.Lock()
+	j.steps = append(j.steps, step)
+	j.mutex.Unlock()
+}
+
+func (j *journal) list() []string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return append([]string(nil), j.steps...)
+}
+
+// Composant notant son démarrage & son arrêt, fonctionnant jusqu'à
+// l'annulation de son contexte
+func recorded(j *journal, name string, requires ...string) Component {
+	return Component{
+		Name:     name,
+		Requires: requires,
+		Start: func(ctx context.Context) error {
+			j.add("start " + name)
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+		Stop: func() error {
+			j.add("stop " + name)
+			return nil
+		},
+	}
+}
+
+// Exécute le superviseur en arrière-plan, son résultat étant transmis à la
+// fin
+func run(ctx context.Context, s *Supervisor) <-chan error {
+
+	result := make(chan error, 1)
+	go func() { result <- s.Run(ctx) }()
+
+	return result
+}
+
+func wait(t *testing.T, result <-chan error) error {
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(TIMEOUT):
+		t.Fatal("supervisor not stopped")
+	}
+
+	return nil
+}
+
+func TestSupervisorOrder(t *testing.T) {
+
+	j := new(journal)
+	s := New()
+
+	// Ajoutés avant leurs dépendances
+	for _, c := range []Component{
+		recorded(j, "view", "devices"),
+		recorded(j, "devices", "osc", "mqtt"),
+		recorded(j, "osc"),
+		recorded(j, "mqtt"),
+	} {
+		if err := s.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := run(ctx, s)
+
+	deadline := time.Now().Add(TIMEOUT)
+	for s.States()["view"] != RUNNING && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := wait(t, result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"start osc", "start mqtt", "start devices", "start view",
+		"stop view", "stop devices", "stop mqtt", "stop osc",
+	}
+
+	if steps := j.list(); reflect.DeepEqual(steps, expected) == false {
+		t.Errorf("steps %v, expect %v", steps, expected)
+	}
+
+	for name, state := range s.States() {
+		if state != STOPPED {
+			t.Errorf("%s: state %s, expect %s", name, state, STOPPED)
+		}
+	}
+}
+
+func TestSupervisorDependencies(t *testing.T) {
+
+	for _, test := range []struct {
+		name       string
+		components []Component
+		err        string
+	}{
+		{
+			"unknown",
+			[]Component{{Name: "a", Requires: []string{"b"}}},
+			"requires unknown component 'b'",
+		},
+		{
+			"cycle",
+			[]Component{
+				{Name: "a", Requires: []string{"b"}},
+				{Name: "b", Requires: []string{"a"}},
+			},
+			"dependency cycle",
+		},
+	} {
+		s := New()
+
+		for _, c := range test.components {
+			if err := s.Add(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := s.Run(context.Background()); err == nil || strings.Contains(err.Error(), test.err) == false {
+			t.Errorf("%s: error %v, expect %s", test.name, err, test.err)
+		}
+	}
+
+	s := New()
+	s.Add(Component{Name: "a"})
+
+	if err := s.Add(Component{Name: "a"}); err == nil {
+		t.Error("component added twice")
+	}
+
+	s.Add(Component{Name: "main", Main: true})
+
+	if err := s.Add(Component{Name: "other", Main: true}); err == nil {
+		t.Error("two main components accepted")
+	}
+}
+
+// Délai doublé à chaque échec, le composant relancé restant en place
+func TestSupervisorRestart(t *testing.T) {
+
+	var mutex sync.Mutex
+	var runs []time.Time
+
+	s := New()
+	s.Add(Component{
+		Name:    "devices",
+		Restart: true,
+		Run: func(ctx context.Context) error {
+
+			mutex.Lock()
+			runs = append(runs, time.Now())
+			count := len(runs)
+			mutex.Unlock()
+
+			if count <= 2 {
+				return errors.New("device disconnected")
+			}
+
+			<-ctx.Done()
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := run(ctx, s)
+
+	deadline := time.Now().Add(TIMEOUT)
+	for {
+		mutex.Lock()
+		count := len(runs)
+		mutex.Unlock()
+
+		if count == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if state := s.States()["devices"]; state != RUNNING {
+		t.Errorf("state %s, expect %s", state, RUNNING)
+	}
+
+	cancel()
+	if err := wait(t, result); err != nil {
+		t.Fatalf("error %s, expect none after restarts", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(runs) != 3 {
+		t.Fatalf("%d runs, expect 3", len(runs))
+	}
+
+	for idx, backoff := range []time.Duration{MIN_BACKOFF, 2 * MIN_BACKOFF} {
+		if delay := runs[idx+1].Sub(runs[idx]); delay < backoff {
+			t.Errorf("restart %d after %s, expect at least %s", idx+1, delay, backoff)
+		}
+	}
+
+	if restarts := s.names["devices"].restarts; restarts != 2 {
+		t.Errorf("%d restarts, expect 2", restarts)
+	}
+}
+
+// Un échec non relancé ou une panique arrête tous les composants
+func TestSupervisorFailure(t *testing.T) {
+
+	for _, test := range []struct {
+		name string
+		run  func(ctx context.Context) error
+		err  string
+	}{
+		{
+			"error",
+			func(ctx context.Context) error { return errors.New("broken") },
+			"output: broken",
+		},
+		{
+			"panic",
+			func(ctx context.Context) error { panic("broken") },
+			"output: panic: broken",
+		},
+	} {
+		j := new(journal)
+		s := New()
+
+		s.Add(recorded(j, "other"))
+		s.Add(Component{Name: "output", Requires: []string{"other"}, Run: test.run})
+
+		err := wait(t, run(context.Background(), s))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, expect %s", test.name, err, test.err)
+		}
+
+		if steps := j.list(); reflect.DeepEqual(steps, []string{"start other", "stop other"}) == false {
+			t.Errorf("%s: steps %v", test.name, steps)
+		}
+
+		if state := s.States()["output"]; state != FAILED {
+			t.Errorf("%s: state %s, expect %s", test.name, state, FAILED)
+		}
+	}
+}
+
+// Un échec au démarrage arrête les composants déjà démarrés
+func TestSupervisorStartFailure(t *testing.T) {
+
+	j := new(journal)
+	s := New()
+
+	s.Add(recorded(j, "osc"))
+	s.Add(Component{
+		Name:     "devices",
+		Requires: []string{"osc"},
+		Start: func(ctx context.Context) error {
+			return errors.New("no such port")
+		},
+	})
+	s.Add(recorded(j, "view", "devices"))
+
+	if err := s.Run(context.Background()); err == nil || err.Error() != "devices: no such port" {
+		t.Errorf("error %v, expect devices: no such port", err)
+	}
+
+	if steps := j.list(); reflect.DeepEqual(steps, []string{"start osc", "stop osc"}) == false {
+		t.Errorf("steps %v, expect osc started & stopped", steps)
+	}
+
+	if state := s.States()["view"]; state != PENDING {
+		t.Errorf("view state %s, expect %s", state, PENDING)
+	}
+}
+
+// La fin de tous les composants finis arrête les autres
+func TestSupervisorFinite(t *testing.T) {
+
+	j := new(journal)
+	s := New()
+
+	s.Add(recorded(j, "output"))
+
+	for _, name := range []string{"replay", "export"} {
+		delay := 10 * time.Millisecond
+		if name == "export" {
+			delay = 100 * time.Millisecond
+		}
+
+		s.Add(Component{
+			Name:     name,
+			Requires: []string{"output"},
+			Finite:   true,
+			Run: func(ctx context.Context) error {
+				time.Sleep(delay)
+				return nil
+			},
+		})
+	}
+
+	start := time.Now()
+	if err := wait(t, run(context.Background(), s)); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("stopped after %s, before the last finite component", elapsed)
+	}
+
+	states := s.States()
+	if states["replay"] != DONE || states["export"] != DONE || states["output"] != STOPPED {
+		t.Errorf("states %v", states)
+	}
+}
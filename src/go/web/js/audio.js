@@ -0,0 +1,36 @@
+// Affichage de l'analyse audio diffusée par /stream/audio
+var audioSource = new EventSource(authUrl('stream/audio'));
+
+audioSource.addEventListener('pitch', function(e) {
+    var pitch = JSON.parse(e.data);
+    var element = document.getElementById('pitch');
+
+    if (pitch.note) {
+        var cents = pitch.note.cents.toFixed(0);
+        element.textContent = pitch.note.name + pitch.note.octave + ' '
+            + (cents > 0 ? '+' : '') + cents + ' cents ('
+            + pitch.frequency.toFixed(1) + ' Hz)';
+    } else {
+        element.textContent = pitch.frequency.toFixed(1) + ' Hz';
+    }
+}, false);
+
+audioSource.addEventListener('level', function(e) {
+    var level = JSON.parse(e.data);
+    document.getElementById('level').value = Math.max(0, 120 + level.db);
+}, false);
+
+audioSource.addEventListener('spectrum', function(e) {
+    var spectrum = JSON.parse(e.data);
+    var canvas = document.getElementById('spectrum');
+    var context = canvas.getContext('2d');
+    var width = canvas.width / spectrum.bands.length;
+
+    context.clearRect(0, 0, canvas.width, canvas.height);
+    context.fillStyle = 'rgb(255, 0, 0)';
+
+    spectrum.bands.forEach(function(db, idx) {
+        var height = canvas.height * Math.max(0, 120 + db) / 120;
+        context.fillRect(idx * width, canvas.height - height, width, height);
+    });
+}, false);
@@ -0,0 +1,16 @@
+// Transmet le jeton ou le lien de partage présent dans l'adresse de la page
+// aux flux de données, EventSource & WebSocket ne permettant pas d'ajouter
+// l'entête Authorization
+function authUrl(url) {
+    var params = new URLSearchParams(window.location.search);
+
+    ['access_token', 'share'].forEach(function(name) {
+        var value = params.get(name);
+        if (value) {
+            url += (url.indexOf('?') < 0 ? '?' : '&')
+                + name + '=' + encodeURIComponent(value);
+        }
+    });
+
+    return url;
+}
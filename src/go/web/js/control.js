@@ -0,0 +1,64 @@
+// Client du protocole WebSocket décrit dans api/websocket.go
+function Control(url) {
+    var self = this;
+
+    // Même serveur que la page par défaut
+    if (url === undefined) {
+        var protocol = window.location.protocol == 'https:' ? 'wss:' : 'ws:';
+        url = protocol + '//' + window.location.host + '/ws';
+    }
+
+    this.lastId = 0;
+    this.pending = {};
+    this.handlers = {};
+
+    this.socket = new WebSocket(authUrl(url));
+
+    this.socket.onmessage = function(e) {
+        var message = JSON.parse(e.data);
+
+        switch (message.type) {
+        case 'event':
+            var handler = self.handlers[message.event];
+            if (handler) {
+                handler(message.data, message);
+            }
+            break;
+
+        case 'response':
+        case 'error':
+            var callback = self.pending[message.id];
+            if (callback) {
+                delete self.pending[message.id];
+                callback(message.error, message.result);
+            }
+            break;
+
+        case 'heartbeat':
+            if (message.dropped) {
+                console.log("DROPPED " + message.dropped);
+            }
+            break;
+        }
+    };
+}
+
+Control.prototype.send = function(message, callback) {
+    message.id = ++this.lastId;
+    if (callback) {
+        this.pending[message.id] = callback;
+    }
+    this.socket.send(JSON.stringify(message));
+};
+
+Control.prototype.command = function(command, args, callback) {
+    this.send({type: 'command', command: command, args: args || []}, callback);
+};
+
+Control.prototype.subscribe = function(types, callback) {
+    this.send({type: 'subscribe', types: types}, callback);
+};
+
+Control.prototype.on = function(event, handler) {
+    this.handlers[event] = handler;
+};
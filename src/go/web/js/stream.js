@@ -0,0 +1,28 @@
+console.log("STREAM");
+
+var source = new EventSource(authUrl('stream/accelerometer'));
+
+source.addEventListener('sample', function(e) {
+    var sample = JSON.parse(e.data);
+    console.log(e.lastEventId, sample);
+}, false);
+
+source.addEventListener('status', function(e) {
+    console.log("STATUS", JSON.parse(e.data));
+}, false);
+
+source.addEventListener('stroke', function(e) {
+    var stroke = JSON.parse(e.data);
+    console.log("STROKE", stroke.Direction, stroke.Speed);
+}, false);
+
+source.addEventListener('open', function(e) {
+    console.log("OPEN!");
+}, false);
+
+source.addEventListener('error', function(e) {
+    console.log("ERROR! " + source.readyState);
+    if (source.readyState == EventSource.CLOSED) {
+        // Connection was closed.
+    }
+}, false);
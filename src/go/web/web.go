@@ -0,0 +1,27 @@
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Pages web embarquées dans le binaire
+//
+//go:embed index.html js
+var files embed.FS
+
+// Retourne les pages web embarquées ou celles du répertoire spécifié, ce qui
+// permet de les modifier sans recompiler pendant le développement
+func FileSystem(dir string) http.FileSystem {
+
+	if dir != "" {
+		return http.Dir(dir)
+	}
+
+	return http.FS(files)
+}
+
+func Files() fs.FS {
+	return files
+}
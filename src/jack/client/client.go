@@ -1,10 +1,13 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/ohohleo/go-dsp/fft"
@@ -13,13 +16,33 @@ import (
 
 // Use global ports to avoid CGO issue
 var portsIn []*jack.Port
-var mutexIn *sync.Mutex
+var mutexIn sync.Mutex
 var onInput func([]jack.AudioSample)
 
 var portsOut []*jack.Port
-var mutexOut *sync.Mutex
+var mutexOut sync.Mutex
 var onOutput func([]jack.AudioSample)
 
+var onShutdown func()
+
+// Returned by Start when the JACK server goes away
+var ErrServerShutdown = errors.New("JACK server shut down")
+
+// Health counters, updated from the JACK callbacks
+var processCount uint64
+var processNanos uint64
+var processMaxNanos uint64
+var xruns uint64
+var fftPending int64
+
+type Stats struct {
+	ProcessCount      uint64
+	ProcessSeconds    float64
+	ProcessMaxSeconds float64
+	Xruns             uint64
+	FFTQueue          int64
+}
+
 type Client struct {
 	client *jack.Client
 	name   string
@@ -36,7 +59,16 @@ type Client struct {
 	outFFTChan   chan []complex128
 	outFFTBuffer []float64
 
-	stopChan chan struct{}
+	// Closed by Stop: pending sends are abandoned
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// Closed when the JACK server shuts down
+	lost     chan struct{}
+	lostOnce sync.Once
+
+	// FFT computations in progress
+	fftWait sync.WaitGroup
 }
 
 func New(name string, links map[string]string) (c *Client, err error) {
@@ -51,6 +83,8 @@ func New(name string, links map[string]string) (c *Client, err error) {
 
 	c = &Client{
 		links: links,
+		done:  make(chan struct{}),
+		lost:  make(chan struct{}),
 	}
 
 	var status int
@@ -66,6 +100,13 @@ func New(name string, links map[string]string) (c *Client, err error) {
 		return
 	}
 
+	status = c.client.SetXRunCallback(onXRun)
+	if err = jack.Strerror(status); err != nil {
+		err = fmt.Errorf("xrun callback issue %s", err.Error())
+		return
+	}
+
+	onShutdown = c.onShutdown
 	c.client.OnShutdown(shutdown)
 
 	c.name = name
@@ -130,37 +171,48 @@ func (c *Client) Start() (err error) {
 	log.Println("START " + c.name)
 
 	// Wait until stop
-	c.stopChan = make(chan struct{})
-	<-c.stopChan
+	select {
+	case <-c.done:
+	case <-c.lost:
+		err = ErrServerShutdown
+	}
 
 	return
 }
 
+// Stop the processing & close the client, the input & output channels are
+// closed once no callback can send on them anymore
 func (c *Client) Stop() (err error) {
 
+	stopped := true
+	c.stopOnce.Do(func() { stopped = false })
+	if stopped {
+		return
+	}
+
 	log.Println("STOP " + c.name)
 
-	// Stop channels
-	if c.inChan != nil {
+	// Unblock the callbacks & the FFT computations waiting for a reader
+	close(c.done)
 
-		mutexIn.Lock()
-		onInput = nil
-		mutexIn.Unlock()
+	mutexIn.Lock()
+	onInput = nil
+	mutexIn.Unlock()
+
+	mutexOut.Lock()
+	onOutput = nil
+	mutexOut.Unlock()
+
+	c.fftWait.Wait()
 
+	if c.inChan != nil {
 		close(c.inChan)
+		close(c.inFFTChan)
 	}
 
 	if c.outChan != nil {
-
-		mutexOut.Lock()
-		onOutput = nil
-		mutexOut.Unlock()
-
 		close(c.outChan)
-	}
-
-	if c.stopChan != nil {
-		close(c.stopChan)
+		close(c.outFFTChan)
 	}
 
 	// Establish disconnect
@@ -175,25 +227,35 @@ func (c *Client) Stop() (err error) {
 	return jack.Strerror(c.client.Close())
 }
 
+// Sample rate of the JACK server
+func (c *Client) SampleRate() float64 {
+	return float64(c.client.GetSampleRate())
+}
+
 func (c *Client) GetInput() (chan []float32, chan []complex128) {
 
 	c.inChan = make(chan []float32)
 	c.inFFTChan = make(chan []complex128)
-	mutexIn = new(sync.Mutex)
+
+	mutexIn.Lock()
 	onInput = c.onInput
+	mutexIn.Unlock()
 
 	return c.inChan, c.inFFTChan
 }
 
 func (c *Client) onInput(samples []jack.AudioSample) {
 
-	// Convert []AudioSample => []float32
-	values := *(*[]float32)(unsafe.Pointer(&samples))
-	go handleFFT(values, c.inFFTChan)
+	// Convert []AudioSample => []float32, copied as JACK reuses its buffers
+	values := make([]float32, len(samples))
+	copy(values, *(*[]float32)(unsafe.Pointer(&samples)))
+	c.fftWait.Add(1)
+	atomic.AddInt64(&fftPending, 1)
+	go c.handleFFT(values, c.inFFTChan)
 	// Send raw values
 	select {
 	case c.inChan <- values:
-		return
+	case <-c.done:
 	}
 }
 
@@ -201,25 +263,33 @@ func (c *Client) GetOutput() (chan []float32, chan []complex128) {
 
 	c.outChan = make(chan []float32)
 	c.outFFTChan = make(chan []complex128)
-	mutexOut = new(sync.Mutex)
+
+	mutexOut.Lock()
 	onOutput = c.onOutput
+	mutexOut.Unlock()
 
 	return c.outChan, c.outFFTChan
 }
 
 func (c *Client) onOutput(samples []jack.AudioSample) {
 
-	// Convert []AudioSample => []float32
-	values := *(*[]float32)(unsafe.Pointer(&samples))
-	go handleFFT(values, c.outFFTChan)
+	// Convert []AudioSample => []float32, copied as JACK reuses its buffers
+	values := make([]float32, len(samples))
+	copy(values, *(*[]float32)(unsafe.Pointer(&samples)))
+	c.fftWait.Add(1)
+	atomic.AddInt64(&fftPending, 1)
+	go c.handleFFT(values, c.outFFTChan)
 	// Send raw values
 	select {
 	case c.outChan <- values:
-		return
+	case <-c.done:
 	}
 }
 
-func handleFFT(values []float32, fftChan chan []complex128) {
+func (c *Client) handleFFT(values []float32, fftChan chan []complex128) {
+
+	defer c.fftWait.Done()
+	defer atomic.AddInt64(&fftPending, -1)
 
 	// Convert into 64 bits
 	inputs := make([]float64, len(values))
@@ -230,26 +300,66 @@ func handleFFT(values []float32, fftChan chan []complex128) {
 	// Calculate FFT
 	select {
 	case fftChan <- fft.FFTReal(inputs):
-		return
+	case <-c.done:
+	}
+}
+
+// Process callback duration, xruns and FFT computations waiting to be consumed
+func (c *Client) Stats() Stats {
+	return Stats{
+		ProcessCount:      atomic.LoadUint64(&processCount),
+		ProcessSeconds:    time.Duration(atomic.LoadUint64(&processNanos)).Seconds(),
+		ProcessMaxSeconds: time.Duration(atomic.LoadUint64(&processMaxNanos)).Seconds(),
+		Xruns:             atomic.LoadUint64(&xruns),
+		FFTQueue:          atomic.LoadInt64(&fftPending),
 	}
 }
 
+func onXRun() int {
+	atomic.AddUint64(&xruns, 1)
+	return 0
+}
+
 func shutdown() {
 	fmt.Println("Shutting down")
+
+	if onShutdown != nil {
+		onShutdown()
+	}
+}
+
+func (c *Client) onShutdown() {
+	c.lostOnce.Do(func() { close(c.lost) })
 }
 
 func onProcess(framesNb uint32) int {
 
+	start := time.Now()
+	defer func() {
+		elapsed := uint64(time.Since(start))
+
+		atomic.AddUint64(&processCount, 1)
+		atomic.AddUint64(&processNanos, elapsed)
+
+		for {
+			max := atomic.LoadUint64(&processMaxNanos)
+			if elapsed <= max ||
+				atomic.CompareAndSwapUint64(&processMaxNanos, max, elapsed) {
+				break
+			}
+		}
+	}()
+
 	for portIdx, in := range portsIn {
 
 		// Get samples input
 		samplesIn := in.GetBuffer(framesNb)
 
+		mutexIn.Lock()
 		if onInput != nil {
-			mutexIn.Lock()
 			onInput(samplesIn)
-			mutexIn.Unlock()
 		}
+		mutexIn.Unlock()
 
 		// Get samples output
 		samplesOut := portsOut[portIdx].GetBuffer(framesNb)
@@ -259,11 +369,11 @@ func onProcess(framesNb uint32) int {
 			samplesOut[idx] = sample
 		}
 
+		mutexOut.Lock()
 		if onOutput != nil {
-			mutexOut.Lock()
 			onOutput(samplesOut)
-			mutexOut.Unlock()
 		}
+		mutexOut.Unlock()
 	}
 
 	return 0 // no error
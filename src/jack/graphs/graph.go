@@ -187,6 +187,21 @@ func (g *Graph) AddToSystem(system *common.RenderSystem) {
 
 }
 
+func (g *Graph) SetHidden(hidden bool) {
+
+	if g.horizontalBar != nil {
+		g.horizontalBar.Hidden = hidden
+	}
+
+	for _, text := range g.texts {
+		text.Hidden = hidden
+	}
+
+	for _, bar := range g.bars {
+		bar.Hidden = hidden
+	}
+}
+
 type Bar struct {
 	ecs.BasicEntity
 	common.RenderComponent
@@ -1,20 +1,59 @@
 package graphs
 
 import (
+	"fmt"
 	"image/color"
+	"sync/atomic"
 
 	"engo.io/ecs"
 	"engo.io/engo/common"
 )
 
+// Graphes pouvant être affichés
+const (
+	GRAPH_ALL         = "all"
+	GRAPH_WAVEFORM    = "waveform"
+	GRAPH_SPECTRUM    = "spectrum"
+	GRAPH_SPECTROGRAM = "spectrogram"
+)
+
+var GRAPHS = []string{GRAPH_ALL, GRAPH_WAVEFORM, GRAPH_SPECTRUM, GRAPH_SPECTROGRAM}
+
+type hideable interface {
+	SetHidden(hidden bool)
+}
+
 type Scene struct {
 	values    map[string]chan []float32
 	fftValues map[string]chan []float32
 	height    float32
 	width     float32
 	font      *common.Font
+
+	// Graphe demandé par les commandes, appliqué par la boucle d'engo qui
+	// seule modifie les entités
+	wanted atomic.Value
+
+	// Graphe affiché & graphes par type, utilisés par la boucle d'engo
+	shown  string
+	graphs map[string][]hideable
 }
 
+// Applique le graphe demandé à l'image suivante
+type visibilitySystem struct {
+	scene *Scene
+}
+
+func (v *visibilitySystem) Update(dt float32) {
+
+	if wanted := v.scene.wanted.Load().(string); wanted != v.scene.shown {
+		v.scene.shown = wanted
+		v.scene.updateVisibility()
+	}
+}
+
+func (*visibilitySystem) Remove(ecs.BasicEntity) {}
+
 func NewScene(defaultFontPath string,
 	values map[string]chan []float32,
 	fftValues map[string]chan []float32,
@@ -24,19 +63,55 @@ func NewScene(defaultFontPath string,
 		fftValues: fftValues,
 		height:    height,
 		width:     width,
+		shown:     GRAPH_ALL,
+		graphs:    make(map[string][]hideable),
 		font: &common.Font{
-			URL:  "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+			URL:  defaultFontPath,
 			FG:   color.Black,
 			Size: 64,
 		},
 	}
 
+	scene.wanted.Store(GRAPH_ALL)
+
 	err = scene.font.CreatePreloaded()
 	return
 }
 
 func (*Scene) Type() string { return "Graph" }
 
+// Affiche uniquement le type de graphe spécifié, ou tous les graphes, à
+// l'image suivante. Peut être appelé depuis n'importe quelle goroutine.
+func (s *Scene) Show(kind string) error {
+
+	valid := false
+	for _, graph := range GRAPHS {
+		if graph == kind {
+			valid = true
+		}
+	}
+
+	if valid == false {
+		return fmt.Errorf("unknown graph '%s'", kind)
+	}
+
+	s.wanted.Store(kind)
+
+	return nil
+}
+
+func (s *Scene) updateVisibility() {
+	for kind, graphs := range s.graphs {
+		for _, graph := range graphs {
+			graph.SetHidden(s.shown != GRAPH_ALL && s.shown != kind)
+		}
+	}
+}
+
+func (s *Scene) add(kind string, graph hideable) {
+	s.graphs[kind] = append(s.graphs[kind], graph)
+}
+
 func (*Scene) Preload() {}
 
 func (s *Scene) Setup(world *ecs.World) {
@@ -56,6 +131,7 @@ func (s *Scene) Setup(world *ecs.World) {
 			height*float32(idx)+height/2, 0.6, 512, s.font)
 		go graph.GraphRaw(values)
 		graph.AddToSystem(renderSystem)
+		s.add(GRAPH_WAVEFORM, graph)
 
 		world.AddSystem(graph)
 
@@ -69,11 +145,13 @@ func (s *Scene) Setup(world *ecs.World) {
 			2, height*float32(idx)+height, 0.6, 512, s.font)
 		graph.AddToSystem(renderSystem)
 		world.AddSystem(graph)
+		s.add(GRAPH_SPECTRUM, graph)
 
 		// Init Spectrogram
 		spectrogram := NewSpectrogram(name, 0, height*float32(idx+1), s.width, height)
 		spectrogram.AddToSystem(renderSystem)
 		world.AddSystem(spectrogram)
+		s.add(GRAPH_SPECTROGRAM, spectrogram)
 
 		// Display everything
 		go func() {
@@ -91,5 +169,9 @@ func (s *Scene) Setup(world *ecs.World) {
 		idx++
 	}
 
+	s.shown = s.wanted.Load().(string)
+	s.updateVisibility()
+
+	world.AddSystem(&visibilitySystem{scene: s})
 	world.AddSystem(renderSystem)
 }
@@ -61,6 +61,10 @@ func (s *Spectrogram) AddToSystem(system *common.RenderSystem) {
 	system.Add(&s.BasicEntity, &s.RenderComponent, &s.SpaceComponent)
 }
 
+func (s *Spectrogram) SetHidden(hidden bool) {
+	s.Hidden = hidden
+}
+
 func (s *Spectrogram) AddSamples(samples []float32) {
 
 	colors := make([]color.NRGBA, len(samples))
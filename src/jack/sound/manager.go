@@ -0,0 +1,402 @@
+package sound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/cmplx"
+	"sync"
+	"time"
+
+	"engo.io/engo"
+	"github.com/ohohleo/violin/audio"
+	"github.com/ohohleo/violin/events"
+	"github.com/ohohleo/violin/jack/client"
+	"github.com/ohohleo/violin/jack/graphs"
+	"github.com/ohohleo/violin/metrics"
+)
+
+const (
+	DEFAULT_WIDTH  = 1000
+	DEFAULT_HEIGHT = 800
+	DEFAULT_FONT   = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+
+	// Minimum delay between two published spectrums
+	SPECTRUM_INTERVAL = 100 * time.Millisecond
+)
+
+// Display & analysis settings, zero values are replaced by the defaults
+type Options struct {
+	// Show the graphs window
+	Display bool
+	Width   int
+	Height  int
+	Font    string
+
+	// Pitch detection thresholds (see audio.Analyzer)
+	MinLevel   float64
+	MinClarity float64
+
+	SpectrumInterval time.Duration
+}
+
+// JACK client feeding the analysis & the graphs display
+type SoundManager struct {
+	mutex   sync.Mutex
+	client  *client.Client
+	options Options
+
+	hub   *events.Hub
+	tuner *audio.Tuner
+
+	inDisplay    chan []float32
+	inDisplayFFT chan []complex128
+
+	sceneMutex sync.Mutex
+	scene      *graphs.Scene
+}
+
+func NewSoundManager(options Options) (s *SoundManager) {
+
+	if options.Width <= 0 || options.Height <= 0 {
+		options.Width, options.Height = DEFAULT_WIDTH, DEFAULT_HEIGHT
+	}
+
+	if options.Font == "" {
+		options.Font = DEFAULT_FONT
+	}
+
+	if options.MinLevel == 0 {
+		options.MinLevel = audio.MIN_LEVEL
+	}
+
+	if options.MinClarity == 0 {
+		options.MinClarity = audio.MIN_CLARITY
+	}
+
+	if options.SpectrumInterval == 0 {
+		options.SpectrumInterval = SPECTRUM_INTERVAL
+	}
+
+	s = &SoundManager{
+		options: options,
+	}
+
+	return
+}
+
+// Publish the input analysis (level, pitch, spectrum) on the hub
+func (s *SoundManager) SetAnalysis(hub *events.Hub, tuner *audio.Tuner) {
+	s.hub = hub
+	s.tuner = tuner
+}
+
+// Connect the links & process the input until Stop is called, the JACK
+// server shuts down or the display is closed. With the display, must be
+// called from the main goroutine.
+func (s *SoundManager) Start(name string, links map[string]string) error {
+	return s.start(context.Background(), name, links)
+}
+
+// Start until the context is cancelled
+func (s *SoundManager) Run(ctx context.Context, name string, links map[string]string) error {
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := s.Stop(); err != nil {
+				log.Println(err)
+			}
+		case <-stopped:
+		}
+	}()
+
+	return s.start(ctx, name, links)
+}
+
+func (s *SoundManager) start(ctx context.Context, name string, links map[string]string) (err error) {
+
+	c, err := client.New(name, links)
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.client = c
+	s.mutex.Unlock()
+
+	// Cancelled before Stop could see the client
+	if ctx.Err() != nil {
+		return c.Stop()
+	}
+
+	// Closed once the display no longer reads its channels
+	displayDone := make(chan struct{})
+
+	if s.options.Display || s.hub != nil {
+		s.dispatchInput(displayDone)
+	}
+
+	if s.options.Display == false {
+		close(displayDone)
+
+		// Released as well after an activation failure or a server shutdown
+		err = c.Start()
+		c.Stop()
+		return
+	}
+
+	// The display is closed when the client stops
+	result := make(chan error, 1)
+	go func() {
+		err := c.Start()
+		s.StopDisplay()
+		result <- err
+	}()
+
+	// Can't use display inside go routine
+	displayErr := s.StartDisplay(displayDone)
+	close(displayDone)
+
+	if err = c.Stop(); err != nil {
+		log.Println(err)
+	}
+
+	if err = <-result; err == nil {
+		err = displayErr
+	}
+
+	return
+}
+
+// Stop the client, the pending sends to the analysis & the display are
+// abandoned
+func (s *SoundManager) Stop() (err error) {
+
+	s.mutex.Lock()
+	c := s.client
+	s.mutex.Unlock()
+
+	if c == nil {
+		return
+	}
+
+	return c.Stop()
+}
+
+func (s *SoundManager) StartDisplay(done chan struct{}) error {
+
+	// Load font
+	fontPath := s.options.Font
+	if err := engo.Files.Load(fontPath); err != nil {
+		return err
+	}
+
+	// output, outputFFT := s.client.GetOutput()
+
+	inDisplayFFT := make(chan []float32)
+	go handleFFT(s.inDisplayFFT, inDisplayFFT, done, false)
+
+	// outDisplayFFT := make(chan []float32)
+	//go handleFFT(outputFFT, outDisplayFFT, false)
+
+	scene, err := graphs.NewScene(
+		fontPath,
+		map[string]chan []float32{
+			"input": s.inDisplay,
+			//"output": output,
+		},
+		map[string]chan []float32{
+			"input": inDisplayFFT,
+			//"output": outDisplayFFT,
+		},
+		float32(s.options.Width), float32(s.options.Height))
+	if err != nil {
+		return err
+	}
+
+	s.sceneMutex.Lock()
+	s.scene = scene
+	s.sceneMutex.Unlock()
+
+	engo.Run(engo.RunOptions{
+		Title:  "Graph",
+		Width:  s.options.Width,
+		Height: s.options.Height,
+	}, scene)
+
+	return nil
+}
+
+func (s *SoundManager) StopDisplay() {
+	engo.Exit()
+}
+
+// Select the displayed graph (see graphs.GRAPHS), applied by the render loop
+// on the next frame
+func (s *SoundManager) ShowGraph(kind string) error {
+
+	s.sceneMutex.Lock()
+	defer s.sceneMutex.Unlock()
+
+	if s.scene == nil {
+		return errors.New("display not started")
+	}
+
+	return s.scene.Show(kind)
+}
+
+// JACK client health counters
+func (s *SoundManager) Metrics() metrics.Collector {
+
+	return func() []*metrics.Metric {
+
+		s.mutex.Lock()
+		c := s.client
+		s.mutex.Unlock()
+
+		if c == nil {
+			return nil
+		}
+
+		stats := c.Stats()
+
+		return []*metrics.Metric{
+			metrics.NewCounter("violin_jack_process_callbacks_total",
+				"JACK process callbacks").Add(float64(stats.ProcessCount)),
+			metrics.NewCounter("violin_jack_process_seconds_total",
+				"Time spent in the JACK process callback").Add(stats.ProcessSeconds),
+			metrics.NewGauge("violin_jack_process_max_seconds",
+				"Longest JACK process callback").Add(stats.ProcessMaxSeconds),
+			metrics.NewCounter("violin_jack_xruns_total",
+				"JACK buffer overruns and underruns").Add(float64(stats.Xruns)),
+			metrics.NewGauge("violin_jack_fft_queue_depth",
+				"FFT computations waiting to be consumed").Add(float64(stats.FFTQueue)),
+		}
+	}
+}
+
+// Share the input between the display and the analysis
+func (s *SoundManager) dispatchInput(displayDone chan struct{}) {
+
+	input, inputFFT := s.client.GetInput()
+
+	if s.options.Display {
+		s.inDisplay = make(chan []float32)
+		s.inDisplayFFT = make(chan []complex128)
+	}
+
+	sampleRate := s.client.SampleRate()
+
+	var analyzer *audio.Analyzer
+	if s.hub != nil {
+		analyzer = audio.NewAnalyzer(sampleRate, s.tuner)
+		analyzer.MinLevel = s.options.MinLevel
+		analyzer.MinClarity = s.options.MinClarity
+	}
+
+	go func() {
+		for values := range input {
+
+			if analyzer != nil {
+				analysis := analyzer.Process(values, nil)
+
+				s.hub.Publish(events.LEVEL, analysis.Level)
+				if analysis.Pitch != nil {
+					s.hub.Publish(events.PITCH, analysis.Pitch)
+				}
+			}
+
+			if s.inDisplay != nil {
+				select {
+				case s.inDisplay <- values:
+				case <-displayDone:
+				}
+			}
+		}
+
+		if s.inDisplay != nil {
+			close(s.inDisplay)
+		}
+	}()
+
+	go func() {
+		var last time.Time
+
+		for fft := range inputFFT {
+
+			if s.hub != nil && time.Since(last) >= s.options.SpectrumInterval {
+				last = time.Now()
+				s.hub.Publish(events.SPECTRUM,
+					audio.ReduceSpectrum(fft, sampleRate, audio.SPECTRUM_BANDS))
+			}
+
+			if s.inDisplayFFT != nil {
+				select {
+				case s.inDisplayFFT <- fft:
+				case <-displayDone:
+				}
+			}
+		}
+
+		if s.inDisplayFFT != nil {
+			close(s.inDisplayFFT)
+		}
+	}()
+}
+
+func handleFFT(inFFT chan []complex128, outFFT chan []float32, done chan struct{}, debug bool) {
+
+	var previousPhase float64
+
+	for {
+		fft, ok := <-inFFT
+		if ok == false {
+			close(outFFT)
+			return
+		}
+
+		fftNb := len(fft)
+		idx := fftNb / 5 // Final frequency 8785 Hz at 204
+		//fmt.Printf("Final frequency %d %.3f\n", idx, 44100*float64(idx)/float64(fftNb))
+
+		// Prepare values
+		values := make([]float32, idx)
+
+		var maxIdx int
+		var maxValue, maxPhase, deltaPhase float64
+
+		for idx > 0 {
+			idx--
+			value, phase := cmplx.Polar(fft[idx])
+
+			if debug && value > maxValue {
+				maxValue = float64(value)
+				maxPhase = phase
+				deltaPhase = phase - previousPhase
+				maxIdx = idx
+			}
+
+			values[idx] = -float32(value) * 10
+		}
+
+		if debug {
+
+			deltaFreq := deltaPhase * 44100 / float64(fftNb) * 0.314 / 2
+			freq := 44100*float64(maxIdx)/float64(fftNb) + deltaFreq
+
+			fmt.Printf("MAX at %04d nb:%d f:%.3f Hz delta f: %.3f value:%.3f delta phase:%.3f\n",
+				maxIdx, fftNb, freq, deltaFreq, maxValue, deltaPhase)
+			previousPhase = maxPhase
+		}
+
+		select {
+		case outFFT <- values:
+		case <-done:
+		}
+	}
+}
@@ -0,0 +1,10 @@
+#version 410 core
+
+in vec4 color0;
+
+out vec4 fragColor;
+
+void main()
+{
+	fragColor = color0;
+}
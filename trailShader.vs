@@ -0,0 +1,15 @@
+#version 410 core
+
+in vec3 position;
+in vec4 color;
+
+out vec4 color0;
+
+uniform mat4 view;
+uniform mat4 projection;
+
+void main()
+{
+	gl_Position = projection * view * vec4(position, 1.0);
+	color0 = color;
+}